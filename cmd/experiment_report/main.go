@@ -0,0 +1,50 @@
+// experiment_report 读取提示词实验的记录文件，按实验和变体汇总评分与用户反馈并与对照组比较
+//
+// 用法：go run ./cmd/experiment_report [-config config/config.yaml] [-log 记录文件] [-json]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/experiment_module"
+	"log"
+	"os"
+)
+
+func main() {
+	configPath := flag.String("config", "config/config.yaml", "配置文件，用于读取 experiments.log")
+	logPath := flag.String("log", "", "实验记录文件，为空时使用配置中的 experiments.log")
+	asJSON := flag.Bool("json", false, "以 JSON 格式输出")
+	flag.Parse()
+
+	path := *logPath
+	if path == "" {
+		cfg, err := config.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("加载配置文件失败: %v", err)
+		}
+		path = cfg.Experiments.Log
+	}
+	if path == "" {
+		log.Fatalf("未指定实验记录文件，请使用 -log 或在配置中设置 experiments.log")
+	}
+
+	records, err := experiment_module.ReadRecords(path)
+	if err != nil {
+		log.Fatalf("读取实验记录失败: %v", err)
+	}
+	reports := experiment_module.BuildReport(records)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			log.Fatalf("输出报告失败: %v", err)
+		}
+		return
+	}
+	if err := experiment_module.WriteReport(os.Stdout, reports); err != nil {
+		log.Fatalf("输出报告失败: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+// storyeval 离线评测：读取 JSONL 格式的故事前提数据集，逐个生成故事，
+// 保存每个故事文档，并输出评分、长度、解析失败、耗时及 token 成本的汇总报告
+//
+// 用法：go run ./cmd/storyeval -input requests.jsonl [-model deepseek] [-out eval_output] [-limit 10]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/eval_module"
+	"flutterdreams/internal/story_generation/story_document"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// 文件名中不允许的字符
+var unsafeName = regexp.MustCompile(`[^\w.-]+`)
+
+func main() {
+	configPath := flag.String("config", "config/config.yaml", "配置文件")
+	inputPath := flag.String("input", "", "故事前提数据集（JSONL）")
+	outputDir := flag.String("out", "eval_output", "输出目录，故事文档保存在 stories/ 下，汇总报告为 report.json")
+	model := flag.String("model", "", "使用的模型（doubao、deepseek、ollama），为空时使用配置中的 default_model")
+	language := flag.String("language", "", "默认故事语言，数据集中指定的语言优先")
+	ageGroup := flag.String("age", "", "默认目标年龄段，数据集中指定的年龄段优先")
+	targetLength := flag.Int("target-length", 0, "默认目标长度，数据集中指定的长度优先")
+	limit := flag.Int("limit", 0, "最多评测的故事数，为 0 时评测全部")
+	promptPrice := flag.Float64("prompt-price", 0, "每千个输入 token 的价格")
+	completionPrice := flag.Float64("completion-price", 0, "每千个输出 token 的价格")
+	flag.Parse()
+
+	if *inputPath == "" {
+		log.Fatalf("请使用 -input 指定故事前提数据集")
+	}
+	if _, err := config.LoadConfig(*configPath); err != nil {
+		log.Fatalf("加载配置文件失败: %v", err)
+	}
+	if *model != "" {
+		config.GlobalConfig.DefaultModel = *model
+	}
+
+	items, err := eval_module.ReadItems(*inputPath)
+	if err != nil {
+		log.Fatalf("读取数据集失败: %v", err)
+	}
+	if *limit > 0 && len(items) > *limit {
+		items = items[:*limit]
+	}
+	storiesDir := filepath.Join(*outputDir, "stories")
+	if err := os.MkdirAll(storiesDir, 0o755); err != nil {
+		log.Fatalf("创建输出目录失败: %v", err)
+	}
+
+	// 用量按前后快照之差归属到每个故事，因此逐个生成
+	var results []eval_module.Result
+	for i, item := range items {
+		item.Language = firstNonEmpty(item.Language, *language)
+		item.AgeGroup = firstNonEmpty(item.AgeGroup, *ageGroup)
+		if item.TargetLength <= 0 {
+			item.TargetLength = *targetLength
+		}
+		log.Printf("评测 %d/%d: %s", i+1, len(items), item.ID)
+
+		before := common.CurrentUsage()
+		start := time.Now()
+		doc, err := story_generation.GenerateStoryDocument(item.Premise, story_generation.StoryOptions{
+			TargetLength: item.TargetLength,
+			AgeGroup:     item.AgeGroup,
+			Language:     item.Language,
+			RequestID:    item.ID,
+		})
+		result := eval_module.NewResult(item, doc, err, time.Since(start).Milliseconds(), common.CurrentUsage().Sub(before))
+		if err != nil {
+			log.Printf("生成故事 %s 失败: %v", item.ID, err)
+		} else {
+			result.Output = filepath.Join(storiesDir, unsafeName.ReplaceAllString(item.ID, "_")+".json")
+			if err := story_document.Save(doc, result.Output); err != nil {
+				log.Printf("保存故事 %s 失败: %v", item.ID, err)
+				result.Output = ""
+			}
+		}
+		results = append(results, result)
+	}
+
+	report := eval_module.BuildReport(config.GetConfig().DefaultModel, eval_module.Pricing{
+		PromptPer1K:     *promptPrice,
+		CompletionPer1K: *completionPrice,
+	}, results)
+	output := struct {
+		Report  eval_module.Report   `json:"report"`
+		Results []eval_module.Result `json:"results"`
+	}{report, results}
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		log.Fatalf("生成报告失败: %v", err)
+	}
+	reportPath := filepath.Join(*outputDir, "report.json")
+	if err := os.WriteFile(reportPath, data, 0o644); err != nil {
+		log.Fatalf("保存报告失败: %v", err)
+	}
+	if err := eval_module.WriteReport(os.Stdout, report); err != nil {
+		log.Fatalf("输出报告失败: %v", err)
+	}
+	log.Printf("报告已保存到 %s", reportPath)
+}
+
+func firstNonEmpty(value string, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
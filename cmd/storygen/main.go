@@ -0,0 +1,203 @@
+// storygen 在本地运行完整的故事生成流程，把故事文档、HTML、朗读音频和插图写入输出目录，
+// 便于编写脚本和调试，不需要启动 Web 服务
+//
+// 用法：go run ./cmd/storygen -premise "小兔子学会分享" [-age 3-5岁] [-style 水彩] [-language zh-Hans] [-model deepseek] [-out story_output]
+package main
+
+import (
+	"flag"
+	"flutterdreams/config"
+	"flutterdreams/internal/model"
+	"flutterdreams/internal/service"
+	"flutterdreams/internal/story_generation"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/plan_module"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"flutterdreams/internal/story_generation/story_document"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 各阶段在进度输出中的名称
+var stageNames = map[string]string{
+	common.PROGRESS_PLAN:       "计划",
+	common.PROGRESS_DRAFT:      "草稿",
+	common.PROGRESS_MODERATION: "审核",
+	common.PROGRESS_FINISH:     "收尾",
+	common.PROGRESS_DONE:       "完成",
+}
+
+func main() {
+	configPath := flag.String("config", "config/config.yaml", "配置文件")
+	premise := flag.String("premise", "", "故事前提，为 - 时从标准输入读取")
+	ageGroup := flag.String("age", "", "目标年龄段，如 3-5岁")
+	style := flag.String("style", "水彩", "插图画风，同 /story 接口的 image_type")
+	language := flag.String("language", "", "故事语言：zh-Hans（默认）、zh-Hant 或 en")
+	modelName := flag.String("model", "", "使用的模型（doubao、deepseek、ollama），为空时使用配置中的 default_model")
+	targetLength := flag.Int("target-length", 0, "目标长度（中文按字、英文按词），为 0 时使用年龄段的默认长度")
+	branching := flag.Bool("branching", false, "生成分支故事")
+	pinyin := flag.Bool("pinyin", false, "逐字标注拼音，只适用于中文故事")
+	activitySheet := flag.Bool("activity-sheet", false, "生成配套学习单")
+	voice := flag.String("voice", plan_module.CHARACTER_VOICES[0], "朗读音色")
+	noAudio := flag.Bool("no-audio", false, "不生成朗读音频")
+	noImages := flag.Bool("no-images", false, "不生成插图")
+	outputDir := flag.String("out", "story_output", "输出目录")
+	progress := flag.Bool("progress", true, "在标准错误输出各阶段的进度")
+	verbose := flag.Bool("verbose", false, "输出各模块的详细日志")
+	flag.Parse()
+
+	text := *premise
+	if text == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("读取故事前提失败: %v", err)
+		}
+		text = string(data)
+	}
+	if text = strings.TrimSpace(text); text == "" {
+		log.Fatalf("请使用 -premise 指定故事前提")
+	}
+	if !common.IsSupportedLanguage(*language) {
+		log.Fatalf("不支持的语言: %s", *language)
+	}
+	if *pinyin && !common.GetLanguage(*language).IsChinese() {
+		log.Fatalf("拼音标注只适用于中文故事")
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("加载配置文件失败: %v", err)
+	}
+	if *modelName != "" {
+		config.GlobalConfig.DefaultModel = *modelName
+	}
+	if err := prompt_module.Load(cfg.Prompts.Dir); err != nil {
+		log.Fatalf("加载提示词模板失败: %v", err)
+	}
+	if err := os.MkdirAll(*outputDir, 0o755); err != nil {
+		log.Fatalf("创建输出目录失败: %v", err)
+	}
+	// 各模块的日志默认关闭，只保留进度和结果
+	logger := log.New(os.Stderr, "", 0)
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	start := time.Now()
+	options := story_generation.StoryOptions{
+		TargetLength:  *targetLength,
+		AgeGroup:      *ageGroup,
+		ActivitySheet: *activitySheet,
+		Branching:     *branching,
+		Language:      common.GetLanguage(*language).Code,
+		Pinyin:        *pinyin,
+	}
+	if *progress {
+		options.Progress = func(p common.Progress) {
+			logger.Print(formatProgress(p, time.Since(start)))
+		}
+	}
+	doc, err := story_generation.GenerateStoryDocument(text, options)
+	if err != nil {
+		logger.Fatalf("生成故事失败: %v", err)
+	}
+
+	// 音频和插图是附加内容，失败时只输出错误，仍然保存故事文档
+	if !*noAudio {
+		report(logger, *progress, "生成朗读音频")
+		audioPath := filepath.Join(*outputDir, "story.mp3")
+		if err := service.SaveAudio(doc.FinalText, *voice, audioPath); err != nil {
+			logger.Printf("生成朗读音频失败: %v", err)
+		} else {
+			doc.AddMedia(story_document.MEDIA_AUDIO, "story.mp3", "")
+		}
+	}
+	if !*noImages {
+		report(logger, *progress, "生成插图")
+		if file, prompt, err := generateImage(doc.FinalText, *style, *outputDir); err != nil {
+			logger.Printf("生成插图失败: %v", err)
+		} else {
+			doc.AddMedia(story_document.MEDIA_IMAGE, file, prompt)
+		}
+	}
+
+	documentPath := filepath.Join(*outputDir, "story.json")
+	if err := story_document.Save(doc, documentPath); err != nil {
+		logger.Fatalf("保存故事文档失败: %v", err)
+	}
+	html, err := doc.ExportHTML()
+	if err != nil {
+		logger.Fatalf("导出 HTML 失败: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(*outputDir, "story.html"), []byte(html), 0o644); err != nil {
+		logger.Fatalf("保存 HTML 失败: %v", err)
+	}
+
+	fmt.Println(doc.FinalText)
+	logger.Printf("故事已保存到 %s（%d %s，耗时 %s）", *outputDir,
+		common.GetLanguage(doc.Language).CountLength(doc.FinalText), common.GetLanguage(doc.Language).LengthUnit,
+		time.Since(start).Round(time.Second))
+}
+
+// 进度的一行输出，如 "[00:42] 草稿 2/5"
+func formatProgress(p common.Progress, elapsed time.Duration) string {
+	line := fmt.Sprintf("[%02d:%02d] %s", int(elapsed.Minutes()), int(elapsed.Seconds())%60, stageNames[p.Stage])
+	if p.Total > 0 {
+		line += fmt.Sprintf(" %d/%d", p.Done, p.Total)
+	}
+	return line
+}
+
+func report(logger *log.Logger, enabled bool, message string) {
+	if enabled {
+		logger.Print(message)
+	}
+}
+
+// 生成插图提示词并调用文生图模型，把图片下载到输出目录，返回图片的相对路径和提示词
+func generateImage(story string, style string, outputDir string) (string, string, error) {
+	prompt, _, err := service.GenerateImagePrompt(story, style, nil)
+	if err != nil {
+		return "", "", err
+	}
+	imageUrl, err := model.GenerateImage(prompt)
+	if err != nil {
+		return "", "", err
+	}
+	imageUrl = strings.TrimSpace(imageUrl)
+	parsed, err := url.Parse(imageUrl)
+	if err != nil || parsed.Scheme == "" {
+		return "", "", fmt.Errorf("文生图模型返回的地址无效: %s", imageUrl)
+	}
+
+	response, err := http.Get(imageUrl)
+	if err != nil {
+		return "", "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("下载插图失败: %s", response.Status)
+	}
+	ext := path.Ext(parsed.Path)
+	if ext == "" {
+		ext = ".png"
+	}
+	file := "cover" + ext
+	output, err := os.Create(filepath.Join(outputDir, file))
+	if err != nil {
+		return "", "", err
+	}
+	defer output.Close()
+	if _, err := io.Copy(output, response.Body); err != nil {
+		return "", "", err
+	}
+	return file, prompt, nil
+}
@@ -11,6 +11,8 @@ import (
 type ServerConfig struct {
 	Host string `yaml:"host"`
 	Port int    `yaml:"port"`
+	// 允许跨域请求的前端地址，为空时只允许 http://localhost:3000
+	AllowedOrigins []string `yaml:"allowed_origins"`
 }
 
 type DoubaoConfig struct {
@@ -32,13 +34,99 @@ type OllamaConfig struct {
 	Model string `yaml:"model"`
 }
 
+// ModerationCategory 内容审核的一个类别及其关键词
+type ModerationCategory struct {
+	Name     string   `yaml:"name"`
+	Action   string   `yaml:"action"` // block、rewrite 或 flag
+	Keywords []string `yaml:"keywords"`
+	// 类别适用的故事语言（如 zh-Hans、en），为空时适用于所有语言
+	Language string `yaml:"language"`
+}
+
+type ModerationConfig struct {
+	// 为空时使用内置类别；配置后整体替换内置类别
+	Categories []ModerationCategory `yaml:"categories"`
+	// 大模型分类器：llm（默认）或 none
+	Classifier string `yaml:"classifier"`
+}
+
+// PromptsConfig 提示词模板配置
+type PromptsConfig struct {
+	// 覆盖内置模板的目录，按 <语言>/<阶段>/<名称>.tmpl 组织；为空时只使用内置模板
+	Dir string `yaml:"dir"`
+}
+
+// ExperimentVariant 实验中的一个提示词变体及其流量百分比
+type ExperimentVariant struct {
+	// 变体名称，对应 <名称>@<变体>.tmpl 模板；control 表示基准模板
+	Name    string `yaml:"name"`
+	Traffic int    `yaml:"traffic"`
+}
+
+// Experiment 针对某个阶段的提示词 A/B 实验
+type Experiment struct {
+	Name string `yaml:"name"`
+	// 阶段：plan、draft、rewrite 或 edit
+	Stage    string              `yaml:"stage"`
+	Variants []ExperimentVariant `yaml:"variants"`
+}
+
+type ExperimentsConfig struct {
+	// 实验记录（分配结果、评分、用户反馈）追加写入的 JSONL 文件，为空时不记录
+	Log    string       `yaml:"log"`
+	Active []Experiment `yaml:"active"`
+}
+
+// BatchConfig 批量生成配置
+type BatchConfig struct {
+	// 同时生成的故事数，为 0 时使用默认值
+	Concurrency int `yaml:"concurrency"`
+	// 单个批次最多包含的故事数，为 0 时使用默认值
+	MaxItems int `yaml:"max_items"`
+	// 已完成的批次保留的分钟数，超时后不能再查询或下载，为 0 时使用默认值
+	RetentionMinutes int `yaml:"retention_minutes"`
+}
+
+// JobsConfig 异步任务配置
+type JobsConfig struct {
+	// 同时运行的任务数，为 0 时使用默认值
+	Concurrency int `yaml:"concurrency"`
+	// 任务存储：memory（默认）或 disk
+	Store string `yaml:"store"`
+	// disk 存储的目录
+	Dir string `yaml:"dir"`
+	// 已结束的任务保留的分钟数，为 0 时使用默认值
+	RetentionMinutes int `yaml:"retention_minutes"`
+}
+
+// LibraryConfig 故事库配置
+type LibraryConfig struct {
+	// SQLite 数据库文件，为空时使用默认路径
+	Path string `yaml:"path"`
+	// 为 true 时不保存生成的故事
+	Disabled bool `yaml:"disabled"`
+}
+
+// AuthConfig 认证配置；认证默认启用，除 /health 外的接口都需要 API key，账户保存在故事库中
+type AuthConfig struct {
+	// 为 true 时关闭认证，任何人都可以调用接口且没有管理员，只用于本地开发
+	Disabled bool `yaml:"disabled"`
+}
+
 type Config struct {
-	Server       ServerConfig    `yaml:"server"`
-	DoubaoConfig DoubaoConfig    `yaml:"doubao"`
-	YoudaoTTS    YoudaoTTSConfig `yaml:"youdaoTTS"`
-	Deepseek     DeepseekConfig  `yaml:"deepseek"`
-	Ollama       OllamaConfig    `yaml:"ollama"`
-	DefaultModel string          `yaml:"default_model"`
+	Server       ServerConfig      `yaml:"server"`
+	DoubaoConfig DoubaoConfig      `yaml:"doubao"`
+	YoudaoTTS    YoudaoTTSConfig   `yaml:"youdaoTTS"`
+	Deepseek     DeepseekConfig    `yaml:"deepseek"`
+	Ollama       OllamaConfig      `yaml:"ollama"`
+	DefaultModel string            `yaml:"default_model"`
+	Moderation   ModerationConfig  `yaml:"moderation"`
+	Prompts      PromptsConfig     `yaml:"prompts"`
+	Experiments  ExperimentsConfig `yaml:"experiments"`
+	Batch        BatchConfig       `yaml:"batch"`
+	Jobs         JobsConfig        `yaml:"jobs"`
+	Library      LibraryConfig     `yaml:"library"`
+	Auth         AuthConfig        `yaml:"auth"`
 }
 
 var (
@@ -36,12 +36,12 @@ func GenerateAudioFromText(q string, voiceName string) (string, error) {
 
 	// 如果音频生成成功
 	if result == nil {
-		log.Fatalf("failed to generate audio")
+		log.Printf("failed to generate audio")
 		return "", fmt.Errorf("failed to generate audio")
 	}
 	// 保存音频文件
 	utils2.SaveFile(filePath, result, false)
-	log.Printf("save file path: " + filePath)
+	log.Printf("save file path: %s", filePath)
 
 	return fileName, nil
 }
@@ -30,7 +30,7 @@ func GenerateStory(systemContent string, userContent string) (string, error) {
 		},
 	)
 	if err != nil {
-		return "", fmt.Errorf("ChatCompletion error: %v", err)
+		return "", fmt.Errorf("ChatCompletion error: %w", err)
 	}
 	return resp.Choices[0].Message.Content, nil
 }
@@ -0,0 +1,183 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"flutterdreams/internal/story_generation/library_module"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// 账户存储随故事库打开，未启用认证时账户接口没有意义，返回 404
+func accountStore(wr http.ResponseWriter, r *http.Request) (library_module.AccountStore, *library_module.User, bool) {
+	user := currentUser(r)
+	if user == nil {
+		writeError(wr, http.StatusNotFound, "Authentication disabled", fmt.Errorf("enable auth in config to manage accounts"))
+		return nil, nil, false
+	}
+	return library_module.Accounts(), user, true
+}
+
+// AccountResponse 用户信息及其 API key
+type AccountResponse struct {
+	Status string                  `json:"status"`
+	User   *library_module.User    `json:"user"`
+	Keys   []library_module.APIKey `json:"keys"`
+}
+
+// GetAccount 当前用户的信息和 API key 列表
+func GetAccount(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	accounts, user, ok := accountStore(wr, r)
+	if !ok {
+		return
+	}
+	keys, err := accounts.ListKeys(user.ID)
+	if err != nil {
+		logError(wr, "Failed to list API keys", err)
+		return
+	}
+	writeJSON(wr, http.StatusOK, AccountResponse{Status: "success", User: user, Keys: keys})
+}
+
+// CreateKeyRequest 创建 API key 的请求体
+type CreateKeyRequest struct {
+	Name string `json:"name"`
+	// 为其他用户创建 key，只有管理员可以使用；为空时为当前用户创建
+	UserID string `json:"user_id"`
+}
+
+// KeyResponse 新创建的 API key，api_key 只返回这一次
+type KeyResponse struct {
+	Status string                 `json:"status"`
+	Key    *library_module.APIKey `json:"key"`
+	APIKey string                 `json:"api_key"`
+}
+
+func CreateKey(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	accounts, user, ok := accountStore(wr, r)
+	if !ok {
+		return
+	}
+	var req CreateKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if req.UserID == "" {
+		req.UserID = user.ID
+	}
+	if !canAccess(r, req.UserID) {
+		writeError(wr, http.StatusForbidden, "Admin required", fmt.Errorf("only admins can create keys for other users"))
+		return
+	}
+	key, plain, err := accounts.CreateKey(req.UserID, req.Name)
+	if errors.Is(err, library_module.ErrNotFound) {
+		writeError(wr, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		logError(wr, "Failed to create API key", err)
+		return
+	}
+	writeJSON(wr, http.StatusCreated, KeyResponse{Status: "success", Key: key, APIKey: plain})
+}
+
+// RevokeKey 撤销 API key，用户可以撤销自己的 key，管理员可以撤销任何 key
+func RevokeKey(wr http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	accounts, _, ok := accountStore(wr, r)
+	if !ok {
+		return
+	}
+	key, err := accounts.GetKey(params.ByName("id"))
+	if err == nil && !canAccess(r, key.UserID) {
+		err = library_module.ErrNotFound
+	}
+	if err == nil {
+		err = accounts.RevokeKey(key.ID)
+	}
+	if errors.Is(err, library_module.ErrNotFound) {
+		writeError(wr, http.StatusNotFound, "API key not found", err)
+		return
+	}
+	if err != nil {
+		logError(wr, "Failed to revoke API key", err)
+		return
+	}
+	writeJSON(wr, http.StatusOK, MessageResponse{Status: "success", Message: "API key revoked successfully"})
+}
+
+// CreateUserRequest 创建用户的请求体
+type CreateUserRequest struct {
+	Name  string `json:"name"`
+	Admin bool   `json:"admin"`
+	// 用量限额，各项为 0 时不限
+	Quota library_module.Quota `json:"quota"`
+}
+
+// UserResponse 新创建的用户及其第一个 API key
+type UserResponse struct {
+	Status string                 `json:"status"`
+	User   *library_module.User   `json:"user"`
+	Key    *library_module.APIKey `json:"key"`
+	APIKey string                 `json:"api_key"`
+}
+
+// UsersResponse 全部用户
+type UsersResponse struct {
+	Status string                `json:"status"`
+	Users  []library_module.User `json:"users"`
+}
+
+// CreateUser 创建用户并返回其第一个 API key，只有管理员可以调用
+func CreateUser(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	accounts, _, ok := accountStore(wr, r)
+	if !ok {
+		return
+	}
+	var req CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(wr, http.StatusBadRequest, "Missing name", fmt.Errorf("name is required"))
+		return
+	}
+	if err := validateQuota(req.Quota); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid quota", err)
+		return
+	}
+	user, err := accounts.CreateUser(req.Name, req.Admin, req.Quota)
+	if errors.Is(err, library_module.ErrNameTaken) {
+		writeError(wr, http.StatusConflict, "User already exists", err)
+		return
+	}
+	if err != nil {
+		logError(wr, "Failed to create user", err)
+		return
+	}
+	key, plain, err := accounts.CreateKey(user.ID, "default")
+	if err != nil {
+		logError(wr, "Failed to create API key", err)
+		return
+	}
+	writeJSON(wr, http.StatusCreated, UserResponse{Status: "success", User: user, Key: key, APIKey: plain})
+}
+
+// ListUsers 列出全部用户，只有管理员可以调用
+func ListUsers(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	accounts, _, ok := accountStore(wr, r)
+	if !ok {
+		return
+	}
+	users, err := accounts.ListUsers()
+	if err != nil {
+		logError(wr, "Failed to list users", err)
+		return
+	}
+	writeJSON(wr, http.StatusOK, UsersResponse{Status: "success", Users: users})
+}
@@ -0,0 +1,108 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/library_module"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// API_KEY_HEADER 传递 API key 的请求头，也可以使用 Authorization: Bearer <key>
+const API_KEY_HEADER = "X-API-Key"
+
+type contextKey int
+
+const principalContextKey contextKey = iota
+
+// 请求的用户及其使用的 API key
+type principal struct {
+	user *library_module.User
+	key  *library_module.APIKey
+}
+
+// authenticate 要求请求带有有效的 API key，并把对应的用户和 key 放入请求的 context；
+// 配置 auth.disabled 关闭认证时直接调用 handle
+func authenticate(handle httprouter.Handle) httprouter.Handle {
+	return func(wr http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if config.GetConfig().Auth.Disabled {
+			handle(wr, r, params)
+			return
+		}
+		accounts := library_module.Accounts()
+		if accounts == nil {
+			writeError(wr, http.StatusServiceUnavailable, "Account store unavailable", fmt.Errorf("story library is disabled"))
+			return
+		}
+		key := apiKey(r)
+		if key == "" {
+			wr.Header().Set("WWW-Authenticate", `Bearer realm="flutterdreams"`)
+			writeError(wr, http.StatusUnauthorized, "Missing API key", fmt.Errorf("%s header or Authorization: Bearer is required", API_KEY_HEADER))
+			return
+		}
+		user, apiKey, err := accounts.Authenticate(key)
+		if errors.Is(err, library_module.ErrUnauthorized) {
+			wr.Header().Set("WWW-Authenticate", `Bearer realm="flutterdreams", error="invalid_token"`)
+			writeError(wr, http.StatusUnauthorized, "Invalid API key", err)
+			return
+		}
+		if err != nil {
+			logError(wr, "Failed to authenticate", err)
+			return
+		}
+		handle(wr, r.WithContext(context.WithValue(r.Context(), principalContextKey, principal{user: user, key: apiKey})), params)
+	}
+}
+
+// requireAdmin 只允许管理员访问；未启用认证时没有管理员，一律拒绝
+func requireAdmin(handle httprouter.Handle) httprouter.Handle {
+	return authenticate(func(wr http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if user := currentUser(r); user == nil || !user.Admin {
+			writeError(wr, http.StatusForbidden, "Admin required", fmt.Errorf("this endpoint requires an admin API key"))
+			return
+		}
+		handle(wr, r, params)
+	})
+}
+
+// 从请求头中读取 API key
+func apiKey(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get(API_KEY_HEADER)); key != "" {
+		return key
+	}
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) > len("Bearer ") && strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(authorization[len("Bearer "):])
+	}
+	return ""
+}
+
+// 当前请求的用户，未启用认证时为 nil
+func currentUser(r *http.Request) *library_module.User {
+	principal, _ := r.Context().Value(principalContextKey).(principal)
+	return principal.user
+}
+
+// 当前请求使用的 API key，未启用认证时为 nil
+func currentKey(r *http.Request) *library_module.APIKey {
+	principal, _ := r.Context().Value(principalContextKey).(principal)
+	return principal.key
+}
+
+// 当前请求的用户 ID，作为新故事、任务、批次和音频的所有者；未启用认证时为空
+func ownerID(r *http.Request) string {
+	if user := currentUser(r); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+// 当前用户能否访问所有者为 owner 的资源：未启用认证时不限制，管理员可以访问所有资源
+func canAccess(r *http.Request, owner string) bool {
+	user := currentUser(r)
+	return user == nil || user.Admin || user.ID == owner
+}
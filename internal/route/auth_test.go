@@ -0,0 +1,184 @@
+package route
+
+import (
+	"encoding/json"
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/library_module"
+	"flutterdreams/internal/story_generation/story_document"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 启用认证并打开临时故事库，返回管理员的 API key
+func setupAuth(t *testing.T) string {
+	t.Helper()
+	previous := config.GlobalConfig
+	config.GlobalConfig.Auth.Disabled = false
+	t.Cleanup(func() { config.GlobalConfig = previous })
+	if err := library_module.Open(config.LibraryConfig{Path: filepath.Join(t.TempDir(), "stories.db")}); err != nil {
+		t.Fatal(err)
+	}
+	key, err := library_module.EnsureAdmin(library_module.Accounts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func request(t *testing.T, method string, path string, key string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if key != "" {
+		r.Header.Set("Authorization", "Bearer "+key)
+	}
+	wr := httptest.NewRecorder()
+	InitRouter().ServeHTTP(wr, r)
+	return wr
+}
+
+func TestAuthentication(t *testing.T) {
+	adminKey := setupAuth(t)
+
+	if wr := request(t, "GET", "/health", "", ""); wr.Code != http.StatusOK {
+		t.Errorf("/health 不需要认证: %d", wr.Code)
+	}
+	if wr := request(t, "GET", "/stories", "", ""); wr.Code != http.StatusUnauthorized || wr.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("缺少 API key 应返回 401: %d", wr.Code)
+	}
+	if wr := request(t, "GET", "/stories", "fd_invalid", ""); wr.Code != http.StatusUnauthorized {
+		t.Errorf("无效的 API key 应返回 401: %d", wr.Code)
+	}
+
+	// 管理员创建两个用户
+	var alice, bob UserResponse
+	for _, c := range []struct {
+		body     string
+		response *UserResponse
+	}{{`{"name": "alice", "quota": {"stories_per_day": 1}}`, &alice}, {`{"name": "bob"}`, &bob}} {
+		wr := request(t, "POST", "/users", adminKey, c.body)
+		if wr.Code != http.StatusCreated {
+			t.Fatalf("创建用户失败: %d %s", wr.Code, wr.Body)
+		}
+		json.Unmarshal(wr.Body.Bytes(), c.response)
+	}
+	if wr := request(t, "POST", "/users", adminKey, `{"name": "alice"}`); wr.Code != http.StatusConflict {
+		t.Errorf("重复的用户名应返回 409: %d", wr.Code)
+	}
+	if wr := request(t, "GET", "/users", alice.APIKey, ""); wr.Code != http.StatusForbidden {
+		t.Errorf("普通用户不能管理用户: %d", wr.Code)
+	}
+
+	// alice 的故事只有 alice 和管理员能看到
+	doc := &story_document.StoryDocument{ID: story_document.NewID(), Title: "小兔子找朋友", Metadata: story_document.Metadata{CreatedAt: time.Now()}}
+	saveStory(alice.User.ID, library_module.SOURCE_STORY, "", nil, doc)
+	for _, c := range []struct {
+		key      string
+		expected int
+	}{{alice.APIKey, http.StatusOK}, {adminKey, http.StatusOK}, {bob.APIKey, http.StatusNotFound}} {
+		if wr := request(t, "GET", "/stories/"+doc.ID, c.key, ""); wr.Code != c.expected {
+			t.Errorf("读取故事返回 %d，期望 %d", wr.Code, c.expected)
+		}
+	}
+	var page ListStoriesResponse
+	json.Unmarshal(request(t, "GET", "/stories", bob.APIKey, "").Body.Bytes(), &page)
+	if page.Total != 0 {
+		t.Errorf("bob 不应看到 alice 的故事: %+v", page)
+	}
+	if wr := request(t, "DELETE", "/stories/"+doc.ID, bob.APIKey, ""); wr.Code != http.StatusNotFound {
+		t.Errorf("bob 不能删除 alice 的故事: %d", wr.Code)
+	}
+
+	// 只能评价自己可以访问的故事
+	config.GlobalConfig.Experiments.Log = filepath.Join(t.TempDir(), "experiments.jsonl")
+	for _, c := range []struct {
+		key      string
+		storyID  string
+		expected int
+	}{{alice.APIKey, doc.ID, http.StatusOK}, {bob.APIKey, doc.ID, http.StatusNotFound}, {alice.APIKey, "missing", http.StatusNotFound}} {
+		body := `{"story_id": "` + c.storyID + `", "rating": 5}`
+		if wr := request(t, "POST", "/storyFeedback", c.key, body); wr.Code != c.expected {
+			t.Errorf("评价故事 %s 返回 %d，期望 %d", c.storyID, wr.Code, c.expected)
+		}
+	}
+
+	// 当天的故事数已达到限额
+	if err := library_module.Accounts().RecordUsage(alice.User.ID, alice.Key.ID, time.Now(), library_module.Usage{Stories: 1, PromptTokens: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if wr := request(t, "POST", "/generateStory", alice.APIKey, `{"premise": "小兔子"}`); wr.Code != http.StatusTooManyRequests {
+		t.Errorf("超出每日限额应返回 429: %d", wr.Code)
+	}
+	var usage UsageResponse
+	json.Unmarshal(request(t, "GET", "/usage", alice.APIKey, "").Body.Bytes(), &usage)
+	if usage.User.Today.Stories != 1 || usage.User.Month.PromptTokens != 100 || len(usage.User.Exhausted) != 1 || usage.Key == nil {
+		t.Errorf("用量错误: %+v", usage)
+	}
+	if wr := request(t, "GET", "/usage?user_id="+alice.User.ID, bob.APIKey, ""); wr.Code != http.StatusForbidden {
+		t.Errorf("普通用户不能查看其他用户的用量: %d", wr.Code)
+	}
+	if wr := request(t, "PUT", "/users/"+alice.User.ID+"/quota", alice.APIKey, `{"stories_per_day": 5}`); wr.Code != http.StatusForbidden {
+		t.Errorf("普通用户不能修改自己的限额: %d", wr.Code)
+	}
+	if wr := request(t, "PUT", "/users/"+alice.User.ID+"/quota", adminKey, `{"stories_per_day": -1}`); wr.Code != http.StatusBadRequest {
+		t.Errorf("限额不能为负数: %d", wr.Code)
+	}
+	if wr := request(t, "PUT", "/users/"+alice.User.ID+"/quota", adminKey, `{"stories_per_day": 5}`); wr.Code != http.StatusOK {
+		t.Errorf("管理员修改限额失败: %d", wr.Code)
+	}
+	// key 的限额单独计算
+	if wr := request(t, "PUT", "/keys/"+alice.Key.ID+"/quota", bob.APIKey, `{"images_per_month": 1}`); wr.Code != http.StatusNotFound {
+		t.Errorf("不能修改其他用户 key 的限额: %d", wr.Code)
+	}
+	if wr := request(t, "PUT", "/keys/"+alice.Key.ID+"/quota", alice.APIKey, `{"stories_per_day": 1}`); wr.Code != http.StatusOK {
+		t.Errorf("修改自己 key 的限额失败: %d", wr.Code)
+	}
+	for _, body := range []string{`{"stories_per_day": 2}`, `{"stories_per_day": 0}`, `{}`} {
+		if wr := request(t, "PUT", "/keys/"+alice.Key.ID+"/quota", alice.APIKey, body); wr.Code != http.StatusForbidden {
+			t.Errorf("普通用户不能提高 key 的限额 %s: %d", body, wr.Code)
+		}
+	}
+	if wr := request(t, "PUT", "/keys/"+bob.Key.ID+"/quota", bob.APIKey, `{"images_per_month": 3}`); wr.Code != http.StatusOK {
+		t.Errorf("用户和 key 都不限时可以设置任意限额: %d", wr.Code)
+	}
+	wr := request(t, "POST", "/generateStory", alice.APIKey, `{"premise": "小兔子"}`)
+	if wr.Code != http.StatusTooManyRequests || !strings.Contains(wr.Body.String(), "key stories_per_day") {
+		t.Errorf("超出 key 的限额应返回 429: %d %s", wr.Code, wr.Body)
+	}
+
+	// 撤销后不能再使用
+	if wr := request(t, "DELETE", "/keys/"+bob.Key.ID, alice.APIKey, ""); wr.Code != http.StatusNotFound {
+		t.Errorf("不能撤销其他用户的 key: %d", wr.Code)
+	}
+	if wr := request(t, "DELETE", "/keys/"+bob.Key.ID, bob.APIKey, ""); wr.Code != http.StatusOK {
+		t.Errorf("撤销自己的 key 失败: %d", wr.Code)
+	}
+	if wr := request(t, "GET", "/me", bob.APIKey, ""); wr.Code != http.StatusUnauthorized {
+		t.Errorf("撤销后的 key 应返回 401: %d", wr.Code)
+	}
+}
+
+func TestAuthenticationEnabledByDefault(t *testing.T) {
+	setupAuth(t)
+	config.GlobalConfig.Auth = config.AuthConfig{}
+
+	if wr := request(t, "GET", "/batches/missing", "", ""); wr.Code != http.StatusUnauthorized {
+		t.Errorf("默认配置应要求 API key: %d", wr.Code)
+	}
+}
+
+func TestAuthenticationDisabled(t *testing.T) {
+	previous := config.GlobalConfig
+	config.GlobalConfig.Auth.Disabled = true
+	t.Cleanup(func() { config.GlobalConfig = previous })
+
+	if wr := request(t, "GET", "/batches/missing", "", ""); wr.Code != http.StatusNotFound {
+		t.Errorf("未启用认证时不需要 API key: %d", wr.Code)
+	}
+	if wr := request(t, "GET", "/users", "", ""); wr.Code != http.StatusForbidden {
+		t.Errorf("未启用认证时不能管理用户: %d", wr.Code)
+	}
+}
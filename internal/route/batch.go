@@ -0,0 +1,134 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"flutterdreams/internal/story_generation"
+	"flutterdreams/internal/story_generation/batch_module"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/library_module"
+	"flutterdreams/internal/story_generation/story_document"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// 队列已满时建议客户端等待的秒数
+const batchRetryAfterSeconds = "30"
+
+// BatchRequest 批量生成请求体，每一项与 /generateStory 的请求体相同
+type BatchRequest struct {
+	Items []StoryGenerateRequest `json:"items"`
+}
+
+// BatchResponse 批次状态，提交后及查询时返回
+type BatchResponse struct {
+	Status  string             `json:"status"`
+	Message string             `json:"message"`
+	Batch   batch_module.Batch `json:"batch"`
+	// 全部完成后可下载结果的地址
+	DownloadUrl string `json:"download_url,omitempty"`
+}
+
+func CreateBatch(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	// 任何一项无效时整个批次都不提交，无效的字段以 items[序号]. 为前缀
+	errs := &common.ValidationError{}
+	for i := range req.Items {
+		errs.AddAll(fmt.Sprintf("items[%d]", i), req.Items[i].validate())
+	}
+	if err := errs.Err(); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid batch request", err)
+		return
+	}
+
+	account, ok := reserveQuota(wr, r, pipelineDemand(), len(req.Items))
+	if !ok {
+		return
+	}
+
+	id, owner := requestID(wr, r), ownerID(r)
+	requests := make([]batch_module.Request, len(req.Items))
+	for i := range req.Items {
+		item := &req.Items[i]
+		// 每个故事的请求 ID 由批次的请求 ID 加序号组成，用于分配提示词实验变体
+		saved := *item
+		var finish func(library_module.Usage)
+		requests[i] = batch_module.Request{
+			Premise: item.Premise,
+			Options: item.storyOptions(fmt.Sprintf("%s-%d", id, i)),
+			BeforeGenerate: func(options *story_generation.StoryOptions) {
+				options.Meter, finish = account.start()
+			},
+			AfterGenerate: func(doc *story_document.StoryDocument, err error) {
+				finish(storyCount(err))
+				if err == nil {
+					saveStory(owner, library_module.SOURCE_GENERATE_STORY, "", saved, doc)
+				}
+			},
+		}
+	}
+
+	batch, err := batch_module.Default().Submit(owner, requests)
+	if errors.Is(err, batch_module.ErrQueueFull) {
+		account.cancel()
+		wr.Header().Set("Retry-After", batchRetryAfterSeconds)
+		writeError(wr, http.StatusServiceUnavailable, "Batch queue is full", err)
+		return
+	}
+	if err != nil {
+		account.cancel()
+		writeError(wr, http.StatusBadRequest, "Failed to submit batch", err)
+		return
+	}
+	writeBatch(wr, http.StatusAccepted, "Batch submitted successfully", batch)
+}
+
+func GetBatch(wr http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	batch, ok := batch_module.Default().Get(params.ByName("id"))
+	if !ok || !canAccess(r, batch.OwnerID) {
+		writeError(wr, http.StatusNotFound, "Batch not found", batch_module.ErrNotFound)
+		return
+	}
+	writeBatch(wr, http.StatusOK, "Batch status", batch)
+}
+
+func DownloadBatch(wr http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id := params.ByName("id")
+	batch, ok := batch_module.Default().Get(id)
+	if !ok || !canAccess(r, batch.OwnerID) {
+		writeError(wr, http.StatusNotFound, "Batch not found", batch_module.ErrNotFound)
+		return
+	}
+	if !batch.Done() {
+		writeError(wr, http.StatusConflict, "Batch not finished", batch_module.ErrNotFinished)
+		return
+	}
+
+	wr.Header().Set("Content-Type", "application/zip")
+	wr.Header().Set("Content-Disposition", "attachment; filename=\"batch-"+id+".zip\"")
+	wr.WriteHeader(http.StatusOK)
+	if err := batch_module.Default().WriteArchive(id, wr); err != nil {
+		// 响应头已经写出，只能记录日志
+		log.Printf("写入批次 %s 的压缩包失败: %v", id, err)
+	}
+}
+
+func writeBatch(wr http.ResponseWriter, status int, message string, batch batch_module.Batch) {
+	response := BatchResponse{Status: "success", Message: message, Batch: batch}
+	if batch.Done() {
+		response.DownloadUrl = "/batches/" + batch.ID + "/download"
+	}
+	wr.Header().Set("Content-Type", "application/json")
+	wr.WriteHeader(status)
+	if err := json.NewEncoder(wr).Encode(response); err != nil {
+		logError(wr, "Error encoding response", err)
+	}
+}
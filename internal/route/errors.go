@@ -0,0 +1,106 @@
+package route
+
+import (
+	"errors"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/moderation_module"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// 只在接口层出现的错误类型，其余见 common.ERROR_*
+const (
+	ERROR_UNAUTHORIZED = "unauthorized"
+	ERROR_FORBIDDEN    = "forbidden"
+	ERROR_NOT_FOUND    = "not_found"
+	ERROR_CONFLICT     = "conflict"
+	ERROR_UNAVAILABLE  = "unavailable"
+)
+
+// ErrorResponse 所有接口出错时的响应体
+type ErrorResponse struct {
+	// error，内容被拦截时为 blocked
+	Status string `json:"status"`
+	// 错误类型，客户端据此处理错误，message 只用于展示和排查
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// 请求参数无效时列出各个无效的字段
+	Fields []common.FieldError `json:"fields,omitempty"`
+	// 内容被拦截时的审核结果
+	Moderation []moderation_module.Decision `json:"moderation,omitempty"`
+}
+
+// 各错误类型对应的状态码
+var errorStatus = map[string]int{
+	common.ERROR_VALIDATION: http.StatusBadRequest,
+	ERROR_UNAUTHORIZED:      http.StatusUnauthorized,
+	ERROR_FORBIDDEN:         http.StatusForbidden,
+	ERROR_NOT_FOUND:         http.StatusNotFound,
+	ERROR_CONFLICT:          http.StatusConflict,
+	common.ERROR_BLOCKED:    http.StatusUnprocessableEntity,
+	common.ERROR_QUOTA:      http.StatusTooManyRequests,
+	common.ERROR_INTERNAL:   http.StatusInternalServerError,
+	common.ERROR_UPSTREAM:   http.StatusBadGateway,
+	ERROR_UNAVAILABLE:       http.StatusServiceUnavailable,
+	common.ERROR_TIMEOUT:    http.StatusGatewayTimeout,
+}
+
+// 错误本身没有类型时按状态码确定错误类型，各类型的状态码互不相同
+func statusCode(status int) string {
+	for code, codeStatus := range errorStatus {
+		if codeStatus == status {
+			return code
+		}
+	}
+	return common.ERROR_INTERNAL
+}
+
+// 记录日志并按错误类型返回错误信息：请求无效 400，被拦截 422，模型等外部服务失败 502、超时 504，其他 500
+func logError(wr http.ResponseWriter, message string, err error) {
+	status, ok := errorStatus[common.ErrorCode(err)]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	writeError(wr, status, message, err)
+}
+
+// 记录日志并以指定状态码返回错误信息；错误带有类型时 code 使用该类型。
+// 5xx 的错误详情可能包含内部路径、上游响应等信息，只写入日志，响应中只返回 message
+func writeError(wr http.ResponseWriter, status int, message string, err error) {
+	log.Printf("%s: %v", message, err)
+	response := ErrorResponse{Status: "error", Code: common.ErrorCode(err), Message: fmt.Sprintf("%s: %v", message, err)}
+	if status >= http.StatusInternalServerError {
+		response.Message = message
+	}
+	if response.Code == common.ERROR_INTERNAL {
+		response.Code = statusCode(status)
+	}
+	var validation *common.ValidationError
+	if errors.As(err, &validation) {
+		response.Fields = validation.Fields
+	}
+	if blocked, ok := moderation_module.IsBlocked(err); ok {
+		response.Status = "blocked"
+		response.Moderation = []moderation_module.Decision{blocked.Decision}
+	}
+	wr.Header().Set("X-Content-Type-Options", "nosniff")
+	writeJSON(wr, status, response)
+}
+
+// 保存在任务中的错误，恢复其错误类型
+type storedError struct {
+	code    string
+	message string
+}
+
+func (e *storedError) Error() string {
+	return e.message
+}
+
+func (e *storedError) Code() string {
+	if e.code == "" {
+		return common.ERROR_INTERNAL
+	}
+	return e.code
+}
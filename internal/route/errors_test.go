@@ -0,0 +1,116 @@
+package route
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flutterdreams/internal/service"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/moderation_module"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 解析错误响应体，检查状态码和错误类型
+func decodeError(t *testing.T, wr *httptest.ResponseRecorder, status int, code string) ErrorResponse {
+	t.Helper()
+	var response ErrorResponse
+	if err := json.Unmarshal(wr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("错误响应不是 JSON: %s", wr.Body)
+	}
+	if wr.Code != status || response.Code != code || wr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("错误响应为 %d %s，期望 %d %s", wr.Code, response.Code, status, code)
+	}
+	return response
+}
+
+// 无效字段的名称
+func fieldNames(response ErrorResponse) string {
+	var fields []string
+	for _, field := range response.Fields {
+		fields = append(fields, field.Field)
+	}
+	return fmt.Sprint(fields)
+}
+
+func TestValidationErrors(t *testing.T) {
+	key := setupAuth(t)
+	decodeError(t, request(t, "POST", "/generateStory", key, `{"premise":`), http.StatusBadRequest, common.ERROR_VALIDATION)
+	decodeError(t, request(t, "GET", "/getAudio", key, ""), http.StatusBadRequest, common.ERROR_VALIDATION)
+
+	for _, c := range []struct {
+		path     string
+		body     string
+		expected string
+	}{
+		{"/generateStory", `{"target_length": -5, "language": "fr"}`, "[premise target_length language]"},
+		{"/generateStory", `{"premise": "小兔子", "language": "en", "pinyin": true}`, "[pinyin]"},
+		{"/story", `{"story_content": "小兔子", "child_age_group": "幼儿", "reading_minutes": 60}`, "[story_type image_type child_age_group reading_minutes]"},
+		{"/continueStory", `{"mode": "prequel"}`, "[document mode]"},
+		{"/batches", `{"items": [{"premise": "小兔子"}, {"premise": ""}]}`, "[items[1].premise]"},
+		{"/storyFeedback", `{"rating": 6}`, "[story_id rating]"},
+	} {
+		response := decodeError(t, request(t, "POST", c.path, key, c.body), http.StatusBadRequest, common.ERROR_VALIDATION)
+		if names := fieldNames(response); names != c.expected {
+			t.Errorf("%s 的无效字段为 %s，期望 %s", c.path, names, c.expected)
+		}
+	}
+}
+
+func TestLogErrorStatus(t *testing.T) {
+	blocked := &moderation_module.BlockedError{Decision: moderation_module.Decision{Stage: moderation_module.STAGE_INPUT, Action: moderation_module.ACTION_BLOCK}}
+	for _, c := range []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{errors.New("磁盘已满"), http.StatusInternalServerError, common.ERROR_INTERNAL},
+		{common.Invalid("mode", "不支持的续写方式"), http.StatusBadRequest, common.ERROR_VALIDATION},
+		{fmt.Errorf("生成草稿时出错: %w", common.Upstream("doubao", errors.New("服务不可用"))), http.StatusBadGateway, common.ERROR_UPSTREAM},
+		{common.Upstream("deepseek", context.DeadlineExceeded), http.StatusGatewayTimeout, common.ERROR_TIMEOUT},
+		{fmt.Errorf("审核失败: %w", blocked), http.StatusUnprocessableEntity, common.ERROR_BLOCKED},
+		// 任务中保存的错误
+		{&storedError{code: common.ERROR_UPSTREAM, message: "调用 doubao 失败"}, http.StatusBadGateway, common.ERROR_UPSTREAM},
+		{&storedError{message: "服务重启，任务中断"}, http.StatusInternalServerError, common.ERROR_INTERNAL},
+	} {
+		wr := httptest.NewRecorder()
+		logError(wr, "Failed to generate story", c.err)
+		response := decodeError(t, wr, c.status, c.code)
+		if (c.code == common.ERROR_BLOCKED) != (response.Status == "blocked" && len(response.Moderation) == 1) {
+			t.Errorf("被拦截时应返回审核结果: %+v", response)
+		}
+	}
+
+	// 5xx 不返回错误详情，4xx 返回
+	wr := httptest.NewRecorder()
+	logError(wr, "Failed to generate story", errors.New("open /var/lib/flutterdreams/stories.db: permission denied"))
+	if response := decodeError(t, wr, http.StatusInternalServerError, common.ERROR_INTERNAL); response.Message != "Failed to generate story" {
+		t.Errorf("5xx 不应返回错误详情: %s", response.Message)
+	}
+	wr = httptest.NewRecorder()
+	logError(wr, "Invalid continue request", common.Invalid("mode", "不支持的续写方式"))
+	if response := decodeError(t, wr, http.StatusBadRequest, common.ERROR_VALIDATION); response.Message == "Invalid continue request" {
+		t.Errorf("4xx 应返回错误详情: %s", response.Message)
+	}
+
+	// 错误本身没有类型时按状态码确定
+	wr = httptest.NewRecorder()
+	writeError(wr, http.StatusNotFound, "Story not found", errors.New("missing"))
+	decodeError(t, wr, http.StatusNotFound, ERROR_NOT_FOUND)
+}
+
+func TestCreateStoryResponsePartial(t *testing.T) {
+	resp := &service.StoryResponse{StoryTitle: "小兔子", StoryContent: "从前有一只小兔子。", Failures: []service.StageFailure{
+		{Stage: service.STAGE_IMAGE, Code: common.ERROR_UPSTREAM, Message: "调用 image 失败"},
+	}}
+	response := createStoryResponse(resp)
+	if response.Status != "partial" || fmt.Sprint(response.Failed) != "[image]" {
+		t.Errorf("部分成功的响应错误: %+v", response)
+	}
+	resp.Failures = nil
+	if response := createStoryResponse(resp); response.Status != "success" {
+		t.Errorf("全部成功时 status 应为 success: %+v", response)
+	}
+}
@@ -0,0 +1,170 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"flutterdreams/internal/service"
+	"flutterdreams/internal/story_generation"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/job_module"
+	"flutterdreams/internal/story_generation/library_module"
+	"flutterdreams/internal/story_generation/moderation_module"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// JobResponse 任务状态，提交后及查询时返回
+type JobResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Job     *job_module.Job `json:"job"`
+}
+
+// SubmitGenerateStoryJob 异步执行 /generateStory，请求体相同，任务结果与 /generateStory 的响应体相同
+func SubmitGenerateStoryJob(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req StoryGenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid story request", err)
+		return
+	}
+	account, ok := checkQuota(wr, r, pipelineDemand())
+	if !ok {
+		return
+	}
+
+	id, owner := requestID(wr, r), ownerID(r)
+	options := req.storyOptions(id)
+	submitJob(wr, account, job_module.KIND_GENERATE_STORY, owner, id, req, func(progress common.ProgressFunc) (interface{}, error) {
+		options.Progress = progress
+		meter, finish := account.start()
+		options.Meter = meter
+		doc, err := story_generation.GenerateStoryDocument(req.Premise, options)
+		finish(storyCount(err))
+		if err != nil {
+			return nil, err
+		}
+		saveStory(owner, library_module.SOURCE_GENERATE_STORY, "", req, doc)
+		return newStoryGenerateResponse(doc), nil
+	})
+}
+
+// SubmitStoryJob 异步执行 /story，请求体相同，任务结果与 /story 的响应体相同
+func SubmitStoryJob(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req service.StoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid story request", err)
+		return
+	}
+	account, ok := checkQuota(wr, r, storyDemand(&req))
+	if !ok {
+		return
+	}
+
+	owner := ownerID(r)
+	submitJob(wr, account, job_module.KIND_STORY, owner, requestID(wr, r), req, func(progress common.ProgressFunc) (interface{}, error) {
+		var resp service.StoryResponse
+		storyService := service.NewStoryService()
+		storyService.Progress = progress
+		meter, finish := account.start()
+		storyService.Meter = meter
+		err := storyService.ProcessStoryRequest(&req, &resp)
+		finish(storyUsage(&resp))
+		if err != nil {
+			return nil, err
+		}
+		if resp.StoryContent != "" {
+			doc := resp.Document(&req)
+			saveStory(owner, library_module.SOURCE_STORY, req.StoryType, req, doc)
+			resp.StoryID = doc.ID
+		}
+		return createStoryResponse(&resp), nil
+	})
+}
+
+// 提交任务，提交失败时释放 account 占用的限额
+func submitJob(wr http.ResponseWriter, account usageAccount, kind string, owner string, requestID string, input interface{}, run job_module.Runner) {
+	manager, err := job_module.Default()
+	if err != nil {
+		account.cancel()
+		logError(wr, "Job store unavailable", err)
+		return
+	}
+	job, err := manager.Submit(kind, owner, requestID, input, run)
+	if err != nil {
+		account.cancel()
+		writeError(wr, http.StatusServiceUnavailable, "Failed to submit job", err)
+		return
+	}
+	writeJob(wr, http.StatusAccepted, "Job submitted successfully", job)
+}
+
+func GetJob(wr http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	job, ok := findJob(wr, r, params.ByName("id"))
+	if !ok {
+		return
+	}
+	writeJob(wr, http.StatusOK, "Job status", job)
+}
+
+// GetJobResult 只返回任务的最终结果：未结束时返回 409，被拦截时返回 422，失败时按错误类型返回与同步接口相同的状态码
+func GetJobResult(wr http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	job, ok := findJob(wr, r, params.ByName("id"))
+	if !ok {
+		return
+	}
+	switch job.Status {
+	case job_module.STATUS_SUCCEEDED:
+		wr.Header().Set("Content-Type", "application/json")
+		wr.WriteHeader(http.StatusOK)
+		wr.Write(job.Result)
+	case job_module.STATUS_BLOCKED:
+		var blocked error = &moderation_module.BlockedError{}
+		if job.Moderation != nil {
+			blocked = &moderation_module.BlockedError{Decision: *job.Moderation}
+		}
+		writeError(wr, http.StatusUnprocessableEntity, "Job blocked", blocked)
+	case job_module.STATUS_FAILED:
+		logError(wr, "Job failed", &storedError{code: job.ErrorCode, message: job.Error})
+	default:
+		writeError(wr, http.StatusConflict, "Job not finished", errors.New(job.Status))
+	}
+}
+
+// 查找任务，不存在或不属于当前用户时返回 404
+func findJob(wr http.ResponseWriter, r *http.Request, id string) (*job_module.Job, bool) {
+	manager, err := job_module.Default()
+	if err != nil {
+		logError(wr, "Job store unavailable", err)
+		return nil, false
+	}
+	job, err := manager.Get(id)
+	if err == nil && !canAccess(r, job.OwnerID) {
+		err = job_module.ErrNotFound
+	}
+	if errors.Is(err, job_module.ErrNotFound) {
+		writeError(wr, http.StatusNotFound, "Job not found", err)
+		return nil, false
+	}
+	if err != nil {
+		logError(wr, "Failed to read job", err)
+		return nil, false
+	}
+	return job, true
+}
+
+func writeJob(wr http.ResponseWriter, status int, message string, job *job_module.Job) {
+	wr.Header().Set("Content-Type", "application/json")
+	wr.WriteHeader(status)
+	if err := json.NewEncoder(wr).Encode(JobResponse{Status: "success", Message: message, Job: job}); err != nil {
+		logError(wr, "Error encoding response", err)
+	}
+}
@@ -0,0 +1,266 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"flutterdreams/internal/story_generation/library_module"
+	"flutterdreams/internal/story_generation/story_document"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// 保存生成的故事到故事库，owner 为所有者的用户 ID；故事库未打开或保存失败时只记录日志，不影响响应
+func saveStory(owner string, source string, storyType string, request interface{}, doc *story_document.StoryDocument) {
+	store := library_module.Default()
+	if store == nil || doc == nil {
+		return
+	}
+	story, err := library_module.NewStory(source, storyType, request, doc)
+	if err == nil {
+		story.OwnerID = owner
+		err = store.Save(story)
+	}
+	if err != nil {
+		log.Printf("保存故事 %s 到故事库时发生错误: %v", doc.ID, err)
+	}
+	for _, media := range doc.Media {
+		if media.Type == story_document.MEDIA_AUDIO {
+			recordAudioOwner(owner, media.URL)
+		}
+	}
+}
+
+// 记录本地音频的所有者，只在启用认证时记录
+func recordAudioOwner(owner string, audioUrl string) {
+	accounts := library_module.Accounts()
+	path, ok := localAudioFile(audioUrl)
+	if owner == "" || accounts == nil || !ok {
+		return
+	}
+	if err := accounts.SetMediaOwner(filepath.Base(path), owner); err != nil {
+		log.Printf("记录音频 %s 的所有者时发生错误: %v", path, err)
+	}
+}
+
+// 当前用户能否获取本地音频：未启用认证时不限制，管理员可以获取所有音频，没有所有者记录的音频只有管理员可以获取
+func canAccessAudio(r *http.Request, path string) bool {
+	user := currentUser(r)
+	if user == nil || user.Admin {
+		return true
+	}
+	owner, err := library_module.Accounts().MediaOwner(filepath.Base(path))
+	if err != nil && !errors.Is(err, library_module.ErrNotFound) {
+		log.Printf("读取音频 %s 的所有者时发生错误: %v", path, err)
+	}
+	return err == nil && owner == user.ID
+}
+
+// 故事库未打开时返回 503
+func libraryStore(wr http.ResponseWriter) (library_module.Store, bool) {
+	store := library_module.Default()
+	if store == nil {
+		writeError(wr, http.StatusServiceUnavailable, "Story library unavailable", fmt.Errorf("story library is disabled"))
+		return nil, false
+	}
+	return store, true
+}
+
+// ListStoriesResponse 故事列表
+type ListStoriesResponse struct {
+	Status string `json:"status"`
+	library_module.Page
+}
+
+// ListStories 分页列出故事，支持 title、character、story_type、age_group、from、to 筛选以及 page、page_size 分页；
+// 启用认证时普通用户只能看到自己的故事，管理员可以用 owner 按用户筛选
+func ListStories(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	listStories(wr, r, false)
+}
+
+// SearchStories 全文检索故事的标题、正文、角色名和标签，q 为检索词，结果按相关度排列；
+// 筛选和分页参数与 ListStories 相同
+func SearchStories(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	listStories(wr, r, true)
+}
+
+func listStories(wr http.ResponseWriter, r *http.Request, search bool) {
+	store, ok := libraryStore(wr)
+	if !ok {
+		return
+	}
+	query, message, err := storyQuery(r.URL.Query())
+	if err == nil && search && strings.TrimSpace(query.Text) == "" {
+		message, err = "Missing search text", fmt.Errorf("q is required")
+	}
+	if err != nil {
+		writeError(wr, http.StatusBadRequest, message, err)
+		return
+	}
+	if !search {
+		query.Text = ""
+	}
+	// 普通用户只能看到自己的故事，管理员可以按 owner 筛选
+	if user := currentUser(r); user != nil && !user.Admin {
+		query.OwnerID = user.ID
+	}
+
+	page, err := store.List(query)
+	if err != nil {
+		logError(wr, "Failed to list stories", err)
+		return
+	}
+	writeJSON(wr, http.StatusOK, ListStoriesResponse{Status: "success", Page: page})
+}
+
+// 解析列表和检索的查询参数，出错时返回错误提示
+func storyQuery(values url.Values) (library_module.Query, string, error) {
+	query := library_module.Query{
+		OwnerID:   values.Get("owner"),
+		Text:      values.Get("q"),
+		Title:     values.Get("title"),
+		Character: values.Get("character"),
+		StoryType: values.Get("story_type"),
+		AgeGroup:  values.Get("age_group"),
+	}
+	var err error
+	if query.Page, err = intParam(values, "page"); err != nil {
+		return query, "Invalid page", err
+	}
+	if query.PageSize, err = intParam(values, "page_size"); err != nil {
+		return query, "Invalid page_size", err
+	}
+	if query.CreatedFrom, err = dateParam(values, "from", false); err != nil {
+		return query, "Invalid from", err
+	}
+	if query.CreatedTo, err = dateParam(values, "to", true); err != nil {
+		return query, "Invalid to", err
+	}
+	return query, "", nil
+}
+
+// StoryResponse 故事库中的一个故事，包含完整的故事文档
+type StoryResponse struct {
+	Status string                `json:"status"`
+	Story  *library_module.Story `json:"story"`
+}
+
+func GetStory(wr http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	store, ok := libraryStore(wr)
+	if !ok {
+		return
+	}
+	story, err := store.Get(params.ByName("id"))
+	if err == nil && !canAccess(r, story.OwnerID) {
+		err = library_module.ErrNotFound
+	}
+	if errors.Is(err, library_module.ErrNotFound) {
+		writeError(wr, http.StatusNotFound, "Story not found", err)
+		return
+	}
+	if err != nil {
+		logError(wr, "Failed to read story", err)
+		return
+	}
+	writeJSON(wr, http.StatusOK, StoryResponse{Status: "success", Story: story})
+}
+
+// DeleteStory 删除故事，同时删除本地保存的朗读音频
+func DeleteStory(wr http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	store, ok := libraryStore(wr)
+	if !ok {
+		return
+	}
+	id := params.ByName("id")
+	story, err := store.Get(id)
+	if err == nil && !canAccess(r, story.OwnerID) {
+		err = library_module.ErrNotFound
+	}
+	if err == nil {
+		err = store.Delete(id)
+	}
+	if errors.Is(err, library_module.ErrNotFound) {
+		writeError(wr, http.StatusNotFound, "Story not found", err)
+		return
+	}
+	if err != nil {
+		logError(wr, "Failed to delete story", err)
+		return
+	}
+	removeLocalAudio(story.Document)
+	writeJSON(wr, http.StatusOK, MessageResponse{Status: "success", Message: "Story deleted successfully"})
+}
+
+// 删除故事文档引用的 /getAudio 音频文件
+func removeLocalAudio(doc *story_document.StoryDocument) {
+	for _, media := range doc.Media {
+		if media.Type != story_document.MEDIA_AUDIO {
+			continue
+		}
+		path, ok := localAudioFile(media.URL)
+		if !ok {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("删除音频文件 %s 时发生错误: %v", path, err)
+		}
+	}
+}
+
+// /getAudio 音频地址对应的本地文件
+func localAudioFile(audioUrl string) (string, bool) {
+	parsed, err := url.Parse(audioUrl)
+	if err != nil || parsed.Path != "/getAudio" || parsed.Query().Get("filename") == "" {
+		return "", false
+	}
+	return getAudioFilePath(parsed.Query().Get("filename")), true
+}
+
+// 读取整数查询参数，未提供时为 0
+func intParam(values url.Values, name string) (int, error) {
+	text := values.Get(name)
+	if text == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return value, nil
+}
+
+// 读取日期查询参数，格式为 2006-01-02 或 RFC 3339，未提供时为零值；
+// 只有日期的结束时间包含当天，返回次日零点
+func dateParam(values url.Values, name string, end bool) (time.Time, error) {
+	text := values.Get(name)
+	if text == "" {
+		return time.Time{}, nil
+	}
+	if value, err := time.Parse(time.RFC3339, text); err == nil {
+		return value, nil
+	}
+	value, err := time.ParseInLocation("2006-01-02", text, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a date (2006-01-02) or RFC 3339 time", name)
+	}
+	if end {
+		value = value.AddDate(0, 0, 1)
+	}
+	return value, nil
+}
+
+func writeJSON(wr http.ResponseWriter, status int, response interface{}) {
+	wr.Header().Set("Content-Type", "application/json")
+	wr.WriteHeader(status)
+	if err := json.NewEncoder(wr).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
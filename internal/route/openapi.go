@@ -0,0 +1,75 @@
+package route
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+	"gopkg.in/yaml.v2"
+)
+
+// 接口的 OpenAPI 3 文档，响应体的字段与本包及各模块中的类型一一对应，由 openapi_test.go 检查
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  map[string]interface{}
+	openAPIErr  error
+)
+
+// OpenAPISpec 解析后的 OpenAPI 文档，可直接编码为 JSON
+func OpenAPISpec() (map[string]interface{}, error) {
+	openAPIOnce.Do(func() {
+		var doc interface{}
+		if openAPIErr = yaml.Unmarshal(openAPISpec, &doc); openAPIErr != nil {
+			return
+		}
+		converted, ok := jsonValue(doc).(map[string]interface{})
+		if !ok {
+			openAPIErr = fmt.Errorf("openapi document must be a mapping")
+			return
+		}
+		openAPIDoc = converted
+	})
+	return openAPIDoc, openAPIErr
+}
+
+// yaml.v2 把映射解析为 map[interface{}]interface{}，转换为 encoding/json 能编码的类型
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// GetOpenAPIYAML 返回 YAML 格式的接口文档
+func GetOpenAPIYAML(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	wr.Header().Set("Content-Type", "application/yaml")
+	wr.WriteHeader(http.StatusOK)
+	wr.Write(openAPISpec)
+}
+
+// GetOpenAPIJSON 返回 JSON 格式的接口文档
+func GetOpenAPIJSON(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	doc, err := OpenAPISpec()
+	if err != nil {
+		logError(wr, "Failed to load OpenAPI document", err)
+		return
+	}
+	writeJSON(wr, http.StatusOK, doc)
+}
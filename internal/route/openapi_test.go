@@ -0,0 +1,447 @@
+package route
+
+import (
+	"encoding/json"
+	"flutterdreams/internal/service"
+	"flutterdreams/internal/story_generation/batch_module"
+	"flutterdreams/internal/story_generation/bilingual_module"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/education_module"
+	"flutterdreams/internal/story_generation/job_module"
+	"flutterdreams/internal/story_generation/library_module"
+	"flutterdreams/internal/story_generation/moderation_module"
+	"flutterdreams/internal/story_generation/pinyin_module"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"flutterdreams/internal/story_generation/readability_module"
+	"flutterdreams/internal/story_generation/story_document"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 文档中的 schema 对应的 Go 类型
+var openAPITypes = map[string]reflect.Type{
+	"ErrorResponse":         reflect.TypeOf(ErrorResponse{}),
+	"FieldError":            reflect.TypeOf(common.FieldError{}),
+	"HealthResponse":        reflect.TypeOf(HealthResponse{}),
+	"MessageResponse":       reflect.TypeOf(MessageResponse{}),
+	"ModerationDecision":    reflect.TypeOf(moderation_module.Decision{}),
+	"PromptRef":             reflect.TypeOf(prompt_module.Ref{}),
+	"StoryRequest":          reflect.TypeOf(service.StoryRequest{}),
+	"StageFailure":          reflect.TypeOf(service.StageFailure{}),
+	"CreateStoryResponse":   reflect.TypeOf(CreateStoryResponse{}),
+	"StoryGenerateRequest":  reflect.TypeOf(StoryGenerateRequest{}),
+	"StoryGenerateResponse": reflect.TypeOf(StoryGenerateResponse{}),
+	"StoryContinueRequest":  reflect.TypeOf(StoryContinueRequest{}),
+	"StoryContinueResponse": reflect.TypeOf(StoryContinueResponse{}),
+	"ParallelAudioRequest":  reflect.TypeOf(ParallelAudioRequest{}),
+	"ParallelAudioResponse": reflect.TypeOf(ParallelAudioResponse{}),
+	"ExportStoryRequest":    reflect.TypeOf(ExportStoryRequest{}),
+	"StoryFeedbackRequest":  reflect.TypeOf(StoryFeedbackRequest{}),
+	"BatchRequest":          reflect.TypeOf(BatchRequest{}),
+	"BatchResponse":         reflect.TypeOf(BatchResponse{}),
+	"Batch":                 reflect.TypeOf(batch_module.Batch{}),
+	"BatchItem":             reflect.TypeOf(batch_module.Item{}),
+	"JobResponse":           reflect.TypeOf(JobResponse{}),
+	"Job":                   reflect.TypeOf(job_module.Job{}),
+	"Progress":              reflect.TypeOf(common.Progress{}),
+	"JobSection":            reflect.TypeOf(job_module.Section{}),
+	"ListStoriesResponse":   reflect.TypeOf(ListStoriesResponse{}),
+	"StorySummary":          reflect.TypeOf(library_module.Summary{}),
+	"StoryResponse":         reflect.TypeOf(StoryResponse{}),
+	"LibraryStory":          reflect.TypeOf(library_module.Story{}),
+	"AccountResponse":       reflect.TypeOf(AccountResponse{}),
+	"User":                  reflect.TypeOf(library_module.User{}),
+	"APIKey":                reflect.TypeOf(library_module.APIKey{}),
+	"CreateKeyRequest":      reflect.TypeOf(CreateKeyRequest{}),
+	"KeyResponse":           reflect.TypeOf(KeyResponse{}),
+	"CreateUserRequest":     reflect.TypeOf(CreateUserRequest{}),
+	"UserResponse":          reflect.TypeOf(UserResponse{}),
+	"UsersResponse":         reflect.TypeOf(UsersResponse{}),
+	"Quota":                 reflect.TypeOf(library_module.Quota{}),
+	"QuotaResponse":         reflect.TypeOf(QuotaResponse{}),
+	"Usage":                 reflect.TypeOf(library_module.Usage{}),
+	"UsageReport":           reflect.TypeOf(UsageReport{}),
+	"UsageResponse":         reflect.TypeOf(UsageResponse{}),
+	// 文档中只给出说明的结构
+	"StoryDocument":     reflect.TypeOf(story_document.StoryDocument{}),
+	"ReadabilityReport": reflect.TypeOf(readability_module.ReadabilityReport{}),
+	"CoverageReport":    reflect.TypeOf(education_module.CoverageReport{}),
+	"ActivitySheet":     reflect.TypeOf(education_module.ActivitySheet{}),
+	"StoryGraph":        reflect.TypeOf(common.StoryGraph{}),
+	"ParallelText":      reflect.TypeOf(bilingual_module.ParallelText{}),
+	"PinyinAnnotation":  reflect.TypeOf(pinyin_module.Annotation{}),
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+func loadSpec(t *testing.T) map[string]interface{} {
+	t.Helper()
+	doc, err := OpenAPISpec()
+	if err != nil {
+		t.Fatalf("无法解析 openapi.yaml: %v", err)
+	}
+	return doc
+}
+
+func object(value interface{}) map[string]interface{} {
+	m, _ := value.(map[string]interface{})
+	return m
+}
+
+// 按 "#/components/..." 查找文档中的定义
+func resolve(doc map[string]interface{}, ref string) map[string]interface{} {
+	node := doc
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		node = object(node[part])
+	}
+	return node
+}
+
+func refName(schema map[string]interface{}) string {
+	ref, _ := schema["$ref"].(string)
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// 文档中的路径和方法都已注册，注册的路由也都在文档中
+func TestOpenAPIRoutes(t *testing.T) {
+	paths := object(loadSpec(t)["paths"])
+	router := InitRouter()
+	documented := map[string]bool{}
+	for path, item := range paths {
+		for method := range object(item) {
+			method = strings.ToUpper(method)
+			documented[method+" "+path] = true
+			if handle, _, _ := router.Lookup(method, strings.ReplaceAll(path, "{id}", "x")); handle == nil {
+				t.Errorf("文档中的 %s %s 没有注册", method, path)
+			}
+		}
+	}
+
+	source, err := os.ReadFile("route.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := regexp.MustCompile(`router\.(GET|POST|PUT|DELETE)\("([^"]+)"`).FindAllStringSubmatch(string(source), -1)
+	if len(routes) == 0 {
+		t.Fatal("route.go 中没有找到路由")
+	}
+	for _, route := range routes {
+		path := regexp.MustCompile(`:(\w+)`).ReplaceAllString(route[2], "{$1}")
+		if !documented[route[1]+" "+path] {
+			t.Errorf("%s %s 没有写入 openapi.yaml", route[1], path)
+		}
+	}
+}
+
+// Go 类型编码为 JSON 时的字段，展开匿名嵌入的结构
+func jsonFields(typ reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if field.Anonymous && tag == "" {
+			for name, embedded := range jsonFields(field.Type) {
+				fields[name] = embedded
+			}
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" || field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// 检查 schema 与 Go 类型是否一致，$ref 必须指向该类型对应的 schema
+func compareType(schema map[string]interface{}, typ reflect.Type) error {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	// 原样保存的 JSON 可以引用任何 schema
+	if typ == rawType || typ.Kind() == reflect.Interface {
+		return nil
+	}
+	if name := refName(schema); name != "" {
+		if openAPITypes[name] != typ {
+			return fmt.Errorf("引用了 %s，Go 类型为 %s", name, typ)
+		}
+		return nil
+	}
+	kind, _ := schema["type"].(string)
+	var expected string
+	switch {
+	case typ == timeType || typ.Kind() == reflect.String:
+		expected = "string"
+	case typ.Kind() == reflect.Bool:
+		expected = "boolean"
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Uint64:
+		expected = "integer"
+	case typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64:
+		expected = "number"
+	case typ.Kind() == reflect.Slice:
+		if kind != "array" {
+			return fmt.Errorf("类型为 %s，应为 array", kind)
+		}
+		return compareType(object(schema["items"]), typ.Elem())
+	default:
+		expected = "object"
+	}
+	if kind != expected {
+		return fmt.Errorf("类型为 %s，应为 %s", kind, expected)
+	}
+	return nil
+}
+
+// 每个 schema 的字段与对应 Go 类型的 JSON 字段一致
+func TestOpenAPISchemas(t *testing.T) {
+	schemas := object(object(loadSpec(t)["components"])["schemas"])
+	for name := range schemas {
+		if openAPITypes[name] == nil {
+			t.Errorf("schema %s 没有对应的 Go 类型", name)
+		}
+	}
+	for name, typ := range openAPITypes {
+		schema := object(schemas[name])
+		if schema == nil {
+			t.Errorf("openapi.yaml 中没有 schema %s", name)
+			continue
+		}
+		if schema["additionalProperties"] == true {
+			continue
+		}
+		properties := object(schema["properties"])
+		fields := jsonFields(typ)
+		for property := range properties {
+			if _, ok := fields[property]; !ok {
+				t.Errorf("%s.%s 不是 %s 的字段", name, property, typ)
+			}
+		}
+		for field, structField := range fields {
+			property := object(properties[field])
+			if property == nil {
+				t.Errorf("%s 缺少字段 %s", name, field)
+				continue
+			}
+			if err := compareType(property, structField.Type); err != nil {
+				t.Errorf("%s.%s %v", name, field, err)
+			}
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, field := range required {
+			if _, ok := properties[field.(string)]; !ok {
+				t.Errorf("%s 的必填字段 %s 不存在", name, field)
+			}
+		}
+	}
+}
+
+// 按 schema 校验解码后的 JSON 值，返回全部不符合的地方
+func validateSchema(doc map[string]interface{}, schema map[string]interface{}, value interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		return validateSchema(doc, resolve(doc, ref), value, path)
+	}
+	if value == nil {
+		if schema["nullable"] == true || len(schema) == 0 || schema["type"] == nil {
+			return nil
+		}
+		return []string{path + " 为 null"}
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		for _, option := range oneOf {
+			if len(validateSchema(doc, object(option), value, path)) == 0 {
+				return nil
+			}
+		}
+		return []string{path + " 不符合 oneOf 中的任何一个"}
+	}
+
+	var problems []string
+	switch schema["type"] {
+	case "object":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{path + " 应为 object"}
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, field := range required {
+			if _, ok := m[field.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s 缺少必填字段 %s", path, field))
+			}
+		}
+		properties := object(schema["properties"])
+		for field, item := range m {
+			property := object(properties[field])
+			if property == nil {
+				if properties != nil && schema["additionalProperties"] != true {
+					problems = append(problems, fmt.Sprintf("%s.%s 不在文档中", path, field))
+				}
+				continue
+			}
+			problems = append(problems, validateSchema(doc, property, item, path+"."+field)...)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{path + " 应为 array"}
+		}
+		for i, item := range items {
+			problems = append(problems, validateSchema(doc, object(schema["items"]), item, path+"["+strconv.Itoa(i)+"]")...)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return []string{path + " 应为 string"}
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return []string{path + " 应为 integer"}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{path + " 应为 number"}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{path + " 应为 boolean"}
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		for _, allowed := range enum {
+			if allowed == value {
+				return problems
+			}
+		}
+		problems = append(problems, fmt.Sprintf("%s 的值 %v 不在 %v 中", path, value, enum))
+	}
+	return problems
+}
+
+// 查找请求路径对应的文档路径
+func specPath(paths map[string]interface{}, path string) string {
+	segments := strings.Split(path, "/")
+	for template := range paths {
+		parts := strings.Split(template, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		matched := true
+		for i, part := range parts {
+			if part != segments[i] && !strings.HasPrefix(part, "{") {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return template
+		}
+	}
+	return ""
+}
+
+// 发送请求并按文档中该状态码（或 default）的响应校验响应体
+func conform(t *testing.T, method string, path string, key string, body string, status int) []byte {
+	t.Helper()
+	doc := loadSpec(t)
+	paths := object(doc["paths"])
+	wr := request(t, method, path, key, body)
+	if wr.Code != status {
+		t.Fatalf("%s %s 返回 %d，期望 %d: %s", method, path, wr.Code, status, wr.Body)
+	}
+
+	template := specPath(paths, strings.Split(path, "?")[0])
+	operation := object(object(paths[template])[strings.ToLower(method)])
+	if operation == nil {
+		t.Fatalf("%s %s 不在文档中", method, path)
+	}
+	responses := object(operation["responses"])
+	response := object(responses[strconv.Itoa(status)])
+	if response == nil {
+		response = object(responses["default"])
+	}
+	if ref, ok := response["$ref"].(string); ok {
+		response = resolve(doc, ref)
+	}
+	if response == nil {
+		t.Fatalf("%s %s 的文档中没有 %d 响应", method, template, status)
+	}
+	media := object(object(response["content"])["application/json"])
+	if media == nil {
+		t.Fatalf("%s %s 的 %d 响应不是 JSON", method, template, status)
+	}
+	if contentType := wr.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("%s %s 的 Content-Type 为 %s", method, path, contentType)
+	}
+	var value interface{}
+	if err := json.Unmarshal(wr.Body.Bytes(), &value); err != nil {
+		t.Fatalf("%s %s 的响应不是 JSON: %s", method, path, wr.Body)
+	}
+	problems := validateSchema(doc, object(media["schema"]), value, "response")
+	sort.Strings(problems)
+	for _, problem := range problems {
+		t.Errorf("%s %s %d: %s", method, path, status, problem)
+	}
+	return wr.Body.Bytes()
+}
+
+// 各接口的实际响应符合文档
+func TestOpenAPIResponses(t *testing.T) {
+	adminKey := setupAuth(t)
+
+	conform(t, "GET", "/health", "", "", http.StatusOK)
+	conform(t, "GET", "/openapi.json", "", "", http.StatusOK)
+	if wr := request(t, "GET", "/openapi.yaml", "", ""); wr.Code != http.StatusOK || wr.Body.Len() != len(openAPISpec) {
+		t.Errorf("/openapi.yaml 返回 %d", wr.Code)
+	}
+
+	// 错误响应
+	conform(t, "GET", "/me", "", "", http.StatusUnauthorized)
+	conform(t, "POST", "/generateStory", adminKey, `{"premise":`, http.StatusBadRequest)
+	conform(t, "POST", "/story", adminKey, `{}`, http.StatusBadRequest)
+	conform(t, "POST", "/batches", adminKey, `{"items": [{"premise": ""}]}`, http.StatusBadRequest)
+	conform(t, "GET", "/batches/missing", adminKey, "", http.StatusNotFound)
+	conform(t, "GET", "/jobs/missing", adminKey, "", http.StatusNotFound)
+	conform(t, "GET", "/jobs/missing/result", adminKey, "", http.StatusNotFound)
+	conform(t, "GET", "/stories/missing", adminKey, "", http.StatusNotFound)
+
+	// 账户和用量
+	conform(t, "GET", "/me", adminKey, "", http.StatusOK)
+	var user UserResponse
+	json.Unmarshal(conform(t, "POST", "/users", adminKey, `{"name": "alice", "quota": {"stories_per_day": 3}}`, http.StatusCreated), &user)
+	conform(t, "POST", "/users", adminKey, `{"name": "alice"}`, http.StatusConflict)
+	conform(t, "GET", "/users", adminKey, "", http.StatusOK)
+	conform(t, "GET", "/users", user.APIKey, "", http.StatusForbidden)
+	var key KeyResponse
+	json.Unmarshal(conform(t, "POST", "/keys", user.APIKey, `{"name": "tablet"}`, http.StatusCreated), &key)
+	conform(t, "PUT", "/users/"+user.User.ID+"/quota", adminKey, `{"stories_per_day": 5}`, http.StatusOK)
+	conform(t, "PUT", "/keys/"+key.Key.ID+"/quota", user.APIKey, `{"images_per_month": -1}`, http.StatusBadRequest)
+	conform(t, "PUT", "/keys/"+key.Key.ID+"/quota", user.APIKey, `{"images_per_month": 1}`, http.StatusForbidden)
+	conform(t, "PUT", "/keys/"+key.Key.ID+"/quota", user.APIKey, `{"stories_per_day": 5, "images_per_month": 1}`, http.StatusOK)
+	conform(t, "GET", "/usage", key.APIKey, "", http.StatusOK)
+	conform(t, "DELETE", "/keys/"+key.Key.ID, user.APIKey, "", http.StatusOK)
+
+	// 故事库
+	conform(t, "GET", "/stories", user.APIKey, "", http.StatusOK)
+	doc := &story_document.StoryDocument{ID: story_document.NewID(), Title: "小兔子找朋友", Premise: "小兔子找朋友", Metadata: story_document.Metadata{CreatedAt: time.Now()}}
+	saveStory(user.User.ID, library_module.SOURCE_STORY, "童话", map[string]string{"story_content": "小兔子"}, doc)
+	conform(t, "GET", "/stories?page=1&page_size=5", user.APIKey, "", http.StatusOK)
+	conform(t, "GET", "/stories?from=yesterday", user.APIKey, "", http.StatusBadRequest)
+	conform(t, "GET", "/search?q=小兔子", user.APIKey, "", http.StatusOK)
+	conform(t, "GET", "/stories/"+doc.ID, user.APIKey, "", http.StatusOK)
+	conform(t, "DELETE", "/stories/"+doc.ID, user.APIKey, "", http.StatusOK)
+}
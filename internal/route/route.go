@@ -2,32 +2,83 @@ package route
 
 import (
 	"encoding/json"
+	"errors"
 	"flutterdreams/internal/service"
 	"flutterdreams/internal/story_generation"
+	"flutterdreams/internal/story_generation/bilingual_module"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/education_module"
+	"flutterdreams/internal/story_generation/experiment_module"
+	"flutterdreams/internal/story_generation/library_module"
+	"flutterdreams/internal/story_generation/moderation_module"
+	"flutterdreams/internal/story_generation/pinyin_module"
+	"flutterdreams/internal/story_generation/plan_module"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"flutterdreams/internal/story_generation/readability_module"
+	"flutterdreams/internal/story_generation/story_document"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/julienschmidt/httprouter"
 )
 
-// 初始化路由
+// REQUEST_ID_HEADER 请求 ID 的请求头和响应头
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// 初始化路由，认证默认启用，除 /health 和 /openapi.* 外的接口都需要 API key
 func InitRouter() *httprouter.Router {
 	router := httprouter.New()
 
 	// 设置 POST 路由
-	router.POST("/story", CreateStory)
+	router.POST("/story", authenticate(CreateStory))
 
 	// 设置 GET 路由用于心跳检查
 	router.GET("/health", HealthCheck)
+	// 接口文档，不需要认证
+	router.GET("/openapi.yaml", GetOpenAPIYAML)
+	router.GET("/openapi.json", GetOpenAPIJSON)
 
 	// 获取音频文件
-	router.GET("/getAudio", GetAudio)
+	router.GET("/getAudio", authenticate(GetAudio))
 	// 生成故事
-	router.POST("/generateStory", GenerateStory)
+	router.POST("/generateStory", authenticate(GenerateStory))
+	// 基于已有故事文档续写或生成续集
+	router.POST("/continueStory", authenticate(ContinueStory))
+	// 朗读双语对照的原文或对照文本
+	router.POST("/parallelAudio", authenticate(ParallelAudio))
+	// 导出可打印的故事（HTML 或纯文本）
+	router.POST("/exportStory", authenticate(ExportStory))
+	// 用户对故事的评分和评论，用于比较提示词实验的变体
+	router.POST("/storyFeedback", authenticate(StoryFeedback))
+	// 批量生成：提交、查询进度、下载全部结果
+	router.POST("/batches", authenticate(CreateBatch))
+	router.GET("/batches/:id", authenticate(GetBatch))
+	router.GET("/batches/:id/download", authenticate(DownloadBatch))
+	// 异步任务：提交后立即返回任务 ID，轮询状态、进度、部分结果和最终结果
+	router.POST("/jobs/generateStory", authenticate(SubmitGenerateStoryJob))
+	router.POST("/jobs/story", authenticate(SubmitStoryJob))
+	router.GET("/jobs/:id", authenticate(GetJob))
+	router.GET("/jobs/:id/result", authenticate(GetJobResult))
+	// 故事库：分页列出、查看和删除保存的故事
+	router.GET("/stories", authenticate(ListStories))
+	router.GET("/stories/:id", authenticate(GetStory))
+	router.DELETE("/stories/:id", authenticate(DeleteStory))
+	// 全文检索故事库
+	router.GET("/search", authenticate(SearchStories))
+	// 账户：当前用户的信息和 API key，管理员管理用户
+	router.GET("/me", authenticate(GetAccount))
+	router.POST("/keys", authenticate(CreateKey))
+	router.DELETE("/keys/:id", authenticate(RevokeKey))
+	router.GET("/users", requireAdmin(ListUsers))
+	router.POST("/users", requireAdmin(CreateUser))
+	// 用量与限额
+	router.GET("/usage", authenticate(GetUsage))
+	router.PUT("/users/:id/quota", requireAdmin(SetUserQuota))
+	router.PUT("/keys/:id/quota", authenticate(SetKeyQuota))
 	return router
 }
 
@@ -40,7 +91,15 @@ func CreateStory(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	err := json.NewDecoder(r.Body).Decode(&storyReq)
 	if err != nil {
 		// 这里不再调用 http.Error 直接返回，改为返回错误信息
-		logError(wr, "Invalid request body", err)
+		writeError(wr, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if err := storyReq.Validate(); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid story request", err)
+		return
+	}
+	account, ok := checkQuota(wr, r, storyDemand(&storyReq))
+	if !ok {
 		return
 	}
 
@@ -48,45 +107,98 @@ func CreateStory(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	storyService := service.NewStoryService()
 
 	// 使用 StoryService 处理故事请求并生成故事
+	meter, finish := account.start()
+	storyService.Meter = meter
 	err = storyService.ProcessStoryRequest(&storyReq, &storyResp)
+	finish(storyUsage(&storyResp))
 	if err != nil {
 		logError(wr, "Failed to process story request", err)
 		return
 	}
+	if storyResp.StoryContent != "" {
+		doc := storyResp.Document(&storyReq)
+		saveStory(ownerID(r), library_module.SOURCE_STORY, storyReq.StoryType, storyReq, doc)
+		storyResp.StoryID = doc.ID
+	}
 
 	wr.Header().Set("Content-Type", "application/json")
 	wr.WriteHeader(http.StatusOK) // 设置 HTTP 状态码为 200 OK
 
-	response := map[string]interface{}{
-		"status":       "success",
-		"message":      "Story request received successfully",
-		"title":        storyResp.StoryTitle,
-		"story":        storyResp.StoryContent,
-		"image_prompt": storyResp.ImagePrompt,
-		"audio_url":    storyResp.AudioUrl,
-		"image_url":    strings.ReplaceAll(storyResp.ImageUrl, "\n", ""),
-	}
-
 	// 将响应转换为 JSON 格式并返回
-	err = json.NewEncoder(wr).Encode(response)
+	err = json.NewEncoder(wr).Encode(createStoryResponse(&storyResp))
 	if err != nil {
 		logError(wr, "Error encoding response", err)
 	}
 }
 
+// CreateStoryResponse /story 的响应体，异步任务的结果与之相同；
+// 故事已生成而音频或图片失败时 status 为 partial，failed 列出失败的阶段，failures 给出原因
+type CreateStoryResponse struct {
+	Status        string                                `json:"status"`
+	Message       string                                `json:"message"`
+	Failed        []string                              `json:"failed"`
+	Failures      []service.StageFailure                `json:"failures"`
+	Title         string                                `json:"title"`
+	Story         string                                `json:"story"`
+	ImagePrompt   string                                `json:"image_prompt"`
+	AudioUrl      string                                `json:"audio_url"`
+	ImageUrl      string                                `json:"image_url"`
+	Readability   *readability_module.ReadabilityReport `json:"readability"`
+	Moderation    []moderation_module.Decision          `json:"moderation"`
+	Coverage      *education_module.CoverageReport      `json:"coverage"`
+	ActivitySheet *education_module.ActivitySheet       `json:"activity_sheet"`
+	Prompts       []prompt_module.Ref                   `json:"prompts"`
+	StoryID       string                                `json:"story_id"`
+}
+
+func createStoryResponse(storyResp *service.StoryResponse) CreateStoryResponse {
+	response := CreateStoryResponse{
+		Status:        "success",
+		Message:       "Story request received successfully",
+		Failed:        storyResp.Failed(),
+		Failures:      storyResp.Failures,
+		Title:         storyResp.StoryTitle,
+		Story:         storyResp.StoryContent,
+		ImagePrompt:   storyResp.ImagePrompt,
+		AudioUrl:      storyResp.AudioUrl,
+		ImageUrl:      strings.ReplaceAll(storyResp.ImageUrl, "\n", ""),
+		Readability:   storyResp.Readability,
+		Moderation:    storyResp.Moderation,
+		Coverage:      storyResp.Coverage,
+		ActivitySheet: storyResp.ActivitySheet,
+		Prompts:       storyResp.Prompts,
+		StoryID:       storyResp.StoryID,
+	}
+	if len(storyResp.Failures) > 0 {
+		response.Status, response.Message = "partial", "Story generated, but some stages failed"
+	}
+	return response
+}
+
+// HealthResponse 心跳检查的响应体
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// MessageResponse 只有状态和说明的响应体
+type MessageResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
 // 处理 GET 请求的心跳检查
 func HealthCheck(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	// 返回一个简单的 JSON 响应，表示服务正常
 	wr.Header().Set("Content-Type", "application/json")
 	wr.WriteHeader(http.StatusOK)
-	json.NewEncoder(wr).Encode(map[string]string{"status": "healthy"})
+	json.NewEncoder(wr).Encode(HealthResponse{Status: "healthy"})
 }
 
 func GetAudio(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	// 获取请求中的文件名参数
 	fileName := r.URL.Query().Get("filename")
 	if fileName == "" {
-		logError(wr, "Missing filename parameter", fmt.Errorf("filename is required"))
+		writeError(wr, http.StatusBadRequest, "Missing filename parameter", fmt.Errorf("filename is required"))
 		return
 	}
 
@@ -95,7 +207,12 @@ func GetAudio(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 
 	// 检查文件是否存在
 	if _, err := os.Stat(audioFilePath); os.IsNotExist(err) {
-		logError(wr, "File not found", err)
+		writeError(wr, http.StatusNotFound, "File not found", err)
+		return
+	}
+	// 启用认证时只能获取自己的音频
+	if !canAccessAudio(r, audioFilePath) {
+		writeError(wr, http.StatusNotFound, "File not found", fmt.Errorf("%s is not owned by the current user", fileName))
 		return
 	}
 
@@ -116,49 +233,161 @@ func getAudioFilePath(fileName string) string {
 	if !strings.HasSuffix(fileName, ".mp3") {
 		fileName += ".mp3" // 默认添加 .mp3 后缀
 	}
-	return filepath.Join("audio", fileName)
+	// 只取文件名，避免通过 ../ 访问或删除 audio 目录之外的文件
+	return filepath.Join(service.AUDIO_DIR, filepath.Base(fileName))
 }
 
-// 记录日志并返回错误信息
-func logError(wr http.ResponseWriter, message string, err error) {
-	log.Printf("%s: %v", message, err)
-	http.Error(wr, fmt.Sprintf("%s: %v", message, err), http.StatusInternalServerError)
+// 请求 ID 取自 X-Request-ID 请求头，没有时生成一个，并在响应头中返回；
+// 提示词实验按请求 ID 分配变体，客户端重试时带上同一个 ID 即可得到相同的变体
+func requestID(wr http.ResponseWriter, r *http.Request) string {
+	id := strings.TrimSpace(r.Header.Get(REQUEST_ID_HEADER))
+	if id == "" {
+		id = story_document.NewID()
+	}
+	wr.Header().Set(REQUEST_ID_HEADER, id)
+	return id
 }
 
 // StoryGenerateRequest 定义请求体结构
 type StoryGenerateRequest struct {
 	Premise string `json:"premise"`
+	// 目标字数与朗读分钟数二选一，都不填时使用默认长度
+	TargetLength   int     `json:"target_length"`
+	ReadingMinutes float64 `json:"reading_minutes"`
+	// 儿童年龄段，如 3-5岁，用于调整角色数量、大纲段落数、用词和故事长度
+	ChildAgeGroup string `json:"child_age_group"`
+	// 教学模式：学习目标与目标词汇
+	EducationalGoals []string `json:"educational_goals"`
+	Vocabulary       []string `json:"vocabulary"`
+	// 是否生成配套学习单
+	ActivitySheet bool `json:"activity_sheet"`
+	// 分支模式：返回带选择的故事图
+	Branching bool `json:"branching"`
+	// 故事语言：zh-Hans（默认）、zh-Hant 或 en；英文的目标长度按词数计
+	Language string `json:"language"`
+	// 双语对照：对照语言（zh-Hans、zh-Hant、en）或 pinyin，为空时不生成
+	BilingualTarget string `json:"bilingual_target"`
+	// 是否逐字标注拼音，只适用于中文故事
+	Pinyin bool `json:"pinyin"`
+}
+
+// 逐个字段校验请求参数，返回 *common.ValidationError 列出全部无效的字段
+func (req *StoryGenerateRequest) validate() error {
+	errs := &common.ValidationError{}
+	// 验证premise不为空
+	if strings.TrimSpace(req.Premise) == "" {
+		errs.Add("premise", "is required")
+	} else if length := utf8.RuneCountInString(req.Premise); length > service.MAX_STORY_CONTENT_LENGTH {
+		errs.Add("premise", "must be at most %d characters, got %d", service.MAX_STORY_CONTENT_LENGTH, length)
+	}
+	common.ValidateTargetLength(errs, req.TargetLength, req.ReadingMinutes)
+	if req.ChildAgeGroup != "" && !common.IsValidAgeGroup(req.ChildAgeGroup) {
+		errs.Add("child_age_group", "must be an age range such as 3-5")
+	}
+	if len(req.EducationalGoals) > service.MAX_GOALS {
+		errs.Add("educational_goals", "must have at most %d items", service.MAX_GOALS)
+	}
+	if len(req.Vocabulary) > service.MAX_GOALS {
+		errs.Add("vocabulary", "must have at most %d items", service.MAX_GOALS)
+	}
+	if !common.IsSupportedLanguage(req.Language) {
+		errs.Add("language", "must be one of zh-Hans, zh-Hant, en")
+		return errs.Err()
+	}
+	language := common.GetLanguage(req.Language)
+	if req.BilingualTarget != "" {
+		if err := bilingual_module.ValidateTarget(language.Code, req.BilingualTarget); err != nil {
+			errs.Add("bilingual_target", "%v", err)
+		}
+	}
+	if req.Pinyin && !language.IsChinese() {
+		errs.Add("pinyin", "is only available for Chinese stories")
+	}
+	return errs.Err()
+}
+
+// 转换为生成故事的参数，调用前先通过 validate 校验
+func (req *StoryGenerateRequest) storyOptions(requestID string) story_generation.StoryOptions {
+	language := common.GetLanguage(req.Language)
+	return story_generation.StoryOptions{
+		TargetLength:    language.ResolveTargetLength(req.TargetLength, req.ReadingMinutes, 0),
+		AgeGroup:        req.ChildAgeGroup,
+		Goals:           common.NewEducationGoals(req.EducationalGoals, req.Vocabulary),
+		ActivitySheet:   req.ActivitySheet,
+		Branching:       req.Branching,
+		Language:        language.Code,
+		BilingualTarget: req.BilingualTarget,
+		Pinyin:          req.Pinyin,
+		RequestID:       requestID,
+	}
 }
 
 // StoryGenerateResponse 定义响应体结构
 type StoryGenerateResponse struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
-	Story   string `json:"story"`
+	Status     string                       `json:"status"`
+	Message    string                       `json:"message"`
+	Story      string                       `json:"story"`
+	Moderation []moderation_module.Decision `json:"moderation,omitempty"`
+	// 教学目标覆盖报告
+	Coverage *education_module.CoverageReport `json:"coverage,omitempty"`
+	// 配套学习单
+	ActivitySheet *education_module.ActivitySheet `json:"activity_sheet,omitempty"`
+	// 分支故事图，前端从 root 节点开始展示内容和选择，story 为主线路径的文本
+	Graph *common.StoryGraph `json:"graph,omitempty"`
+	// 逐句对齐的双语对照
+	ParallelText *bilingual_module.ParallelText `json:"parallel_text,omitempty"`
+	// 逐字拼音标注
+	Pinyin *pinyin_module.Annotation `json:"pinyin,omitempty"`
+	// 完整的故事文档，可用于 /continueStory 续写
+	Document *story_document.StoryDocument `json:"document,omitempty"`
+}
+
+// /generateStory 的响应体，异步任务的结果与之相同
+func newStoryGenerateResponse(doc *story_document.StoryDocument) StoryGenerateResponse {
+	return StoryGenerateResponse{
+		Status:        "success",
+		Message:       "Story generated successfully",
+		Story:         doc.FinalText,
+		Moderation:    doc.Moderation,
+		Coverage:      doc.Coverage,
+		ActivitySheet: doc.ActivitySheet,
+		Graph:         doc.Graph,
+		ParallelText:  doc.ParallelText,
+		Pinyin:        doc.Pinyin,
+		Document:      doc,
+	}
 }
 
 func GenerateStory(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	// 解析请求体
 	var req StoryGenerateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logError(wr, "Invalid request body", err)
+		writeError(wr, http.StatusBadRequest, "Invalid request body", err)
 		return
 	}
 
-	// 验证premise不为空
-	if req.Premise == "" {
-		logError(wr, "Missing premise", fmt.Errorf("premise is required"))
+	if err := req.validate(); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid story request", err)
+		return
+	}
+	account, ok := checkQuota(wr, r, pipelineDemand())
+	if !ok {
 		return
 	}
 
 	// 调用 plan_module 生成故事计划
-	story := story_generation.GenerateStory(req.Premise)
-	// 构造响应
-	response := StoryGenerateResponse{
-		Status:  "success",
-		Message: "Story generated successfully",
-		Story:   story,
+	options := req.storyOptions(requestID(wr, r))
+	meter, finish := account.start()
+	options.Meter = meter
+	doc, err := story_generation.GenerateStoryDocument(req.Premise, options)
+	finish(storyCount(err))
+	if err != nil {
+		logError(wr, "Failed to generate story", err)
+		return
 	}
+	saveStory(ownerID(r), library_module.SOURCE_GENERATE_STORY, "", req, doc)
+	// 构造响应
+	response := newStoryGenerateResponse(doc)
 
 	// 设置响应头
 	wr.Header().Set("Content-Type", "application/json")
@@ -170,3 +399,276 @@ func GenerateStory(wr http.ResponseWriter, r *http.Request, _ httprouter.Params)
 		return
 	}
 }
+
+// StoryContinueRequest 续写请求体，document 为之前生成的故事文档
+type StoryContinueRequest struct {
+	Document json.RawMessage `json:"document"`
+	// continuation 续写或 sequel 续集，默认为续写
+	Mode string `json:"mode"`
+	// 续集的故事前提，为空时自动构思
+	Premise string `json:"premise"`
+	// 新增内容的目标字数与朗读分钟数，二选一
+	TargetLength   int     `json:"target_length"`
+	ReadingMinutes float64 `json:"reading_minutes"`
+	ActivitySheet  bool    `json:"activity_sheet"`
+}
+
+// StoryContinueResponse 续写响应体，document 为新的故事文档，可再次用于续写
+type StoryContinueResponse struct {
+	Status     string                        `json:"status"`
+	Message    string                        `json:"message"`
+	Story      string                        `json:"story"`
+	Document   *story_document.StoryDocument `json:"document"`
+	Moderation []moderation_module.Decision  `json:"moderation,omitempty"`
+}
+
+// 逐个字段校验请求参数，故事文档由 story_document 解析
+func (req *StoryContinueRequest) validate() error {
+	errs := &common.ValidationError{}
+	if len(req.Document) == 0 {
+		errs.Add("document", "is required")
+	}
+	switch req.Mode {
+	case "", story_generation.CONTINUE_MODE_CONTINUATION, story_generation.CONTINUE_MODE_SEQUEL:
+	default:
+		errs.Add("mode", "must be continuation or sequel")
+	}
+	if length := utf8.RuneCountInString(req.Premise); length > service.MAX_STORY_CONTENT_LENGTH {
+		errs.Add("premise", "must be at most %d characters, got %d", service.MAX_STORY_CONTENT_LENGTH, length)
+	}
+	common.ValidateTargetLength(errs, req.TargetLength, req.ReadingMinutes)
+	return errs.Err()
+}
+
+func ContinueStory(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req StoryContinueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid continue request", err)
+		return
+	}
+
+	// 通过 story_document 解析，旧版本的文档会自动迁移
+	previous, err := story_document.Unmarshal(req.Document, story_document.FORMAT_JSON)
+	if err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid story document", err)
+		return
+	}
+	account, ok := checkQuota(wr, r, pipelineDemand())
+	if !ok {
+		return
+	}
+
+	meter, finish := account.start()
+	doc, err := story_generation.ContinueStoryDocument(previous, story_generation.ContinueOptions{
+		Mode:          req.Mode,
+		Premise:       req.Premise,
+		TargetLength:  common.GetLanguage(previous.Language).ResolveTargetLength(req.TargetLength, req.ReadingMinutes, 0),
+		ActivitySheet: req.ActivitySheet,
+		RequestID:     requestID(wr, r),
+		Meter:         meter,
+	})
+	finish(storyCount(err))
+	if err != nil {
+		logError(wr, "Failed to continue story", err)
+		return
+	}
+	// 原故事文档已经保存在新文档的来源中，请求参数中不再重复保存
+	saved := req
+	saved.Document = nil
+	saveStory(ownerID(r), library_module.SOURCE_CONTINUE_STORY, "", saved, doc)
+
+	response := StoryContinueResponse{
+		Status:     "success",
+		Message:    "Story continued successfully",
+		Story:      doc.FinalText,
+		Document:   doc,
+		Moderation: doc.Moderation,
+	}
+	wr.Header().Set("Content-Type", "application/json")
+	wr.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(wr).Encode(response); err != nil {
+		logError(wr, "Error encoding response", err)
+		return
+	}
+}
+
+// ParallelAudioRequest 双语朗读请求体
+type ParallelAudioRequest struct {
+	// 带双语对照的故事文档
+	Document json.RawMessage `json:"document"`
+	// source 朗读原文，target 朗读对照文本（拼音对照时朗读原文）
+	Side string `json:"side"`
+	// 有道 TTS 音色，为空时使用默认音色
+	Voice string `json:"voice"`
+}
+
+// ParallelAudioResponse 双语朗读响应体
+type ParallelAudioResponse struct {
+	Status   string `json:"status"`
+	Side     string `json:"side"`
+	AudioUrl string `json:"audio_url"`
+}
+
+func ParallelAudio(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req ParallelAudioRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if req.Side == "" {
+		req.Side = bilingual_module.SIDE_SOURCE
+	}
+	if req.Side != bilingual_module.SIDE_SOURCE && req.Side != bilingual_module.SIDE_TARGET {
+		writeError(wr, http.StatusBadRequest, "Invalid side", fmt.Errorf("side must be source or target"))
+		return
+	}
+	if req.Voice == "" {
+		req.Voice = plan_module.CHARACTER_VOICES[0]
+	}
+
+	doc, err := story_document.Unmarshal(req.Document, story_document.FORMAT_JSON)
+	if err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid story document", err)
+		return
+	}
+	if doc.ParallelText == nil {
+		writeError(wr, http.StatusBadRequest, "Missing parallel text", fmt.Errorf("document has no parallel_text"))
+		return
+	}
+
+	text := doc.ParallelText.SpeechText(req.Side)
+	seconds := common.EstimateAudioSeconds(common.TruncateBytes(text, service.MAX_TTS_TEXT_BYTES))
+	account, ok := checkQuota(wr, r, library_module.Usage{AudioSeconds: seconds})
+	if !ok {
+		return
+	}
+	audioUrl, err := service.GenerateAudio(text, req.Voice)
+	if err != nil {
+		account.cancel()
+		logError(wr, "Failed to generate audio", err)
+		return
+	}
+	account.record(library_module.Usage{AudioSeconds: seconds})
+	recordAudioOwner(ownerID(r), audioUrl)
+
+	wr.Header().Set("Content-Type", "application/json")
+	wr.WriteHeader(http.StatusOK)
+	json.NewEncoder(wr).Encode(ParallelAudioResponse{
+		Status:   "success",
+		Side:     req.Side,
+		AudioUrl: audioUrl,
+	})
+}
+
+// ExportStoryRequest 导出请求体
+type ExportStoryRequest struct {
+	Document json.RawMessage `json:"document"`
+	// html（默认）或 text
+	Format string `json:"format"`
+	// 导出 HTML 时是否显示拼音，文档没有拼音标注时现场标注，只适用于中文故事
+	Pinyin bool `json:"pinyin"`
+}
+
+func ExportStory(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req ExportStoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	doc, err := story_document.Unmarshal(req.Document, story_document.FORMAT_JSON)
+	if err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid story document", err)
+		return
+	}
+
+	switch req.Format {
+	case "", "html":
+		if !req.Pinyin {
+			doc.Pinyin = nil
+		} else if doc.Pinyin == nil {
+			if !common.GetLanguage(doc.Language).IsChinese() {
+				writeError(wr, http.StatusBadRequest, "Invalid pinyin option", fmt.Errorf("pinyin is only available for Chinese stories"))
+				return
+			}
+			meter, finish := accountOf(r).start()
+			doc.Pinyin = pinyin_module.Annotate(doc.FinalText, meter)
+			finish(library_module.Usage{})
+		}
+		content, err := doc.ExportHTML()
+		if err != nil {
+			logError(wr, "Failed to export story", err)
+			return
+		}
+		wr.Header().Set("Content-Type", "text/html; charset=utf-8")
+		wr.WriteHeader(http.StatusOK)
+		wr.Write([]byte(content))
+	case "text":
+		wr.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		wr.WriteHeader(http.StatusOK)
+		wr.Write([]byte(doc.ExportText()))
+	default:
+		writeError(wr, http.StatusBadRequest, "Invalid format", fmt.Errorf("format must be html or text"))
+	}
+}
+
+// StoryFeedbackRequest 用户反馈请求体
+type StoryFeedbackRequest struct {
+	// 故事文档的 ID
+	StoryID string `json:"story_id"`
+	// 1-5 分
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment"`
+}
+
+func StoryFeedback(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req StoryFeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	errs := &common.ValidationError{}
+	if req.StoryID == "" {
+		errs.Add("story_id", "is required")
+	}
+	if req.Rating < experiment_module.MIN_RATING || req.Rating > experiment_module.MAX_RATING {
+		errs.Add("rating", "must be between %d and %d", experiment_module.MIN_RATING, experiment_module.MAX_RATING)
+	}
+	if err := errs.Err(); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid feedback", err)
+		return
+	}
+	// 只能评价故事库中自己可以访问的故事
+	store, ok := libraryStore(wr)
+	if !ok {
+		return
+	}
+	story, err := store.Get(req.StoryID)
+	if err == nil && !canAccess(r, story.OwnerID) {
+		err = library_module.ErrNotFound
+	}
+	if errors.Is(err, library_module.ErrNotFound) {
+		writeError(wr, http.StatusNotFound, "Story not found", err)
+		return
+	}
+	if err != nil {
+		logError(wr, "Failed to read story", err)
+		return
+	}
+	if err := experiment_module.RecordFeedback(req.StoryID, req.Rating, req.Comment); err != nil {
+		logError(wr, "Failed to record feedback", err)
+		return
+	}
+
+	wr.Header().Set("Content-Type", "application/json")
+	wr.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(wr).Encode(MessageResponse{
+		Status:  "success",
+		Message: "Feedback recorded successfully",
+	}); err != nil {
+		logError(wr, "Error encoding response", err)
+	}
+}
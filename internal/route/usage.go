@@ -0,0 +1,413 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"flutterdreams/internal/service"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/library_module"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// UsageReport 一个用户或 API key 的限额及当天、当月的用量
+type UsageReport struct {
+	Quota library_module.Quota `json:"quota"`
+	Today library_module.Usage `json:"today"`
+	Month library_module.Usage `json:"month"`
+	// 已用完的各项限额，再次请求时返回 429
+	Exhausted []string `json:"exhausted,omitempty"`
+}
+
+// 统计 userID（keyID 不为空时为该 key）在 now 当天和当月的用量
+func usageReport(accounts library_module.AccountStore, userID string, keyID string, quota library_module.Quota, now time.Time) (UsageReport, error) {
+	report := UsageReport{Quota: quota}
+	var err error
+	if report.Today, err = accounts.UsageSince(userID, keyID, library_module.StartOfDay(now)); err != nil {
+		return report, err
+	}
+	if report.Month, err = accounts.UsageSince(userID, keyID, library_module.StartOfMonth(now)); err != nil {
+		return report, err
+	}
+	report.Exhausted = quota.Exceeded(report.Today, report.Month, library_module.Usage{Stories: 1, AudioSeconds: 1, Images: 1})
+	return report, nil
+}
+
+// 已通过限额检查、尚未记录实际用量的请求占用的用量，按 pendingKey 累计。
+// 检查限额时计入，避免同时提交或排队中的请求绕过限额；锁在检查和占用期间一直持有
+var (
+	pendingMu sync.Mutex
+	pending   = map[string]library_module.Usage{}
+)
+
+// 用户（keyID 为空）或 API key 在 pending 中的键
+func pendingKey(userID string, keyID string) string {
+	if keyID != "" {
+		return "key:" + keyID
+	}
+	return "user:" + userID
+}
+
+func addUsage(a library_module.Usage, b library_module.Usage, sign int) library_module.Usage {
+	a.Stories += sign * b.Stories
+	a.PromptTokens += sign * b.PromptTokens
+	a.CompletionTokens += sign * b.CompletionTokens
+	a.AudioSeconds += sign * b.AudioSeconds
+	a.Images += sign * b.Images
+	return a
+}
+
+// 执行生成前检查用户及其 API key 的限额，demand 为本次请求将产生的用量（见 Quota.Exceeded）；超出时返回 429。
+// 通过时占用 demand，直到返回的 usageAccount 记录实际用量或调用 cancel
+func checkQuota(wr http.ResponseWriter, r *http.Request, demand library_module.Usage) (usageAccount, bool) {
+	return reserveQuota(wr, r, demand, 1)
+}
+
+// reserveQuota 检查 runs 次生成（如批次中的各项）的限额，每次生成将产生 each 的用量，
+// 每记录一次实际用量释放一次生成占用的用量
+func reserveQuota(wr http.ResponseWriter, r *http.Request, each library_module.Usage, runs int) (usageAccount, bool) {
+	account := accountOf(r)
+	user, key := currentUser(r), currentKey(r)
+	if user == nil {
+		return account, true
+	}
+	var demand library_module.Usage
+	for i := 0; i < runs; i++ {
+		demand = addUsage(demand, each, 1)
+	}
+
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	accounts := library_module.Accounts()
+	now := time.Now()
+	var exceeded []string
+	for _, owner := range []struct {
+		keyID string
+		quota library_module.Quota
+	}{{"", user.Quota}, {key.ID, key.Quota}} {
+		if owner.quota == (library_module.Quota{}) {
+			continue
+		}
+		report, err := usageReport(accounts, user.ID, owner.keyID, owner.quota, now)
+		if err != nil {
+			logError(wr, "Failed to check quota", err)
+			return account, false
+		}
+		held := pending[pendingKey(user.ID, owner.keyID)]
+		today, month := addUsage(report.Today, held, 1), addUsage(report.Month, held, 1)
+		for _, name := range owner.quota.Exceeded(today, month, demand) {
+			if owner.keyID != "" {
+				name = "key " + name
+			}
+			exceeded = append(exceeded, name)
+		}
+	}
+	if len(exceeded) > 0 {
+		writeError(wr, http.StatusTooManyRequests, "Quota exceeded", errors.New(strings.Join(exceeded, ", ")))
+		return account, false
+	}
+
+	account.reserved = &reservation{keys: []string{pendingKey(user.ID, ""), pendingKey(user.ID, key.ID)}, each: each, runs: runs}
+	for _, k := range account.reserved.keys {
+		pending[k] = addUsage(pending[k], demand, 1)
+	}
+	return account, true
+}
+
+// 一个请求占用的用量
+type reservation struct {
+	keys []string
+	each library_module.Usage
+	// 尚未释放的生成次数
+	runs int
+}
+
+// 释放 runs 次生成占用的用量，runs 超过剩余次数时只释放剩余的部分；可以对 nil 调用
+func (r *reservation) release(runs int) {
+	if r == nil {
+		return
+	}
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	if runs > r.runs {
+		runs = r.runs
+	}
+	r.runs -= runs
+	for i := 0; i < runs; i++ {
+		for _, k := range r.keys {
+			if pending[k] = addUsage(pending[k], r.each, -1); pending[k].Empty() {
+				delete(pending, k)
+			}
+		}
+	}
+}
+
+// 用量记到发起请求的用户和 API key 名下；未启用认证时不记录
+type usageAccount struct {
+	userID string
+	keyID  string
+	// 检查限额时占用的用量，记录实际用量后释放
+	reserved *reservation
+}
+
+func accountOf(r *http.Request) usageAccount {
+	user, key := currentUser(r), currentKey(r)
+	if user == nil {
+		return usageAccount{}
+	}
+	return usageAccount{userID: user.ID, keyID: key.ID}
+}
+
+// start 开始计量模型 token 数：返回的 Meter 传给本次生成，只有经由它的模型调用才计入；
+// 生成结束（无论成功与否）后调用返回的函数，传入生成的故事数、音频时长和图片数，与计量到的 token 数一起记录。
+// 任务和批次在 worker 中运行，需要在运行时调用 start
+func (a usageAccount) start() (*common.Meter, func(media library_module.Usage)) {
+	if a.userID == "" {
+		return nil, func(library_module.Usage) {}
+	}
+	meter := common.NewMeter()
+	return meter, func(media library_module.Usage) {
+		tokens := meter.Usage()
+		media.PromptTokens += tokens.PromptTokens
+		media.CompletionTokens += tokens.CompletionTokens
+		a.record(media)
+	}
+}
+
+// record 记录一次生成的实际用量，并释放这次生成占用的用量；也用于不需要计量 token 的用量，如单独合成的音频
+func (a usageAccount) record(usage library_module.Usage) {
+	defer a.reserved.release(1)
+	accounts := library_module.Accounts()
+	if a.userID == "" || accounts == nil || usage.Empty() {
+		return
+	}
+	if err := accounts.RecordUsage(a.userID, a.keyID, time.Now(), usage); err != nil {
+		log.Printf("记录用户 %s 的用量时发生错误: %v", a.userID, err)
+	}
+}
+
+// cancel 请求没有执行（如提交任务失败）时释放全部占用的用量
+func (a usageAccount) cancel() {
+	a.reserved.release(math.MaxInt)
+}
+
+// 生成一个故事预计消耗的 token 数，生成前按此占用 tokens_per_month 限额，记录实际用量后释放。
+// 实际用量可能超出预估，因此限额最多被正在执行的请求超出各自的差额
+const (
+	PIPELINE_STORY_TOKENS = 30000 // 完整生成流程、续写和续集：计划、逐段草稿、打分、编辑和审核
+	QUICK_STORY_TOKENS    = 5000  // /story 的快速流程：一次生成和审核
+)
+
+// 完整生成流程将产生的用量，用于生成前检查限额
+func pipelineDemand() library_module.Usage {
+	return library_module.Usage{Stories: 1, PromptTokens: PIPELINE_STORY_TOKENS}
+}
+
+// /story 将产生的用量，用于生成前检查限额；音频时长在生成前未知，只检查是否已用完
+func storyDemand(req *service.StoryRequest) library_module.Usage {
+	demand := library_module.Usage{Stories: 1, PromptTokens: QUICK_STORY_TOKENS, Images: 1}
+	if req.CharacterChoice != "" {
+		demand.AudioSeconds = 1
+	}
+	return demand
+}
+
+// /story 实际产生的故事、音频和图片用量
+func storyUsage(resp *service.StoryResponse) library_module.Usage {
+	var usage library_module.Usage
+	if resp.StoryContent != "" {
+		usage.Stories = 1
+	}
+	if resp.AudioUrl != "" {
+		usage.AudioSeconds = common.EstimateAudioSeconds(common.TruncateBytes(resp.StoryContent, service.MAX_TTS_TEXT_BYTES))
+	}
+	if resp.ImageUrl != "" {
+		usage.Images = 1
+	}
+	return usage
+}
+
+// 生成成功时计一个故事
+func storyCount(err error) library_module.Usage {
+	if err != nil {
+		return library_module.Usage{}
+	}
+	return library_module.Usage{Stories: 1}
+}
+
+// UsageResponse 用户及当前 API key 的限额和用量
+type UsageResponse struct {
+	Status string       `json:"status"`
+	UserID string       `json:"user_id"`
+	User   UsageReport  `json:"user"`
+	Key    *UsageReport `json:"key,omitempty"`
+}
+
+// GetUsage 当前用户及当前 API key 的用量与限额；管理员可以用 user_id 查询其他用户
+func GetUsage(wr http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	accounts, user, ok := accountStore(wr, r)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	response := UsageResponse{Status: "success", UserID: user.ID}
+	if id := r.URL.Query().Get("user_id"); id != "" && id != user.ID {
+		if !user.Admin {
+			writeError(wr, http.StatusForbidden, "Admin required", fmt.Errorf("only admins can view other users' usage"))
+			return
+		}
+		other, err := accounts.GetUser(id)
+		if errors.Is(err, library_module.ErrNotFound) {
+			writeError(wr, http.StatusNotFound, "User not found", err)
+			return
+		}
+		if err != nil {
+			logError(wr, "Failed to read user", err)
+			return
+		}
+		user = other
+		response.UserID = other.ID
+	} else {
+		key := currentKey(r)
+		report, err := usageReport(accounts, user.ID, key.ID, key.Quota, now)
+		if err != nil {
+			logError(wr, "Failed to read usage", err)
+			return
+		}
+		response.Key = &report
+	}
+	var err error
+	if response.User, err = usageReport(accounts, user.ID, "", user.Quota, now); err != nil {
+		logError(wr, "Failed to read usage", err)
+		return
+	}
+	writeJSON(wr, http.StatusOK, response)
+}
+
+// 解析限额请求体，各项不能为负数
+func decodeQuota(wr http.ResponseWriter, r *http.Request) (library_module.Quota, bool) {
+	var quota library_module.Quota
+	if err := json.NewDecoder(r.Body).Decode(&quota); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid request body", err)
+		return quota, false
+	}
+	if err := validateQuota(quota); err != nil {
+		writeError(wr, http.StatusBadRequest, "Invalid quota", err)
+		return quota, false
+	}
+	return quota, true
+}
+
+func validateQuota(quota library_module.Quota) error {
+	if quota.StoriesPerDay < 0 || quota.TokensPerMonth < 0 || quota.AudioMinutesPerMonth < 0 || quota.ImagesPerMonth < 0 {
+		return fmt.Errorf("quota limits must be non-negative integers")
+	}
+	return nil
+}
+
+// QuotaResponse 修改后的限额
+type QuotaResponse struct {
+	Status string               `json:"status"`
+	Quota  library_module.Quota `json:"quota"`
+}
+
+// SetUserQuota 修改用户的限额，只有管理员可以调用
+func SetUserQuota(wr http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	accounts, _, ok := accountStore(wr, r)
+	if !ok {
+		return
+	}
+	quota, ok := decodeQuota(wr, r)
+	if !ok {
+		return
+	}
+	err := accounts.SetQuota(params.ByName("id"), quota)
+	if errors.Is(err, library_module.ErrNotFound) {
+		writeError(wr, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		logError(wr, "Failed to update quota", err)
+		return
+	}
+	writeJSON(wr, http.StatusOK, QuotaResponse{Status: "success", Quota: quota})
+}
+
+// SetKeyQuota 修改 API key 的限额，用户可以为自己的 key 设置更低的限额，管理员可以修改任何 key。
+// 普通用户的新限额各项都不能高于 key 当前的限额（key 不限时为用户的限额），0 表示不限，高于任何限额
+func SetKeyQuota(wr http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	accounts, _, ok := accountStore(wr, r)
+	if !ok {
+		return
+	}
+	quota, ok := decodeQuota(wr, r)
+	if !ok {
+		return
+	}
+	key, err := accounts.GetKey(params.ByName("id"))
+	if err == nil && !canAccess(r, key.UserID) {
+		err = library_module.ErrNotFound
+	}
+	if errors.Is(err, library_module.ErrNotFound) {
+		writeError(wr, http.StatusNotFound, "API key not found", err)
+		return
+	}
+	if err != nil {
+		logError(wr, "Failed to read API key", err)
+		return
+	}
+	if user := currentUser(r); !user.Admin {
+		if raised := raisedLimits(quota, effectiveQuota(key.Quota, user.Quota)); len(raised) > 0 {
+			writeError(wr, http.StatusForbidden, "Quota exceeds current limit", fmt.Errorf("only admins can raise key quota: %s", strings.Join(raised, ", ")))
+			return
+		}
+	}
+	if err := accounts.SetKeyQuota(key.ID, quota); err != nil {
+		logError(wr, "Failed to update quota", err)
+		return
+	}
+	writeJSON(wr, http.StatusOK, QuotaResponse{Status: "success", Quota: quota})
+}
+
+// key 实际受到的各项限额：key 不限的项由用户的限额约束
+func effectiveQuota(key library_module.Quota, user library_module.Quota) library_module.Quota {
+	pick := func(keyLimit int, userLimit int) int {
+		if keyLimit == 0 {
+			return userLimit
+		}
+		return keyLimit
+	}
+	return library_module.Quota{
+		StoriesPerDay:        pick(key.StoriesPerDay, user.StoriesPerDay),
+		TokensPerMonth:       pick(key.TokensPerMonth, user.TokensPerMonth),
+		AudioMinutesPerMonth: pick(key.AudioMinutesPerMonth, user.AudioMinutesPerMonth),
+		ImagesPerMonth:       pick(key.ImagesPerMonth, user.ImagesPerMonth),
+	}
+}
+
+// 返回 quota 中高于 limit 的各项名称，0 表示不限，高于任何限额
+func raisedLimits(quota library_module.Quota, limit library_module.Quota) []string {
+	var raised []string
+	for _, item := range []struct {
+		name  string
+		value int
+		limit int
+	}{
+		{library_module.QUOTA_STORIES_PER_DAY, quota.StoriesPerDay, limit.StoriesPerDay},
+		{library_module.QUOTA_TOKENS_PER_MONTH, quota.TokensPerMonth, limit.TokensPerMonth},
+		{library_module.QUOTA_AUDIO_MINUTES_PER_MONTH, quota.AudioMinutesPerMonth, limit.AudioMinutesPerMonth},
+		{library_module.QUOTA_IMAGES_PER_MONTH, quota.ImagesPerMonth, limit.ImagesPerMonth},
+	} {
+		if item.limit > 0 && (item.value == 0 || item.value > item.limit) {
+			raised = append(raised, item.name)
+		}
+	}
+	return raised
+}
@@ -0,0 +1,111 @@
+package route
+
+import (
+	"context"
+	"flutterdreams/internal/story_generation/library_module"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 已通过检查但尚未记录用量的请求占用限额，释放后才能再次提交
+func TestQuotaReservation(t *testing.T) {
+	setupAuth(t)
+	accounts := library_module.Accounts()
+	user, err := accounts.CreateUser("carol", false, library_module.Quota{StoriesPerDay: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _, err := accounts.CreateKey(user.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest("POST", "/generateStory", nil)
+		return r.WithContext(context.WithValue(r.Context(), principalContextKey, principal{user: user, key: key}))
+	}
+	check := func(runs int) (usageAccount, int) {
+		wr := httptest.NewRecorder()
+		account, ok := reserveQuota(wr, newRequest(), library_module.Usage{Stories: 1}, runs)
+		if ok {
+			return account, http.StatusOK
+		}
+		return account, wr.Code
+	}
+
+	first, code := check(1)
+	if code != http.StatusOK {
+		t.Fatalf("第一次请求应通过: %d", code)
+	}
+	second, code := check(1)
+	if code != http.StatusOK {
+		t.Fatalf("第二次请求应通过: %d", code)
+	}
+	if _, code := check(1); code != http.StatusTooManyRequests {
+		t.Errorf("两个请求尚未完成，第三次应返回 429: %d", code)
+	}
+
+	// 失败的生成不计故事数，释放后可以再次提交
+	_, finish := first.start()
+	finish(library_module.Usage{})
+	if _, code := check(1); code != http.StatusOK {
+		t.Errorf("释放后应通过: %d", code)
+	}
+	second.cancel()
+	second.cancel()
+	if pending[pendingKey(user.ID, "")].Stories != 1 {
+		t.Errorf("重复释放不应影响其他请求: %+v", pending)
+	}
+
+	// 批次按项数占用
+	if _, code := check(2); code != http.StatusTooManyRequests {
+		t.Errorf("批次超出限额应返回 429: %d", code)
+	}
+}
+
+// 生成前按预估的 token 数占用 tokens_per_month 限额，记录实际用量后释放
+func TestTokenReservation(t *testing.T) {
+	setupAuth(t)
+	accounts := library_module.Accounts()
+	user, err := accounts.CreateUser("dave", false, library_module.Quota{TokensPerMonth: 2*PIPELINE_STORY_TOKENS + 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _, err := accounts.CreateKey(user.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(runs int) (usageAccount, int) {
+		r := httptest.NewRequest("POST", "/generateStory", nil)
+		r = r.WithContext(context.WithValue(r.Context(), principalContextKey, principal{user: user, key: key}))
+		wr := httptest.NewRecorder()
+		account, ok := reserveQuota(wr, r, pipelineDemand(), runs)
+		if ok {
+			return account, http.StatusOK
+		}
+		return account, wr.Code
+	}
+
+	if _, code := check(3); code != http.StatusTooManyRequests {
+		t.Errorf("批次预估的 token 数超出限额应返回 429: %d", code)
+	}
+	first, code := check(1)
+	if code != http.StatusOK {
+		t.Fatalf("第一次请求应通过: %d", code)
+	}
+	if _, code := check(1); code != http.StatusOK {
+		t.Fatalf("第二次请求应通过: %d", code)
+	}
+	if _, code := check(1); code != http.StatusTooManyRequests {
+		t.Errorf("两个请求占用了预估的 token 数，第三次应返回 429: %d", code)
+	}
+
+	// 记录实际用量后释放占用，剩余的限额按实际用量计算
+	first.record(library_module.Usage{Stories: 1, PromptTokens: 500, CompletionTokens: 500})
+	if _, code := check(1); code != http.StatusOK {
+		t.Errorf("释放后应通过: %d", code)
+	}
+	if held := pending[pendingKey(user.ID, "")]; held.Tokens() != 2*PIPELINE_STORY_TOKENS {
+		t.Errorf("占用的 token 数错误: %+v", held)
+	}
+}
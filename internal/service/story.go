@@ -1,11 +1,23 @@
 package service
 
 import (
+	"errors"
 	"flutterdreams/config"
 	"flutterdreams/internal/model"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/edit_module"
+	"flutterdreams/internal/story_generation/education_module"
+	"flutterdreams/internal/story_generation/moderation_module"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"flutterdreams/internal/story_generation/readability_module"
+	"flutterdreams/internal/story_generation/story_document"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // 用于接收客户端发送的 JSON 数据
@@ -15,18 +27,156 @@ type StoryRequest struct {
 	StoryType       string `json:"story_type"`
 	ImageType       string `json:"image_type"`
 	ChildAgeGroup   string `json:"child_age_group"`
+	// 目标字数与朗读分钟数二选一，都不填时使用年龄段的默认字数
+	TargetLength   int     `json:"target_length"`
+	ReadingMinutes float64 `json:"reading_minutes"`
+	// 教学模式：学习目标（如 分享、数到十、刷牙）与目标词汇
+	EducationalGoals []string `json:"educational_goals"`
+	Vocabulary       []string `json:"vocabulary"`
+	// 是否生成配套学习单
+	ActivitySheet bool `json:"activity_sheet"`
+	// 故事语言：zh-Hans（默认）、zh-Hant 或 en
+	Language string `json:"language"`
 }
 
+// Validate 逐个字段校验请求，返回 *common.ValidationError 列出全部无效的字段；
+// character_choice 为空时不生成音频
+func (req *StoryRequest) Validate() error {
+	errs := &common.ValidationError{}
+	if strings.TrimSpace(req.StoryContent) == "" {
+		errs.Add("story_content", "is required")
+	} else if length := utf8.RuneCountInString(req.StoryContent); length > MAX_STORY_CONTENT_LENGTH {
+		errs.Add("story_content", "must be at most %d characters, got %d", MAX_STORY_CONTENT_LENGTH, length)
+	}
+	if strings.TrimSpace(req.StoryType) == "" {
+		errs.Add("story_type", "is required")
+	}
+	if strings.TrimSpace(req.ImageType) == "" {
+		errs.Add("image_type", "is required")
+	}
+	if strings.TrimSpace(req.ChildAgeGroup) == "" {
+		errs.Add("child_age_group", "is required")
+	} else if !common.IsValidAgeGroup(req.ChildAgeGroup) {
+		errs.Add("child_age_group", "must be an age range such as 3-5")
+	}
+	common.ValidateTargetLength(errs, req.TargetLength, req.ReadingMinutes)
+	if len(req.EducationalGoals) > MAX_GOALS {
+		errs.Add("educational_goals", "must have at most %d items", MAX_GOALS)
+	}
+	if len(req.Vocabulary) > MAX_GOALS {
+		errs.Add("vocabulary", "must have at most %d items", MAX_GOALS)
+	}
+	if !common.IsSupportedLanguage(req.Language) {
+		errs.Add("language", "must be one of zh-Hans, zh-Hant, en")
+	}
+	return errs.Err()
+}
+
+const (
+	DEFAULT_STORY_LENGTH     = 600     // 故事的默认目标字数，年龄段默认字数更短时以年龄段为准
+	MAX_STORY_CONTENT_LENGTH = 500     // 故事主题的最大字数
+	MAX_GOALS                = 20      // 学习目标和目标词汇各自的数量上限
+	MAX_TTS_TEXT_BYTES       = 2048    // TTS 接口单次请求的文本长度上限
+	AUDIO_DIR                = "audio" // TTS 生成的音频文件保存在工作目录下的该目录中
+)
+
 type StoryResponse struct {
 	StoryTitle   string `json:"story_title"`
 	StoryContent string `json:"story_content"`
 	ImagePrompt  string `json:"image_prompt"`
 	AudioUrl     string `json:"audio_url"`
 	ImageUrl     string `json:"image_url"`
+	// 按年龄段进行的可读性检查结果
+	Readability *readability_module.ReadabilityReport `json:"readability,omitempty"`
+	// 各阶段的内容审核结果
+	Moderation []moderation_module.Decision `json:"moderation,omitempty"`
+	// 教学目标覆盖报告，未指定教学目标时为空
+	Coverage *education_module.CoverageReport `json:"coverage,omitempty"`
+	// 配套学习单，未请求时为空
+	ActivitySheet *education_module.ActivitySheet `json:"activity_sheet,omitempty"`
+	// 生成时使用的提示词模板及版本
+	Prompts []prompt_module.Ref `json:"prompts,omitempty"`
+	// 保存到故事库后的故事 ID
+	StoryID string `json:"story_id,omitempty"`
+	// 故事已生成但音频或图片失败时，失败的阶段及原因
+	Failures []StageFailure `json:"failures,omitempty"`
 }
 
+// /story 流程中可以单独失败的阶段
+const (
+	STAGE_TEXT  = "text"  // 故事文本
+	STAGE_AUDIO = "audio" // 朗读音频
+	STAGE_IMAGE = "image" // 插图，包括插图提示词
+)
+
+// StageFailure 一个阶段失败的原因，code 为 common.ERROR_* 中的错误类型
+type StageFailure struct {
+	Stage   string `json:"stage"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Failed 失败的阶段，全部成功时为空
+func (resp *StoryResponse) Failed() []string {
+	var stages []string
+	for _, failure := range resp.Failures {
+		stages = append(stages, failure.Stage)
+	}
+	return stages
+}
+
+// 记录失败的阶段，不中断后续阶段；错误详情只写入日志
+func (resp *StoryResponse) fail(stage string, err error) {
+	log.Printf("%s 阶段失败: %v", stage, err)
+	resp.Failures = append(resp.Failures, StageFailure{Stage: stage, Code: common.ErrorCode(err), Message: common.PublicMessage(err)})
+}
+
+// Document 把 /story 的结果转换为故事文档，用于保存到故事库；快速流程没有大纲和段落
+func (resp *StoryResponse) Document(req *StoryRequest) *story_document.StoryDocument {
+	doc := &story_document.StoryDocument{
+		Version:       story_document.CURRENT_VERSION,
+		ID:            story_document.NewID(),
+		Title:         resp.StoryTitle,
+		Premise:       req.StoryContent,
+		FinalText:     resp.StoryContent,
+		AgeGroup:      req.ChildAgeGroup,
+		Language:      common.GetLanguage(req.Language).Code,
+		Readability:   resp.Readability,
+		Coverage:      resp.Coverage,
+		ActivitySheet: resp.ActivitySheet,
+		Moderation:    resp.Moderation,
+		Goals:         common.NewEducationGoals(req.EducationalGoals, req.Vocabulary),
+		Metadata: story_document.Metadata{
+			Generator: story_document.GENERATOR,
+			// 快速流程固定使用豆包模型
+			Model:     "doubao",
+			CreatedAt: time.Now(),
+			Prompts:   resp.Prompts,
+		},
+	}
+	if resp.AudioUrl != "" {
+		doc.AddMedia(story_document.MEDIA_AUDIO, resp.AudioUrl, "")
+	}
+	if imageUrl := strings.TrimSpace(resp.ImageUrl); imageUrl != "" {
+		doc.AddMedia(story_document.MEDIA_IMAGE, imageUrl, resp.ImagePrompt)
+	}
+	return doc
+}
+
+// /story 流程的进度阶段
+const (
+	PROGRESS_STORY = "story" // 生成故事和插图提示词
+	PROGRESS_AUDIO = "audio" // 生成朗读音频
+	PROGRESS_IMAGE = "image" // 生成插图
+)
+
 // 是处理故事请求的服务层
-type StoryService struct{}
+type StoryService struct {
+	// 各阶段的进度回调，为 nil 时不报告进度
+	Progress common.ProgressFunc
+	// 模型调用的用量计入的 Meter，为 nil 时只计入进程的累计用量
+	Meter *common.Meter
+}
 
 // 创建一个新的 StoryService 实例
 func NewStoryService() *StoryService {
@@ -34,82 +184,171 @@ func NewStoryService() *StoryService {
 }
 
 // 用于处理故事请求的业务逻辑 逻辑线路
+// 故事文本没有生成时返回错误（请求无效、被拦截或模型调用失败）；
+// 故事已生成而音频或图片失败时仍返回 nil，失败的阶段记录在 resp.Failures 中
 func (s *StoryService) ProcessStoryRequest(req *StoryRequest, resp *StoryResponse) error {
 	// 1. story_content + story_type + child_age_group 生成提示词，返回故事结果
+	s.Progress.Report(common.Progress{Stage: PROGRESS_STORY, Done: 0, Total: 3})
 	err := s.GenerateStory(req, resp)
+	if resp.StoryContent == "" {
+		// 没有故事内容时不再继续生成音频和图片
+		if err == nil {
+			err = common.Upstream("doubao", errors.New("模型返回的故事内容为空"))
+		}
+		return err
+	}
 	if err != nil {
-		log.Printf("生成故事时发生错误: %v", err) // 记录错误，但不停止执行
+		// 故事已生成，插图提示词被拦截或生成失败
+		resp.fail(STAGE_IMAGE, err)
 	}
 
-	// 2. 根据故事结果 + character_choice 返回音频文件
-	err = generateAudioFromText(req, resp)
-	if err != nil {
-		log.Printf("生成音频时发生错误: %v", err) // 记录错误，但不停止执行
+	// 2. 根据故事结果 + character_choice 返回音频文件，未选择音色时不生成
+	s.Progress.Report(common.Progress{Stage: PROGRESS_AUDIO, Done: 1, Total: 3})
+	if req.CharacterChoice != "" {
+		if err := generateAudioFromText(req, resp); err != nil {
+			resp.fail(STAGE_AUDIO, err)
+		}
 	}
 
 	// 3. 根据图片提示词 + image_type 返回图片文件
-	err = generateImageFromText(req, resp)
-	if err != nil {
-		log.Printf("生成图片时发生错误: %v", err) // 记录错误，但不停止执行
+	s.Progress.Report(common.Progress{Stage: PROGRESS_IMAGE, Done: 2, Total: 3})
+	if resp.ImagePrompt != "" {
+		if err := generateImageFromText(req, resp); err != nil {
+			resp.fail(STAGE_IMAGE, err)
+		}
 	}
-
-	return nil // 不返回错误，确保处理继续进行
+	return nil
 }
 
 // 1. story_content + story_type + child_age_group 生成提示词，返回故事结果
 func (s *StoryService) GenerateStory(req *StoryRequest, resp *StoryResponse) error {
 	// 检查输入是否有效
-	if req.StoryContent == "" || req.StoryType == "" || req.ChildAgeGroup == "" || req.ImageType == "" {
-		return fmt.Errorf("请求参数无效，请提供故事内容、故事类型和儿童年龄组")
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	// 审核用户输入的故事主题
+	language := common.GetLanguage(req.Language)
+	storyTheme, decision, err := moderation_module.Moderate(moderation_module.STAGE_INPUT, req.StoryContent, language.Code, s.Meter)
+	resp.Moderation = append(resp.Moderation, decision)
+	if err != nil {
+		return err
+	}
+
+	ageProfile := common.GetAgeProfile(req.ChildAgeGroup)
+	defaultLength := DEFAULT_STORY_LENGTH
+	if ageProfile.TargetLength < defaultLength {
+		defaultLength = ageProfile.TargetLength
 	}
+	targetLength := language.ResolveTargetLength(req.TargetLength, req.ReadingMinutes, language.ScaleLength(defaultLength))
+	goals := common.NewEducationGoals(req.EducationalGoals, req.Vocabulary)
 
 	// 生成故事内容的提示词
-	storyPrompt := fmt.Sprintf(
-		"根据以下内容生成一个有趣的儿童故事,去掉不相关的内容只输出故事,回复文字的UTF-8编码长度不能超过2000且文字和符号总共不能超过600!"+
-			"\n故事的主题：%s\n故事的类型：%s\n儿童的年龄段：%s\n"+
-			"你需要按照如下格式进行回复\n"+
-			"故事题目：...\n"+
-			"故事内容:...",
-		req.StoryContent,
-		req.StoryType,
-		req.ChildAgeGroup,
-	)
+	storyPrompt, err := prompt_module.Render("service/story", language.Code, nil, struct {
+		TargetLength      int
+		Theme             string
+		EducationGuidance string
+		StoryType         string
+		AgeGroup          string
+		AgeGuidance       string
+	}{targetLength, storyTheme, goals.Guidance(), req.StoryType, req.ChildAgeGroup, ageProfile.Guidance()})
+	if err != nil {
+		return err
+	}
+	systemPrompt, err := prompt_module.Render("service/story_system", language.Code, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Prompts = prompt_module.Refs(language.Code, nil, "service")
 	log.Printf("storyPrompt:%s", storyPrompt)
 	// 调用模型生成故事内容
-	storyContent, err := model.GenerateStory(
-		"你是一名故事生成的专家，请根据以下提示生成一个有趣的故事。",
-		storyPrompt,
-	)
+	start := time.Now()
+	storyContent, err := model.GenerateStory(systemPrompt, storyPrompt)
+	s.Meter.RecordCall(systemPrompt+storyPrompt, storyContent, time.Since(start), err)
+	err = common.Upstream("doubao", err)
 	if err != nil {
 		log.Printf("生成故事内容时发生错误: %v", err)
-		return fmt.Errorf("生成故事内容时发生错误: %v", err)
+		return fmt.Errorf("生成故事内容时发生错误: %w", err)
 	}
 	//log.Printf("storyContent:%s", storyContent)
 	//处理故事题目和故事内容
-	title, story := extractStoryInfo(storyContent)
+	title, story := extractStoryInfo(storyContent, language.Code)
+	// 字数偏离目标时压缩或扩写
+	story, err = edit_module.AdjustLength(story, targetLength, language.Code, nil, s.Meter)
+	if err != nil {
+		log.Printf("调整故事字数时发生错误: %v", err)
+	}
+	// 审核生成的故事内容
+	story, decision, err = moderation_module.Moderate(moderation_module.STAGE_OUTPUT, story, language.Code, s.Meter)
+	resp.Moderation = append(resp.Moderation, decision)
+	if err != nil {
+		return err
+	}
 	resp.StoryTitle = title
 	resp.StoryContent = story
+	if language.IsChinese() {
+		// 可读性指标只适用于中文
+		report := readability_module.Analyze(story, ageProfile)
+		resp.Readability = &report
+	}
+	if !goals.Empty() {
+		coverage := education_module.CheckCoverage(goals, []string{story}, language.Code, s.Meter)
+		resp.Coverage = &coverage
+	}
+	if req.ActivitySheet {
+		sheet, err := education_module.GenerateActivitySheet(story, "", &ageProfile, goals, language.Code, s.Meter)
+		if err != nil {
+			// 学习单是附加内容，生成失败不影响故事
+			log.Printf("生成学习单时发生错误: %v", err)
+		}
+		resp.ActivitySheet = sheet
+	}
 	log.Printf("StoryTitle:%s", title)
 	log.Printf("StoryContent:%s", story)
 
+	imagePrompt, decision, err := GenerateImagePrompt(story, req.ImageType, s.Meter)
+	if decision.Stage != "" {
+		resp.Moderation = append(resp.Moderation, decision)
+	}
+	if err != nil {
+		return err
+	}
+	resp.ImagePrompt = imagePrompt
+	return nil
+}
+
+// GenerateImagePrompt 根据故事内容和画风生成插图提示词，并审核生成的提示词；
+// 提示词被拦截时返回 *moderation_module.BlockedError，未经审核就失败时返回的审核结果为空；模型调用计入 meter（可以为 nil）
+// 插图提示词交给图片模型使用，无论故事是什么语言都用中文模板生成并按中文审核
+func GenerateImagePrompt(story string, imageType string, meter *common.Meter) (string, moderation_module.Decision, error) {
 	// 生成图片提示词的提示词
-	imagePromptInput := fmt.Sprintf(
-		"根据这个故事内容主旨生成供生成图片的提示词，图片类型：%s\n故事内容：%s",
-		req.ImageType,
-		storyContent,
-	)
+	imagePromptInput, err := prompt_module.Render("service/image_prompt", common.DEFAULT_LANGUAGE, nil, struct {
+		ImageType string
+		Story     string
+	}{imageType, story})
+	if err != nil {
+		return "", moderation_module.Decision{}, err
+	}
+	imagePromptSystem, err := prompt_module.Render("service/image_prompt_system", common.DEFAULT_LANGUAGE, nil, nil)
+	if err != nil {
+		return "", moderation_module.Decision{}, err
+	}
 	// 调用模型生成图片提示词
-	imagePrompt, err := model.GenerateStory(
-		"你是一名生成故事的专家，请根据以下提示生成一个适合图片生成的提示词。",
-		imagePromptInput,
-	)
+	start := time.Now()
+	imagePrompt, err := model.GenerateStory(imagePromptSystem, imagePromptInput)
+	meter.RecordCall(imagePromptSystem+imagePromptInput, imagePrompt, time.Since(start), err)
+	err = common.Upstream("doubao", err)
 	if err != nil {
 		log.Printf("生成图片提示词时发生错误: %v", err)
-		return fmt.Errorf("生成图片提示词时发生错误: %v", err)
+		return "", moderation_module.Decision{}, fmt.Errorf("生成图片提示词时发生错误: %w", err)
+	}
+	// 审核图片提示词，被拦截时不生成图片
+	imagePrompt, decision, err := moderation_module.Moderate(moderation_module.STAGE_IMAGE_PROMPT, imagePrompt, common.DEFAULT_LANGUAGE, meter)
+	if err != nil {
+		return "", decision, err
 	}
 	log.Printf("imagePrompt:%s", imagePrompt)
-	resp.ImagePrompt = imagePrompt
-	return nil
+	return imagePrompt, decision, nil
 }
 
 // 2. 根据故事结果 + character_choice 返回音频文件
@@ -119,24 +358,65 @@ func generateAudioFromText(req *StoryRequest, resp *StoryResponse) error {
 		return fmt.Errorf("请求参数无效，请提供故事内容、音频角色信息")
 	}
 
-	if len(resp.StoryContent) > 2048 {
-		resp.StoryContent = resp.StoryContent[:2048] // 截断到前 2048 个字符
-		log.Printf("StoryContent exceeded 2048 characters, truncated to: %s", resp.StoryContent)
+	audioUrl, err := GenerateAudio(resp.StoryContent, req.CharacterChoice)
+	if err != nil {
+		return err
 	}
+	resp.AudioUrl = audioUrl
+	return nil
+}
 
-	// 调用生成音频的函数
-	fileName, err := model.GenerateAudioFromText(resp.StoryContent, req.CharacterChoice)
+// GenerateAudio 将文本转为语音，返回可通过 /getAudio 访问的音频 URL
+func GenerateAudio(text string, voiceName string) (string, error) {
+	fileName, err := synthesize(text, voiceName)
 	if err != nil {
-		log.Printf("Failed to generate audio: %v", err)
-		return err
+		return "", err
 	}
 
 	// 构建音频文件的访问 URL
 	address := fmt.Sprintf("%s:%d", config.GetConfig().Server.Host, config.GetConfig().Server.Port)
-	resp.AudioUrl = "http://" + address + "/getAudio?filename=" + fileName
-	log.Printf("Audio URL: %s", resp.AudioUrl)
+	audioUrl := "http://" + address + "/getAudio?filename=" + fileName
+	log.Printf("Audio URL: %s", audioUrl)
 
-	return nil
+	return audioUrl, nil
+}
+
+// SaveAudio 将文本转为语音并把音频文件移动到 path，供命令行工具写入输出目录
+func SaveAudio(text string, voiceName string, path string) error {
+	fileName, err := synthesize(text, voiceName)
+	if err != nil {
+		return err
+	}
+	source := filepath.Join(AUDIO_DIR, fileName)
+	if err := os.Rename(source, path); err == nil {
+		return nil
+	}
+	// 输出目录与音频目录不在同一个文件系统时复制后删除
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	return os.Remove(source)
+}
+
+// 调用 TTS 生成音频，返回保存在 AUDIO_DIR 下的文件名
+func synthesize(text string, voiceName string) (string, error) {
+	// 只截断送给 TTS 的文本，按字符边界截断避免切坏多字节字符
+	ttsText := common.TruncateBytes(text, MAX_TTS_TEXT_BYTES)
+	if len(ttsText) < len(text) {
+		log.Printf("Text exceeded %d bytes, truncated for TTS to: %s", MAX_TTS_TEXT_BYTES, ttsText)
+	}
+
+	// 调用生成音频的函数
+	fileName, err := model.GenerateAudioFromText(ttsText, voiceName)
+	if err != nil {
+		log.Printf("Failed to generate audio: %v", err)
+		return "", common.Upstream("youdao_tts", err)
+	}
+	return fileName, nil
 }
 
 // 3. 根据图片提示词 + image_type 返回图片文件
@@ -149,25 +429,52 @@ func generateImageFromText(req *StoryRequest, resp *StoryResponse) error {
 	imageUrl, err := model.GenerateImage(resp.ImagePrompt)
 	if err != nil {
 		log.Printf("Failed to generate image: %v", err)
-		return err
+		return common.Upstream("image", err)
 	}
 	resp.ImageUrl = imageUrl
 	return nil
 }
 
 // 提取故事的题目和内容
-func extractStoryInfo(story string) (string, string) {
+func extractStoryInfo(story string, language string) (string, string) {
+	labels := storyLabels[common.GetLanguage(language).Code]
 	// 找到故事中的第一行（题目）和剩余内容（故事内容）
 	lines := strings.SplitN(story, "\n", 2) // 按照第一个换行符分割
 
 	// 如果格式正确，返回题目和内容
 	if len(lines) == 2 {
-		// 去掉 "故事题目：" 和 "故事内容：" 前缀
-		title := strings.Replace(lines[0], "故事题目：", "", 1)
-		content := strings.Replace(lines[1], "故事内容：", "", 1)
+		// 去掉 "故事题目：" 和 "故事内容：" 等前缀，冒号可以是全角或半角
+		title := trimLabel(lines[0], labels.title)
+		content := trimLabel(lines[1], labels.content)
 		return title, content
 	}
 
 	// 如果格式不对，返回默认题目和内容
-	return "生成的故事", story
+	return labels.untitled, story
+}
+
+// 各语言 service/story 模板要求的题目和内容前缀，以及格式不对时的默认题目
+var storyLabels = map[string]struct {
+	title    string
+	content  string
+	untitled string
+}{
+	common.LANG_ZH_HANS: {"故事题目", "故事内容", "生成的故事"},
+	common.LANG_ZH_HANT: {"故事題目", "故事內容", "生成的故事"},
+	common.LANG_EN:      {"Title", "Story", "Untitled story"},
+}
+
+// 去掉行首的标签及其后的冒号，标签不区分大小写
+func trimLabel(line string, label string) string {
+	trimmed := strings.TrimSpace(line)
+	if len(trimmed) < len(label) || !strings.EqualFold(trimmed[:len(label)], label) {
+		return line
+	}
+	rest := strings.TrimSpace(trimmed[len(label):])
+	for _, colon := range []string{"：", ":"} {
+		if strings.HasPrefix(rest, colon) {
+			return strings.TrimSpace(strings.TrimPrefix(rest, colon))
+		}
+	}
+	return line
 }
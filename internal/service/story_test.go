@@ -1,7 +1,9 @@
 package service
 
 import (
+	"errors"
 	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/common"
 	"fmt"
 	"path/filepath"
 	"runtime"
@@ -56,3 +58,49 @@ func TestGenerateStory_ValidRequest(t *testing.T) {
 	}
 	fmt.Println(resp.StoryContent + resp.ImagePrompt + resp.AudioUrl)
 }
+
+func TestStoryRequestValidate(t *testing.T) {
+	valid := StoryRequest{StoryContent: "爱探险的朵拉", StoryType: "冒险", ChildAgeGroup: "3-5岁", ImageType: "卡通风格"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("有效的请求校验失败: %v", err)
+	}
+
+	invalid := StoryRequest{ChildAgeGroup: "幼儿", TargetLength: -1, Vocabulary: make([]string, MAX_GOALS+1), Language: "fr"}
+	var errs *common.ValidationError
+	if !errors.As(invalid.Validate(), &errs) {
+		t.Fatal("无效的请求应返回 *common.ValidationError")
+	}
+	var fields []string
+	for _, field := range errs.Fields {
+		fields = append(fields, field.Field)
+	}
+	if fmt.Sprint(fields) != "[story_content story_type image_type child_age_group target_length vocabulary language]" {
+		t.Errorf("无效字段错误: %v", fields)
+	}
+}
+
+func TestExtractStoryInfo(t *testing.T) {
+	title, story := extractStoryInfo("故事题目：小兔子找朋友\n故事内容:从前有一只小兔子。", common.LANG_ZH_HANS)
+	if title != "小兔子找朋友" || story != "从前有一只小兔子。" {
+		t.Errorf("中文题目解析错误: %q %q", title, story)
+	}
+	title, story = extractStoryInfo("title: Benny Finds a Friend\nStory: Once upon a time, there was a rabbit.", common.LANG_EN)
+	if title != "Benny Finds a Friend" || story != "Once upon a time, there was a rabbit." {
+		t.Errorf("英文题目解析错误: %q %q", title, story)
+	}
+	if title, _ := extractStoryInfo("Once upon a time.", common.LANG_EN); title != "Untitled story" {
+		t.Errorf("格式不对时应使用默认题目: %q", title)
+	}
+}
+
+func TestStoryResponseFailed(t *testing.T) {
+	resp := &StoryResponse{}
+	if resp.Failed() != nil {
+		t.Error("全部成功时不应有失败的阶段")
+	}
+	resp.fail(STAGE_AUDIO, common.Upstream("youdao_tts", errors.New("服务不可用")))
+	resp.fail(STAGE_IMAGE, errors.New("ImagePrompt is empty"))
+	if fmt.Sprint(resp.Failed()) != "[audio image]" || resp.Failures[0].Code != common.ERROR_UPSTREAM || resp.Failures[1].Code != common.ERROR_INTERNAL {
+		t.Errorf("失败的阶段错误: %+v", resp.Failures)
+	}
+}
@@ -0,0 +1,71 @@
+package batch_module
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"flutterdreams/internal/story_generation/story_document"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	// ErrNotFound 批次不存在或已过期
+	ErrNotFound = errors.New("批次不存在或已过期")
+	// ErrNotFinished 批次中还有故事没有生成完
+	ErrNotFinished = errors.New("批次尚未完成")
+	// ErrQueueFull 排队的故事已达上限，稍后可以重新提交
+	ErrQueueFull = errors.New("排队的故事过多，请稍后再提交")
+)
+
+// WriteArchive 把批次中成功生成的故事写成 zip：
+// manifest.json 为批次状态，stories/ 下每个故事一个 JSON 文档和一个可打印的 HTML
+func (m *Manager) WriteArchive(id string, w io.Writer) error {
+	batch, ok := m.Get(id)
+	if !ok {
+		return ErrNotFound
+	}
+	if !batch.Done() {
+		return ErrNotFinished
+	}
+
+	archive := zip.NewWriter(w)
+	manifest, err := json.MarshalIndent(batch, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(archive, "manifest.json", manifest, batch.FinishedAt); err != nil {
+		return err
+	}
+	for _, item := range batch.Items {
+		if item.document == nil {
+			continue
+		}
+		name := fmt.Sprintf("stories/%02d-%s", item.Index+1, item.StoryID)
+		data, err := story_document.Marshal(item.document, story_document.FORMAT_JSON)
+		if err != nil {
+			return fmt.Errorf("第 %d 个故事: %v", item.Index, err)
+		}
+		if err := writeFile(archive, name+".json", data, item.FinishedAt); err != nil {
+			return err
+		}
+		html, err := item.document.ExportHTML()
+		if err != nil {
+			return fmt.Errorf("第 %d 个故事: %v", item.Index, err)
+		}
+		if err := writeFile(archive, name+".html", []byte(html), item.FinishedAt); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func writeFile(archive *zip.Writer, name string, data []byte, modified time.Time) error {
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	return err
+}
@@ -0,0 +1,276 @@
+// 批量生成模块：一次提交多个故事请求（如一个教学单元的一组故事），由固定数量的 worker 并发生成，
+// 客户端轮询批次及每个故事的状态，全部完成后下载包含所有故事的 zip
+package batch_module
+
+import (
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/moderation_module"
+	"flutterdreams/internal/story_generation/story_document"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_CONCURRENCY       = 2
+	DEFAULT_MAX_ITEMS         = 30
+	DEFAULT_RETENTION_MINUTES = 24 * 60
+	// 等待生成的故事总数上限，超过时拒绝新的批次
+	MAX_QUEUED_ITEMS = 200
+)
+
+// 批次和单个故事的状态
+const (
+	STATUS_PENDING   = "pending"   // 排队中
+	STATUS_RUNNING   = "running"   // 生成中
+	STATUS_SUCCEEDED = "succeeded" // 全部成功
+	STATUS_FAILED    = "failed"    // 生成失败（批次：全部失败）
+	STATUS_BLOCKED   = "blocked"   // 未通过内容审核
+	STATUS_PARTIAL   = "partial"   // 批次中部分故事失败
+)
+
+// Request 批次中的一个故事请求
+type Request struct {
+	Premise string
+	Options story_generation.StoryOptions
+	// 开始生成前和生成结束后（无论成功与否，失败时 doc 为 nil）调用，如计量用量、保存到故事库；为 nil 时不调用。
+	// BeforeGenerate 可以修改本次生成使用的选项，如设置 Meter
+	BeforeGenerate func(options *story_generation.StoryOptions)
+	AfterGenerate  func(doc *story_document.StoryDocument, err error)
+}
+
+// GenerateFunc 生成单个故事，默认为 story_generation.GenerateStoryDocument
+type GenerateFunc func(premise string, options story_generation.StoryOptions) (*story_document.StoryDocument, error)
+
+// Item 批次中单个故事的状态
+type Item struct {
+	Index   int    `json:"index"`
+	Premise string `json:"premise"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	// 失败的错误类型，见 common.ERROR_*
+	ErrorCode  string    `json:"error_code,omitempty"`
+	StoryID    string    `json:"story_id,omitempty"`
+	Title      string    `json:"title,omitempty"`
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+
+	request  Request
+	document *story_document.StoryDocument
+}
+
+// Batch 批次的状态，Items 按提交顺序排列
+type Batch struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	// 提交批次的用户 ID，未启用认证时为空
+	OwnerID    string    `json:"owner_id,omitempty"`
+	Total      int       `json:"total"`
+	Completed  int       `json:"completed"`
+	Failed     int       `json:"failed"`
+	CreatedAt  time.Time `json:"created_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Items      []Item    `json:"items"`
+}
+
+// Done 批次中的故事是否都已结束（成功、失败或被拦截）
+func (b *Batch) Done() bool {
+	return b.Completed+b.Failed == b.Total
+}
+
+// 排队中的一个故事
+type task struct {
+	batch *Batch
+	index int
+}
+
+// Manager 保存批次状态并用 worker 池生成故事
+type Manager struct {
+	mu        sync.Mutex
+	batches   map[string]*Batch
+	queue     chan task
+	generate  GenerateFunc
+	maxItems  int
+	retention time.Duration
+}
+
+// NewManager 创建批量生成管理器并启动 concurrency 个 worker
+func NewManager(concurrency int, maxItems int, retention time.Duration, generate GenerateFunc) *Manager {
+	if concurrency <= 0 {
+		concurrency = DEFAULT_CONCURRENCY
+	}
+	if maxItems <= 0 {
+		maxItems = DEFAULT_MAX_ITEMS
+	}
+	m := &Manager{
+		batches:   make(map[string]*Batch),
+		queue:     make(chan task, MAX_QUEUED_ITEMS),
+		generate:  generate,
+		maxItems:  maxItems,
+		retention: retention,
+	}
+	for i := 0; i < concurrency; i++ {
+		go m.work()
+	}
+	return m
+}
+
+var (
+	defaultOnce    sync.Once
+	defaultManager *Manager
+)
+
+// Default 按配置创建的全局管理器，第一次使用时启动 worker
+func Default() *Manager {
+	defaultOnce.Do(func() {
+		cfg := config.GetConfig().Batch
+		retention := cfg.RetentionMinutes
+		if retention <= 0 {
+			retention = DEFAULT_RETENTION_MINUTES
+		}
+		defaultManager = NewManager(cfg.Concurrency, cfg.MaxItems, time.Duration(retention)*time.Minute, story_generation.GenerateStoryDocument)
+	})
+	return defaultManager
+}
+
+// Submit 提交一个批次，返回批次的初始状态；批次为空、超过上限时返回错误，队列已满时返回 ErrQueueFull
+func (m *Manager) Submit(ownerID string, requests []Request) (Batch, error) {
+	if len(requests) == 0 {
+		return Batch{}, fmt.Errorf("批次中没有故事请求")
+	}
+	if len(requests) > m.maxItems {
+		return Batch{}, fmt.Errorf("批次最多包含 %d 个故事，实际为 %d 个", m.maxItems, len(requests))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire(time.Now())
+	if len(m.queue)+len(requests) > cap(m.queue) {
+		return Batch{}, ErrQueueFull
+	}
+
+	batch := &Batch{
+		ID:        story_document.NewID(),
+		Status:    STATUS_PENDING,
+		OwnerID:   ownerID,
+		Total:     len(requests),
+		CreatedAt: time.Now(),
+	}
+	for i, request := range requests {
+		batch.Items = append(batch.Items, Item{Index: i, Premise: request.Premise, Status: STATUS_PENDING, request: request})
+	}
+	m.batches[batch.ID] = batch
+	for i := range requests {
+		m.queue <- task{batch: batch, index: i}
+	}
+	log.Printf("批次 %s 已提交，共 %d 个故事", batch.ID, batch.Total)
+	return snapshot(batch), nil
+}
+
+// Get 批次的当前状态
+func (m *Manager) Get(id string) (Batch, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	batch, ok := m.batches[id]
+	if !ok {
+		return Batch{}, false
+	}
+	return snapshot(batch), true
+}
+
+// 删除完成时间超过保留时长的批次
+func (m *Manager) expire(now time.Time) {
+	if m.retention <= 0 {
+		return
+	}
+	for id, batch := range m.batches {
+		if batch.Done() && now.Sub(batch.FinishedAt) > m.retention {
+			delete(m.batches, id)
+		}
+	}
+}
+
+// worker 依次从队列取出故事生成
+func (m *Manager) work() {
+	for t := range m.queue {
+		m.mu.Lock()
+		item := &t.batch.Items[t.index]
+		item.Status = STATUS_RUNNING
+		item.StartedAt = time.Now()
+		t.batch.Status = STATUS_RUNNING
+		request := item.request
+		m.mu.Unlock()
+
+		doc, err := m.run(&request)
+		if request.AfterGenerate != nil {
+			request.AfterGenerate(doc, err)
+		}
+
+		m.mu.Lock()
+		item.FinishedAt = time.Now()
+		if blocked, ok := moderation_module.IsBlocked(err); ok {
+			item.Status = STATUS_BLOCKED
+			item.Error = blocked.Error()
+			item.ErrorCode = common.ERROR_BLOCKED
+		} else if err != nil {
+			item.Status = STATUS_FAILED
+			item.Error = common.PublicMessage(err)
+			item.ErrorCode = common.ErrorCode(err)
+		} else {
+			item.Status = STATUS_SUCCEEDED
+			item.StoryID = doc.ID
+			item.Title = doc.Title
+			item.document = doc
+		}
+		if item.Status == STATUS_SUCCEEDED {
+			t.batch.Completed++
+		} else {
+			t.batch.Failed++
+			// 返回给客户端的错误信息不含详情，详情只写入日志
+			log.Printf("批次 %s 第 %d 个故事生成失败: %v", t.batch.ID, t.index, err)
+		}
+		if t.batch.Done() {
+			t.batch.FinishedAt = item.FinishedAt
+			t.batch.Status = finalStatus(t.batch)
+			log.Printf("批次 %s 完成: 成功 %d，失败 %d", t.batch.ID, t.batch.Completed, t.batch.Failed)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// 生成一个故事；生成过程中的 panic 转为错误，只影响这一个故事，AfterGenerate 仍会被调用
+func (m *Manager) run(request *Request) (doc *story_document.StoryDocument, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("生成故事时发生 panic: %v\n%s", r, debug.Stack())
+			doc, err = nil, fmt.Errorf("生成故事时发生内部错误: %v", r)
+		}
+	}()
+	if request.BeforeGenerate != nil {
+		request.BeforeGenerate(&request.Options)
+	}
+	return m.generate(request.Premise, request.Options)
+}
+
+// 全部结束后批次的状态
+func finalStatus(batch *Batch) string {
+	switch {
+	case batch.Failed == 0:
+		return STATUS_SUCCEEDED
+	case batch.Completed == 0:
+		return STATUS_FAILED
+	default:
+		return STATUS_PARTIAL
+	}
+}
+
+// 复制批次状态，调用方持有锁
+func snapshot(batch *Batch) Batch {
+	copied := *batch
+	copied.Items = append([]Item(nil), batch.Items...)
+	return copied
+}
@@ -0,0 +1,160 @@
+package batch_module
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"flutterdreams/internal/story_generation"
+	"flutterdreams/internal/story_generation/moderation_module"
+	"flutterdreams/internal/story_generation/story_document"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 等待批次结束
+func waitDone(t *testing.T, m *Manager, id string) Batch {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		batch, ok := m.Get(id)
+		if !ok {
+			t.Fatalf("找不到批次 %s", id)
+		}
+		if batch.Done() {
+			return batch
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("批次没有在限定时间内完成")
+	return Batch{}
+}
+
+func TestBatch(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	generate := func(premise string, options story_generation.StoryOptions) (*story_document.StoryDocument, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		switch premise {
+		case "失败":
+			return nil, errors.New("模型不可用")
+		case "拦截":
+			return nil, &moderation_module.BlockedError{Decision: moderation_module.Decision{Stage: moderation_module.STAGE_INPUT}}
+		case "崩溃":
+			panic("空指针")
+		}
+		return &story_document.StoryDocument{Version: story_document.CURRENT_VERSION, ID: story_document.NewID(), Title: premise, Premise: premise, FinalText: premise}, nil
+	}
+	m := NewManager(2, 10, time.Hour, generate)
+
+	premises := []string{"小兔子", "失败", "小熊", "拦截", "小猫", "崩溃"}
+	var requests []Request
+	started, succeeded, failed := 0, 0, 0
+	for _, premise := range premises {
+		premise := premise
+		requests = append(requests, Request{
+			Premise: premise,
+			BeforeGenerate: func(*story_generation.StoryOptions) {
+				mu.Lock()
+				started++
+				mu.Unlock()
+			},
+			AfterGenerate: func(doc *story_document.StoryDocument, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failed++
+				} else if doc.Title == premise {
+					succeeded++
+				}
+			},
+		})
+	}
+	submitted, err := m.Submit("user-1", requests)
+	if err != nil {
+		t.Fatalf("提交批次失败: %v", err)
+	}
+	if submitted.Total != len(premises) || submitted.OwnerID != "user-1" || submitted.Items[0].Status != STATUS_PENDING {
+		t.Errorf("批次初始状态错误: %+v", submitted)
+	}
+	if err := m.WriteArchive(submitted.ID, &bytes.Buffer{}); err != ErrNotFinished && err != nil {
+		t.Errorf("未完成时下载应返回 ErrNotFinished: %v", err)
+	}
+
+	batch := waitDone(t, m, submitted.ID)
+	if batch.Status != STATUS_PARTIAL || batch.Completed != 3 || batch.Failed != 3 {
+		t.Errorf("批次状态错误: %+v", batch)
+	}
+	if batch.Items[1].Status != STATUS_FAILED || batch.Items[3].Status != STATUS_BLOCKED || batch.Items[4].Title != "小猫" {
+		t.Errorf("单个故事状态错误: %+v", batch.Items)
+	}
+	if batch.Items[1].Error != "内部错误" {
+		t.Errorf("失败的故事不应返回错误详情: %s", batch.Items[1].Error)
+	}
+	// panic 只让这一个故事失败，worker 继续运行
+	if batch.Items[5].Status != STATUS_FAILED || batch.Items[5].Error == "" {
+		t.Errorf("panic 的故事应标记为失败: %+v", batch.Items[5])
+	}
+	if maxRunning > 2 {
+		t.Errorf("同时生成的故事数 %d 超过并发上限", maxRunning)
+	}
+	mu.Lock()
+	if started != 6 || succeeded != 3 || failed != 3 {
+		t.Errorf("生成前后的回调次数错误: 开始 %d，成功 %d，失败 %d", started, succeeded, failed)
+	}
+	mu.Unlock()
+
+	var buffer bytes.Buffer
+	if err := m.WriteArchive(batch.ID, &buffer); err != nil {
+		t.Fatalf("生成压缩包失败: %v", err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// manifest 以及 3 个成功故事的 JSON 和 HTML
+	if len(reader.File) != 7 || reader.File[0].Name != "manifest.json" {
+		t.Errorf("压缩包内容错误: %d 个文件", len(reader.File))
+	}
+	if err := m.WriteArchive("missing", &buffer); err != ErrNotFound {
+		t.Errorf("不存在的批次应返回 ErrNotFound: %v", err)
+	}
+}
+
+func TestSubmitLimits(t *testing.T) {
+	m := NewManager(1, 2, time.Hour, nil)
+	if _, err := m.Submit("", nil); err == nil {
+		t.Error("空批次应提交失败")
+	}
+	if _, err := m.Submit("", make([]Request, 3)); err == nil {
+		t.Error("超过上限的批次应提交失败")
+	}
+
+	m = NewManager(1, MAX_QUEUED_ITEMS+1, time.Hour, nil)
+	if _, err := m.Submit("", make([]Request, MAX_QUEUED_ITEMS+1)); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("队列已满时应返回 ErrQueueFull: %v", err)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	m := NewManager(1, 2, time.Minute, nil)
+	m.batches["old"] = &Batch{ID: "old", Total: 1, Completed: 1, FinishedAt: time.Now().Add(-2 * time.Minute)}
+	m.batches["running"] = &Batch{ID: "running", Total: 1}
+	m.expire(time.Now())
+	if _, ok := m.Get("old"); ok {
+		t.Error("过期的批次应被删除")
+	}
+	if _, ok := m.Get("running"); !ok {
+		t.Error("未完成的批次不应被删除")
+	}
+}
@@ -0,0 +1,196 @@
+// 双语模块：成稿后为每个段落生成逐句对齐的译文或拼音，用于语言学习
+package bilingual_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	MAX_ATTEMPTS = 3
+	// TARGET_PINYIN 对照文本为带声调的汉语拼音
+	TARGET_PINYIN = "pinyin"
+)
+
+// 朗读的一侧
+const (
+	SIDE_SOURCE = "source" // 原文
+	SIDE_TARGET = "target" // 对照文本
+)
+
+var pairLine = regexp.MustCompile(`^(\d+)\s*[｜|]\s*(.+)$`)
+
+// SentencePair 一句原文及其对照文本
+type SentencePair struct {
+	Source string `json:"source" yaml:"source"`
+	Target string `json:"target" yaml:"target"`
+}
+
+// Section 一个段落的逐句对照
+type Section struct {
+	Index int            `json:"index" yaml:"index"`
+	Pairs []SentencePair `json:"pairs" yaml:"pairs"`
+}
+
+// ParallelText 整个故事的双语对照
+type ParallelText struct {
+	SourceLanguage string    `json:"source_language" yaml:"source_language"`
+	TargetLanguage string    `json:"target_language" yaml:"target_language"`
+	Sections       []Section `json:"sections" yaml:"sections"`
+}
+
+// ValidateTarget 检查对照语言：拼音只适用于中文原文，翻译目标必须是受支持且与原文不同的语言
+func ValidateTarget(source string, target string) error {
+	sourceLanguage := common.GetLanguage(source)
+	if target == TARGET_PINYIN {
+		if !sourceLanguage.IsChinese() {
+			return fmt.Errorf("只有中文故事可以生成拼音对照")
+		}
+		return nil
+	}
+	if !common.IsSupportedLanguage(target) {
+		return fmt.Errorf("不支持的对照语言: %s", target)
+	}
+	if common.GetLanguage(target).Code == sourceLanguage.Code {
+		return fmt.Errorf("对照语言不能与故事语言相同: %s", target)
+	}
+	return nil
+}
+
+// AlignSections 为每个段落生成逐句对照
+// 参数：
+// - sections: 各段落内容
+// - source: 故事语言
+// - target: 对照语言（common 中的语言代码）或 TARGET_PINYIN
+// - meter: 模型调用的用量计入的 Meter，可以为 nil
+func AlignSections(sections []string, source string, target string, meter *common.Meter) (*ParallelText, error) {
+	if err := ValidateTarget(source, target); err != nil {
+		return nil, err
+	}
+	if target != TARGET_PINYIN {
+		target = common.GetLanguage(target).Code
+	}
+
+	text := &ParallelText{
+		SourceLanguage: common.GetLanguage(source).Code,
+		TargetLanguage: target,
+	}
+	for i, content := range sections {
+		pairs, err := alignSection(content, target, meter)
+		if err != nil {
+			return nil, fmt.Errorf("段落 %d 对照生成失败: %w", i, err)
+		}
+		text.Sections = append(text.Sections, Section{Index: i, Pairs: pairs})
+	}
+	return text, nil
+}
+
+// 为单个段落生成逐句对照：原文按句编号后交给模型，按编号对齐结果
+func alignSection(content string, target string, meter *common.Meter) ([]SentencePair, error) {
+	sentences := common.SplitSentences(content)
+	if len(sentences) == 0 {
+		return nil, nil
+	}
+	prompt := constructAlignPrompt(sentences, target)
+
+	for attempts := 0; attempts < MAX_ATTEMPTS; attempts++ {
+		response, err := meter.ChatWithModel(prompt)
+		if err != nil {
+			return nil, fmt.Errorf("调用模型生成对照失败: %w", err)
+		}
+		targets := parseAlignedLines(response)
+		pairs, missing := pairSentences(sentences, targets)
+		if missing == 0 {
+			return pairs, nil
+		}
+		log.Printf("对照结果缺少 %d 句，重新生成", missing)
+	}
+	return nil, fmt.Errorf("未能为所有句子生成对照")
+}
+
+// 构建生成对照的提示词
+func constructAlignPrompt(sentences []string, target string) string {
+	var builder strings.Builder
+
+	if target == TARGET_PINYIN {
+		builder.WriteString("请为以下编号的中文句子逐句标注带声调符号的汉语拼音（如 xiǎo tù zi），多音字按句中的读音标注。\n\n")
+	} else {
+		builder.WriteString("请把以下编号的句子逐句翻译成" + common.GetLanguage(target).Name + "，译文要简单自然，适合儿童阅读。\n\n")
+	}
+
+	for i, sentence := range sentences {
+		builder.WriteString(fmt.Sprintf("%d｜%s\n", i+1, sentence))
+	}
+
+	builder.WriteString("\n要求：\n")
+	builder.WriteString("1. 每一句对应输出一行，不要合并或拆分句子\n")
+	builder.WriteString("2. 不要使用特殊字符、星号或markdown格式\n")
+	builder.WriteString("3. 每行格式为 编号｜结果，编号与原句相同，不要输出原句\n")
+
+	return builder.String()
+}
+
+// 解析 "编号｜结果" 格式的对照结果
+func parseAlignedLines(response string) map[int]string {
+	targets := make(map[int]string)
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, "*", ""))
+		match := pairLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[1])
+		if _, ok := targets[index]; !ok {
+			targets[index] = strings.TrimSpace(match[2])
+		}
+	}
+	return targets
+}
+
+// 按编号配对原句与对照结果，返回配对结果及缺少对照的句子数
+func pairSentences(sentences []string, targets map[int]string) ([]SentencePair, int) {
+	pairs := make([]SentencePair, len(sentences))
+	missing := 0
+	for i, sentence := range sentences {
+		pairs[i] = SentencePair{Source: sentence, Target: targets[i+1]}
+		if pairs[i].Target == "" {
+			missing++
+		}
+	}
+	return pairs, missing
+}
+
+// Text 拼接某一侧的全文，side 为 SIDE_SOURCE 或 SIDE_TARGET
+func (t *ParallelText) Text(side string) string {
+	var sentences []string
+	for _, section := range t.Sections {
+		for _, pair := range section.Pairs {
+			if side == SIDE_TARGET {
+				sentences = append(sentences, pair.Target)
+			} else {
+				sentences = append(sentences, pair.Source)
+			}
+		}
+	}
+	separator := ""
+	language := t.SourceLanguage
+	if side == SIDE_TARGET {
+		language = t.TargetLanguage
+	}
+	if language == TARGET_PINYIN || !common.GetLanguage(language).IsChinese() {
+		separator = " "
+	}
+	return strings.Join(sentences, separator)
+}
+
+// SpeechText 用于朗读的文本：拼音对照朗读时读原文
+func (t *ParallelText) SpeechText(side string) string {
+	if side == SIDE_TARGET && t.TargetLanguage == TARGET_PINYIN {
+		side = SIDE_SOURCE
+	}
+	return t.Text(side)
+}
@@ -0,0 +1,44 @@
+package bilingual_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"testing"
+)
+
+func TestPairSentences(t *testing.T) {
+	sentences := []string{"小兔醒了。", "它去找妈妈。"}
+	targets := parseAlignedLines("1｜The bunny woke up.\n**2 | It went to find its mom.**\n3｜多余的一行")
+
+	pairs, missing := pairSentences(sentences, targets)
+	if missing != 0 || pairs[1].Target != "It went to find its mom." {
+		t.Fatalf("对照配对错误: %+v, 缺少 %d 句", pairs, missing)
+	}
+
+	text := &ParallelText{
+		SourceLanguage: common.LANG_ZH_HANS,
+		TargetLanguage: common.LANG_EN,
+		Sections:       []Section{{Index: 0, Pairs: pairs}},
+	}
+	if source := text.Text(SIDE_SOURCE); source != "小兔醒了。它去找妈妈。" {
+		t.Errorf("原文拼接错误: %s", source)
+	}
+	if target := text.Text(SIDE_TARGET); target != "The bunny woke up. It went to find its mom." {
+		t.Errorf("译文拼接错误: %s", target)
+	}
+
+	if _, missing := pairSentences(sentences, map[int]string{1: "only one"}); missing != 1 {
+		t.Errorf("缺少的对照数错误: %d", missing)
+	}
+}
+
+func TestValidateTarget(t *testing.T) {
+	if err := ValidateTarget(common.LANG_EN, TARGET_PINYIN); err == nil {
+		t.Error("英文故事不应允许拼音对照")
+	}
+	if err := ValidateTarget(common.LANG_ZH_HANS, "zh-CN"); err == nil {
+		t.Error("对照语言与故事语言相同时应当报错")
+	}
+	if err := ValidateTarget(common.LANG_ZH_HANS, common.LANG_EN); err != nil {
+		t.Errorf("合法的对照语言被拒绝: %v", err)
+	}
+}
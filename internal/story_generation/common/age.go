@@ -0,0 +1,126 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AgeProfile 年龄段的阅读水平设定
+type AgeProfile struct {
+	Key               string   `json:"key" yaml:"key"`                                 // 年龄段标识，如 3-5
+	Label             string   `json:"label" yaml:"label"`                             // 年龄段名称，如 3-5岁
+	VocabularyLevel   string   `json:"vocabulary_level" yaml:"vocabulary_level"`       // 用词要求
+	MaxSentenceLength int      `json:"max_sentence_length" yaml:"max_sentence_length"` // 单句最大字数
+	MaxRareCharRatio  float64  `json:"max_rare_char_ratio" yaml:"max_rare_char_ratio"` // 非常用字最大比例
+	AllowedThemes     []string `json:"allowed_themes" yaml:"allowed_themes"`
+	AvoidThemes       []string `json:"avoid_themes" yaml:"avoid_themes"`
+	Characters        int      `json:"characters" yaml:"characters"`             // 角色数量
+	OutlineSections   int      `json:"outline_sections" yaml:"outline_sections"` // 大纲段落数
+	TargetLength      int      `json:"target_length" yaml:"target_length"`       // 故事总字数
+}
+
+// DEFAULT_AGE_GROUP 未指定或无法识别年龄段时使用的年龄段
+const DEFAULT_AGE_GROUP = "6-8"
+
+// AGE_PROFILES 各年龄段的设定
+var AGE_PROFILES = map[string]AgeProfile{
+	"0-3": {
+		Key:               "0-3",
+		Label:             "0-3岁",
+		VocabularyLevel:   "只用最常见的口语词汇，多用叠词和拟声词，不用成语",
+		MaxSentenceLength: 10,
+		MaxRareCharRatio:  0.15,
+		AllowedThemes:     []string{"家人", "小动物", "颜色", "形状", "日常习惯"},
+		AvoidThemes:       []string{"冲突", "危险", "分离", "黑暗", "死亡"},
+		Characters:        2,
+		OutlineSections:   3,
+		TargetLength:      200,
+	},
+	"3-5": {
+		Key:               "3-5",
+		Label:             "3-5岁",
+		VocabularyLevel:   "用简单常见的词汇，可以少量使用拟声词，避免成语和书面语",
+		MaxSentenceLength: 15,
+		MaxRareCharRatio:  0.2,
+		AllowedThemes:     []string{"友谊", "分享", "勇气", "好奇心", "生活习惯"},
+		AvoidThemes:       []string{"暴力", "死亡", "恐怖"},
+		Characters:        2,
+		OutlineSections:   4,
+		TargetLength:      500,
+	},
+	"6-8": {
+		Key:               "6-8",
+		Label:             "6-8岁",
+		VocabularyLevel:   "用词生动易懂，可以使用少量常见成语",
+		MaxSentenceLength: 25,
+		MaxRareCharRatio:  0.3,
+		AllowedThemes:     []string{"冒险", "友谊", "合作", "诚实", "自然科学"},
+		AvoidThemes:       []string{"暴力", "恐怖"},
+		Characters:        3,
+		OutlineSections:   5,
+		TargetLength:      1000,
+	},
+	"9-12": {
+		Key:               "9-12",
+		Label:             "9-12岁",
+		VocabularyLevel:   "用词丰富，可以使用成语和适度的修辞",
+		MaxSentenceLength: 35,
+		MaxRareCharRatio:  0.4,
+		AllowedThemes:     []string{"冒险", "成长", "责任", "历史", "科学探索", "悬疑"},
+		AvoidThemes:       []string{"血腥暴力"},
+		Characters:        4,
+		OutlineSections:   6,
+		TargetLength:      2000,
+	},
+}
+
+// GetAgeProfile 根据年龄段描述获取设定，支持 "3-5"、"3-5岁"、"3~5岁"、"3到5岁" 等写法
+// 无法识别时返回 DEFAULT_AGE_GROUP 对应的设定
+func GetAgeProfile(ageGroup string) AgeProfile {
+	if profile, ok := AGE_PROFILES[normalizeAgeGroup(ageGroup)]; ok {
+		return profile
+	}
+	return AGE_PROFILES[DEFAULT_AGE_GROUP]
+}
+
+// IsValidAgeGroup 年龄段描述能否识别为 AGE_PROFILES 中的年龄段
+func IsValidAgeGroup(ageGroup string) bool {
+	_, ok := AGE_PROFILES[normalizeAgeGroup(ageGroup)]
+	return ok
+}
+
+// 将年龄段描述规范化为 AGE_PROFILES 的键；只给出单个年龄时返回包含该年龄的年龄段
+func normalizeAgeGroup(ageGroup string) string {
+	numbers := regexp.MustCompile(`\d+`).FindAllString(ageGroup, -1)
+	if len(numbers) >= 2 {
+		key := numbers[0] + "-" + numbers[1]
+		if _, ok := AGE_PROFILES[key]; ok {
+			return key
+		}
+	}
+	if len(numbers) >= 1 {
+		var age int
+		fmt.Sscanf(numbers[0], "%d", &age)
+		switch {
+		case age < 3:
+			return "0-3"
+		case age < 6:
+			return "3-5"
+		case age < 9:
+			return "6-8"
+		default:
+			return "9-12"
+		}
+	}
+	return strings.TrimSpace(ageGroup)
+}
+
+// Guidance 生成注入提示词的年龄段要求
+func (p AgeProfile) Guidance() string {
+	guidance := fmt.Sprintf("适合%s儿童阅读，%s，每句话不超过%d个字", p.Label, p.VocabularyLevel, p.MaxSentenceLength)
+	if len(p.AvoidThemes) > 0 {
+		guidance += "，不要涉及" + strings.Join(p.AvoidThemes, "、")
+	}
+	return guidance
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"strings"
+)
+
+// CharacterProfile 角色设定（角色圣经）中单个角色的结构化信息
+type CharacterProfile struct {
+	Name          string   `json:"name" yaml:"name"`
+	Species       string   `json:"species,omitempty" yaml:"species,omitempty"`
+	Appearance    string   `json:"appearance,omitempty" yaml:"appearance,omitempty"`
+	Personality   string   `json:"personality,omitempty" yaml:"personality,omitempty"`
+	SpeakingStyle string   `json:"speaking_style,omitempty" yaml:"speaking_style,omitempty"`
+	Relationships []string `json:"relationships,omitempty" yaml:"relationships,omitempty"`
+	Voice         string   `json:"voice,omitempty" yaml:"voice,omitempty"`
+}
+
+// CharacterIssue 一致性检查发现的角色设定矛盾
+type CharacterIssue struct {
+	SectionIndex int    `json:"section_index" yaml:"section_index"`
+	Character    string `json:"character" yaml:"character"`
+	Description  string `json:"description" yaml:"description"`
+}
+
+// FormatCharacterBible 将角色设定压缩为每个角色一行的文本，用于注入提示词
+// 例如：小白｜兔子｜外貌：雪白的毛｜性格：胆小善良｜说话：轻声细语｜关系：猫头鹰爷爷的朋友
+func FormatCharacterBible(characters []CharacterProfile) string {
+	var lines []string
+	for _, character := range characters {
+		parts := []string{character.Name}
+		if character.Species != "" {
+			parts = append(parts, character.Species)
+		}
+		if character.Appearance != "" {
+			parts = append(parts, "外貌："+character.Appearance)
+		}
+		if character.Personality != "" {
+			parts = append(parts, "性格："+character.Personality)
+		}
+		if character.SpeakingStyle != "" {
+			parts = append(parts, "说话："+character.SpeakingStyle)
+		}
+		if len(character.Relationships) > 0 {
+			parts = append(parts, "关系："+strings.Join(character.Relationships, "、"))
+		}
+		lines = append(lines, strings.Join(parts, "｜"))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -4,20 +4,37 @@ import (
 	"flutterdreams/config"
 	"flutterdreams/internal/model"
 	"fmt"
+	"time"
 )
 
-// ChatWithModel 根据配置文件选择模型并调用相应的生成函数
+// ChatWithModel 根据配置文件选择模型并调用相应的生成函数，同时累计调用用量
 func ChatWithModel(userContent string) (string, error) {
+	return (*Meter)(nil).ChatWithModel(userContent)
+}
+
+// ChatWithModel 调用默认模型，用量计入 m（见 Meter.RecordCall）
+func (m *Meter) ChatWithModel(userContent string) (string, error) {
+	start := time.Now()
+	response, err := chatWithDefaultModel(userContent)
+	m.RecordCall(userContent, response, time.Since(start), err)
+	return response, err
+}
+
+// 模型调用失败时返回 *UpstreamError
+func chatWithDefaultModel(userContent string) (string, error) {
 	defaultModel := config.GetConfig().DefaultModel // 从配置文件读取默认模型
 
+	var response string
+	var err error
 	switch defaultModel {
 	case "doubao":
-		return model.GenerateStory("", userContent) // 调用 doubao 模型
+		response, err = model.GenerateStory("", userContent) // 调用 doubao 模型
 	case "ollama":
-		return model.ChatWithOllama(userContent) // 调用 ollama 模型
+		response, err = model.ChatWithOllama(userContent) // 调用 ollama 模型
 	case "deepseek":
-		return model.ChatByDeepSeek("", userContent) // 调用 deepseek 模型
+		response, err = model.ChatByDeepSeek("", userContent) // 调用 deepseek 模型
 	default:
 		return "", fmt.Errorf("未定义的模型: %s", defaultModel)
 	}
+	return response, Upstream(defaultModel, err)
 }
@@ -0,0 +1,43 @@
+package common
+
+// Draft 存储单个段落草稿的上下文信息及生成结果
+type Draft struct {
+	Index                 int    `json:"index" yaml:"index"`
+	CurrentSection        string `json:"current_section" yaml:"current_section"`
+	PreOutlineSection     string `json:"pre_outline_section,omitempty" yaml:"pre_outline_section,omitempty"`
+	NextOutlineSection    string `json:"next_outline_section,omitempty" yaml:"next_outline_section,omitempty"`
+	PreContent            string `json:"pre_content,omitempty" yaml:"pre_content,omitempty"`
+	InferAttributesString string `json:"infer_attributes_string" yaml:"infer_attributes_string"`
+	// 压缩后的角色设定，由 FormatCharacterBible 生成
+	CharacterBible  string           `json:"character_bible,omitempty" yaml:"character_bible,omitempty"`
+	CharacterIssues []CharacterIssue `json:"character_issues,omitempty" yaml:"character_issues,omitempty"`
+	// 与当前段落相关的已知事实，由 FormatFacts 生成
+	Facts            string            `json:"facts,omitempty" yaml:"facts,omitempty"`
+	NewFacts         []Fact            `json:"new_facts,omitempty" yaml:"new_facts,omitempty"`
+	ContinuityIssues []ContinuityIssue `json:"continuity_issues,omitempty" yaml:"continuity_issues,omitempty"`
+	// 年龄段要求，由 AgeProfile.Guidance 生成
+	AgeGuidance string `json:"age_guidance,omitempty" yaml:"age_guidance,omitempty"`
+	// 教学要求，由 EducationGoals.Guidance 生成
+	EducationGuidance string `json:"education_guidance,omitempty" yaml:"education_guidance,omitempty"`
+	// 分支模式：读者进入本节点时做出的选择，以及本节点结尾提供的选择
+	IncomingChoices []string `json:"incoming_choices,omitempty" yaml:"incoming_choices,omitempty"`
+	OutgoingChoices []string `json:"outgoing_choices,omitempty" yaml:"outgoing_choices,omitempty"`
+	// 故事语言，决定提示词模板和长度单位
+	Language string `json:"language,omitempty" yaml:"language,omitempty"`
+	// 实验分配的提示词模板变体（阶段 → 变体），记录在故事元数据中而不随草稿保存
+	PromptVariants map[string]string `json:"-" yaml:"-"`
+	// 模型调用的用量计入的 Meter，不随草稿保存
+	Meter *Meter `json:"-" yaml:"-"`
+	// 本段目标长度（中文按字、英文按词），为 0 时不限制
+	TargetLength int    `json:"target_length,omitempty" yaml:"target_length,omitempty"`
+	Content      string `json:"content" yaml:"content"`
+	Scores       Scores `json:"scores" yaml:"scores"`
+}
+
+// Scores 存储 rewrite_module 对段落的各维度打分
+type Scores struct {
+	Coherence float64 `json:"coherence" yaml:"coherence"`
+	Quality   float64 `json:"quality" yaml:"quality"`
+	Fluency   float64 `json:"fluency" yaml:"fluency"`
+	Total     float64 `json:"total" yaml:"total"`
+}
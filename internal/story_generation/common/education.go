@@ -0,0 +1,54 @@
+package common
+
+import (
+	"strings"
+)
+
+// EducationGoals 教学目标：需要在故事中传达的道理或知识，以及需要出现的目标词汇
+type EducationGoals struct {
+	Objectives []string `json:"objectives,omitempty" yaml:"objectives,omitempty"` // 如 分享、数到十、刷牙
+	Vocabulary []string `json:"vocabulary,omitempty" yaml:"vocabulary,omitempty"` // 目标词汇
+}
+
+// NewEducationGoals 根据请求参数创建教学目标，去掉空白项，都为空时返回 nil
+func NewEducationGoals(objectives []string, vocabulary []string) *EducationGoals {
+	goals := &EducationGoals{
+		Objectives: trimNonEmpty(objectives),
+		Vocabulary: trimNonEmpty(vocabulary),
+	}
+	if goals.Empty() {
+		return nil
+	}
+	return goals
+}
+
+// Empty 是否没有任何教学目标
+func (g *EducationGoals) Empty() bool {
+	return g == nil || (len(g.Objectives) == 0 && len(g.Vocabulary) == 0)
+}
+
+// Guidance 生成注入提示词的教学要求
+func (g *EducationGoals) Guidance() string {
+	if g.Empty() {
+		return ""
+	}
+	var parts []string
+	if len(g.Objectives) > 0 {
+		parts = append(parts, "通过情节自然地传达以下学习目标："+strings.Join(g.Objectives, "、"))
+	}
+	if len(g.Vocabulary) > 0 {
+		parts = append(parts, "在故事中使用以下词语："+strings.Join(g.Vocabulary, "、"))
+	}
+	return strings.Join(parts, "；")
+}
+
+func trimNonEmpty(items []string) []string {
+	var result []string
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
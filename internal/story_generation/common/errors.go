@@ -0,0 +1,155 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// 错误类型，接口按类型选择状态码，并在错误响应的 code 中返回
+const (
+	ERROR_VALIDATION = "invalid_request"  // 请求参数无效
+	ERROR_UPSTREAM   = "upstream_error"   // 调用模型、TTS 或图片服务失败
+	ERROR_TIMEOUT    = "upstream_timeout" // 调用外部服务超时
+	ERROR_QUOTA      = "quota_exceeded"   // 超出用量限额
+	ERROR_BLOCKED    = "content_blocked"  // 内容未通过审核
+	ERROR_INTERNAL   = "internal_error"   // 其他错误
+)
+
+// CodedError 带错误类型的错误
+type CodedError interface {
+	error
+	Code() string
+}
+
+// ErrorCode 返回错误的类型：错误链中有 CodedError 时使用其类型，超时返回 ERROR_TIMEOUT，否则为 ERROR_INTERNAL
+func ErrorCode(err error) string {
+	var coded CodedError
+	if errors.As(err, &coded) {
+		return coded.Code()
+	}
+	if IsTimeout(err) {
+		return ERROR_TIMEOUT
+	}
+	return ERROR_INTERNAL
+}
+
+// IsTimeout 判断错误是否由超时导致
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// FieldError 单个请求字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError 请求参数无效，列出全部无效的字段
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Field + " " + field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Code() string {
+	return ERROR_VALIDATION
+}
+
+// Add 记录一个无效字段
+func (e *ValidationError) Add(field string, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// AddAll 以 prefix 为前缀合并 err 中的无效字段，err 不是 *ValidationError 时以 prefix 为字段名
+func (e *ValidationError) AddAll(prefix string, err error) {
+	var other *ValidationError
+	if !errors.As(err, &other) {
+		if err != nil {
+			e.Add(prefix, "%v", err)
+		}
+		return
+	}
+	for _, field := range other.Fields {
+		e.Add(prefix+"."+field.Field, "%s", field.Message)
+	}
+}
+
+// Invalid 只有一个无效字段的 *ValidationError
+func Invalid(field string, format string, args ...interface{}) error {
+	errs := &ValidationError{}
+	errs.Add(field, format, args...)
+	return errs
+}
+
+// Err 没有无效字段时返回 nil，避免返回值为 nil 指针的非 nil error
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// UpstreamError 调用外部服务（模型、TTS、图片生成）失败
+type UpstreamError struct {
+	Service string
+	Err     error
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("调用 %s 失败: %v", e.Service, e.Err)
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// Code 超时返回 ERROR_TIMEOUT，其他失败返回 ERROR_UPSTREAM
+func (e *UpstreamError) Code() string {
+	if IsTimeout(e.Err) {
+		return ERROR_TIMEOUT
+	}
+	return ERROR_UPSTREAM
+}
+
+// Upstream 把调用 service 的错误包装为 *UpstreamError，err 为 nil 或已经带有错误类型时原样返回
+func Upstream(service string, err error) error {
+	var coded CodedError
+	if err == nil || errors.As(err, &coded) {
+		return err
+	}
+	return &UpstreamError{Service: service, Err: err}
+}
+
+// PublicMessage 返回可以展示给客户端的错误信息：内部错误以及外部服务的失败、超时可能包含内部路径、
+// 上游的响应内容等，只返回概括的说明（外部服务只给出服务名），详情由调用方写入日志；其他类型返回原始信息
+func PublicMessage(err error) string {
+	code := ErrorCode(err)
+	var upstream *UpstreamError
+	switch {
+	case code == ERROR_TIMEOUT && errors.As(err, &upstream):
+		return fmt.Sprintf("调用 %s 超时", upstream.Service)
+	case code == ERROR_TIMEOUT:
+		return "调用外部服务超时"
+	case code == ERROR_UPSTREAM && errors.As(err, &upstream):
+		return fmt.Sprintf("调用 %s 失败", upstream.Service)
+	case code == ERROR_UPSTREAM:
+		return "调用外部服务失败"
+	case code == ERROR_INTERNAL:
+		return "内部错误"
+	}
+	return err.Error()
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorCode(t *testing.T) {
+	upstream := Upstream("doubao", errors.New("服务不可用"))
+	cases := []struct {
+		err      error
+		expected string
+	}{
+		{errors.New("未知错误"), ERROR_INTERNAL},
+		{Invalid("premise", "is required"), ERROR_VALIDATION},
+		{upstream, ERROR_UPSTREAM},
+		// 经过多层包装仍能识别错误类型
+		{fmt.Errorf("生成计划信息时出错: %w", fmt.Errorf("无法生成setting: %w", upstream)), ERROR_UPSTREAM},
+		{Upstream("deepseek", fmt.Errorf("error sending HTTP request: %w", context.DeadlineExceeded)), ERROR_TIMEOUT},
+		{context.DeadlineExceeded, ERROR_TIMEOUT},
+	}
+	for _, c := range cases {
+		if code := ErrorCode(c.err); code != c.expected {
+			t.Errorf("%v 的错误类型为 %s，期望 %s", c.err, code, c.expected)
+		}
+	}
+
+	// 已经带有错误类型时不再包装
+	if Upstream("doubao", upstream) != upstream || Upstream("doubao", nil) != nil {
+		t.Error("Upstream 不应重复包装")
+	}
+	invalid := Invalid("premise", "is required")
+	if Upstream("doubao", invalid) != invalid {
+		t.Error("Upstream 不应改变校验错误的类型")
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	errs := &ValidationError{}
+	if errs.Err() != nil {
+		t.Error("没有无效字段时应返回 nil")
+	}
+	errs.Add("premise", "is required")
+	errs.AddAll("items[1]", Invalid("target_length", "must be between 0 and %d", MAX_TARGET_LENGTH))
+	errs.AddAll("items[2]", nil)
+	errs.AddAll("items[3]", errors.New("无效"))
+
+	expected := []FieldError{
+		{Field: "premise", Message: "is required"},
+		{Field: "items[1].target_length", Message: "must be between 0 and 5000"},
+		{Field: "items[3]", Message: "无效"},
+	}
+	if len(errs.Fields) != len(expected) {
+		t.Fatalf("无效字段错误: %+v", errs.Fields)
+	}
+	for i, field := range expected {
+		if errs.Fields[i] != field {
+			t.Errorf("第 %d 个无效字段为 %+v，期望 %+v", i, errs.Fields[i], field)
+		}
+	}
+	if errs.Error() != "premise is required; items[1].target_length must be between 0 and 5000; items[3] 无效" {
+		t.Errorf("错误信息不正确: %s", errs.Error())
+	}
+}
+
+func TestPublicMessage(t *testing.T) {
+	for _, c := range []struct {
+		err      error
+		expected string
+	}{
+		{errors.New("open /var/lib/stories.db: permission denied"), "内部错误"},
+		{fmt.Errorf("生成草稿时出错: %w", Upstream("doubao", errors.New("401 invalid api key sk-123"))), "调用 doubao 失败"},
+		{Upstream("deepseek", context.DeadlineExceeded), "调用 deepseek 超时"},
+		{Invalid("mode", "不支持的续写方式"), "mode 不支持的续写方式"},
+	} {
+		if message := PublicMessage(c.err); message != c.expected {
+			t.Errorf("PublicMessage(%v) = %q，期望 %q", c.err, message, c.expected)
+		}
+	}
+}
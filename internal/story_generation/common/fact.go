@@ -0,0 +1,112 @@
+package common
+
+import (
+	"strings"
+)
+
+// 事实类别
+const (
+	FACT_POSSESSION = "物品" // 物品在谁手里或处于什么状态，主体为物品
+	FACT_LOCATION   = "位置" // 角色在哪里
+	FACT_REVEALED   = "揭示" // 已经揭示的信息
+	FACT_TIME       = "时间" // 故事中的时间
+)
+
+// FACT_CATEGORIES 所有事实类别
+var FACT_CATEGORIES = []string{FACT_POSSESSION, FACT_LOCATION, FACT_REVEALED, FACT_TIME}
+
+// 各语言提示词中使用的事实类别名称，事实本身按简体中文的类别保存；未列出的语言使用类别本身
+var factCategoryNames = map[string]map[string]string{
+	LANG_ZH_HANT: {FACT_POSSESSION: "物品", FACT_LOCATION: "位置", FACT_REVEALED: "揭示", FACT_TIME: "時間"},
+	LANG_EN:      {FACT_POSSESSION: "item", FACT_LOCATION: "location", FACT_REVEALED: "revealed", FACT_TIME: "time"},
+}
+
+// FactCategoryName 事实类别在某个语言的提示词中的名称
+func FactCategoryName(category string, language string) string {
+	if name, ok := factCategoryNames[GetLanguage(language).Code][category]; ok {
+		return name
+	}
+	return category
+}
+
+// ParseFactCategory 将模型输出的类别名称（该语言的名称或简体中文类别，不区分大小写）转换为事实类别
+func ParseFactCategory(name string, language string) (string, bool) {
+	for _, category := range FACT_CATEGORIES {
+		if name == category || strings.EqualFold(name, FactCategoryName(category, language)) {
+			return category, true
+		}
+	}
+	return "", false
+}
+
+// Fact 从段落中提取的一条事实
+type Fact struct {
+	SectionIndex int    `json:"section_index" yaml:"section_index"`
+	Category     string `json:"category" yaml:"category"`
+	Subject      string `json:"subject" yaml:"subject"`
+	Content      string `json:"content" yaml:"content"`
+}
+
+// ContinuityIssue 编辑修正后仍然存在的事实矛盾
+type ContinuityIssue struct {
+	SectionIndex int    `json:"section_index" yaml:"section_index"`
+	Fact         string `json:"fact" yaml:"fact"`
+	Description  string `json:"description" yaml:"description"`
+}
+
+// FactLedger 按段落顺序累积的事实账本
+type FactLedger struct {
+	Facts []Fact `json:"facts" yaml:"facts"`
+}
+
+// Add 追加事实
+func (l *FactLedger) Add(facts ...Fact) {
+	l.Facts = append(l.Facts, facts...)
+}
+
+// Current 返回每个类别、主体的最新事实
+// 物品的主体是物品本身，位置、时间和物品的持有者或状态以后面段落为准；揭示信息按内容累积
+func (l *FactLedger) Current() []Fact {
+	var current []Fact
+	index := make(map[string]int)
+	for _, fact := range l.Facts {
+		key := fact.Category + "|" + fact.Subject
+		if fact.Category == FACT_REVEALED {
+			key += "|" + fact.Content
+		}
+		if i, ok := index[key]; ok {
+			current[i] = fact
+			continue
+		}
+		index[key] = len(current)
+		current = append(current, fact)
+	}
+	return current
+}
+
+// Relevant 返回与给定文本相关的最新事实：主体出现在文本中的事实，以及最新的时间
+func (l *FactLedger) Relevant(texts ...string) []Fact {
+	joined := strings.Join(texts, "\n")
+	var relevant []Fact
+	for _, fact := range l.Current() {
+		if fact.Category != FACT_TIME && fact.Subject != "" && strings.Contains(joined, fact.Subject) {
+			relevant = append(relevant, fact)
+		}
+	}
+	for i := len(l.Facts) - 1; i >= 0; i-- {
+		if l.Facts[i].Category == FACT_TIME {
+			relevant = append(relevant, l.Facts[i])
+			break
+		}
+	}
+	return relevant
+}
+
+// FormatFacts 将事实格式化为每条一行的文本，用于注入提示词，类别使用 language 的名称
+func FormatFacts(facts []Fact, language string) string {
+	var lines []string
+	for _, fact := range facts {
+		lines = append(lines, FactCategoryName(fact.Category, language)+"｜"+fact.Subject+"｜"+fact.Content)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,148 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ROOT_NODE_ID 故事图的起始节点编号
+const ROOT_NODE_ID = 1
+
+// Choice 读者在节点结尾可以做出的选择
+type Choice struct {
+	Text string `json:"text" yaml:"text"`
+	Next int    `json:"next" yaml:"next"` // 选择后进入的节点编号
+}
+
+// StoryNode 分支故事中的一个节点
+type StoryNode struct {
+	ID      int      `json:"id" yaml:"id"`
+	Outline string   `json:"outline" yaml:"outline"`
+	Depth   int      `json:"depth" yaml:"depth"` // 从起始节点出发的最长步数
+	Choices []Choice `json:"choices,omitempty" yaml:"choices,omitempty"`
+	Ending  bool     `json:"ending,omitempty" yaml:"ending,omitempty"`
+	Content string   `json:"content,omitempty" yaml:"content,omitempty"`
+	Scores  Scores   `json:"scores" yaml:"scores"`
+	// 从本节点提取的事实
+	Facts []Fact `json:"facts,omitempty" yaml:"facts,omitempty"`
+}
+
+// StoryGraph 分支故事图：节点按编号排列，选择只能指向编号更大的节点，因此不会成环
+type StoryGraph struct {
+	Root  int         `json:"root" yaml:"root"`
+	Nodes []StoryNode `json:"nodes" yaml:"nodes"`
+}
+
+// Node 按编号查找节点
+func (g *StoryGraph) Node(id int) *StoryNode {
+	for i := range g.Nodes {
+		if g.Nodes[i].ID == id {
+			return &g.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// Parents 返回指向该节点的所有 (父节点, 选择)，按父节点编号排列
+func (g *StoryGraph) Parents(id int) ([]*StoryNode, []Choice) {
+	var parents []*StoryNode
+	var choices []Choice
+	for i := range g.Nodes {
+		for _, choice := range g.Nodes[i].Choices {
+			if choice.Next == id {
+				parents = append(parents, &g.Nodes[i])
+				choices = append(choices, choice)
+			}
+		}
+	}
+	return parents, choices
+}
+
+// Endings 返回所有结局节点
+func (g *StoryGraph) Endings() []*StoryNode {
+	var endings []*StoryNode
+	for i := range g.Nodes {
+		if g.Nodes[i].Ending {
+			endings = append(endings, &g.Nodes[i])
+		}
+	}
+	return endings
+}
+
+// MainPath 从起始节点出发、每次都走第一个选择得到的路径
+func (g *StoryGraph) MainPath() []*StoryNode {
+	var path []*StoryNode
+	for node := g.Node(g.Root); node != nil; {
+		path = append(path, node)
+		if len(node.Choices) == 0 {
+			break
+		}
+		node = g.Node(node.Choices[0].Next)
+	}
+	return path
+}
+
+// Depth 图的最大深度（起始节点为 0）
+func (g *StoryGraph) Depth() int {
+	depth := 0
+	for _, node := range g.Nodes {
+		if node.Depth > depth {
+			depth = node.Depth
+		}
+	}
+	return depth
+}
+
+// Normalize 整理故事图：按编号排序、去掉从起始节点不可达的节点、计算深度、标记结局
+// 然后检查：选择指向存在且编号更大的节点、深度不超过 maxDepth、结局数不超过 maxEndings
+func (g *StoryGraph) Normalize(maxDepth int, maxEndings int) error {
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	if g.Node(g.Root) == nil {
+		return fmt.Errorf("缺少起始节点 %d", g.Root)
+	}
+
+	for _, node := range g.Nodes {
+		for _, choice := range node.Choices {
+			if g.Node(choice.Next) == nil {
+				return fmt.Errorf("节点 %d 的选择指向不存在的节点 %d", node.ID, choice.Next)
+			}
+			if choice.Next <= node.ID {
+				return fmt.Errorf("节点 %d 的选择指向了前面的节点 %d", node.ID, choice.Next)
+			}
+		}
+	}
+
+	// 节点已按编号排序且选择只指向后面的节点，按顺序遍历即可得到最长路径深度
+	reachable := map[int]bool{g.Root: true}
+	depths := map[int]int{g.Root: 0}
+	for _, node := range g.Nodes {
+		if !reachable[node.ID] {
+			continue
+		}
+		for _, choice := range node.Choices {
+			reachable[choice.Next] = true
+			if depths[node.ID]+1 > depths[choice.Next] {
+				depths[choice.Next] = depths[node.ID] + 1
+			}
+		}
+	}
+
+	var nodes []StoryNode
+	for _, node := range g.Nodes {
+		if !reachable[node.ID] {
+			continue
+		}
+		node.Depth = depths[node.ID]
+		node.Ending = len(node.Choices) == 0
+		if node.Depth > maxDepth {
+			return fmt.Errorf("节点 %d 的深度 %d 超过上限 %d", node.ID, node.Depth, maxDepth)
+		}
+		nodes = append(nodes, node)
+	}
+	g.Nodes = nodes
+
+	if endings := len(g.Endings()); endings > maxEndings {
+		return fmt.Errorf("结局数 %d 超过上限 %d", endings, maxEndings)
+	}
+	return nil
+}
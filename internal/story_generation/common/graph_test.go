@@ -0,0 +1,28 @@
+package common
+
+import "testing"
+
+func TestNormalizeRejectsInvalidGraph(t *testing.T) {
+	backward := &StoryGraph{Root: ROOT_NODE_ID, Nodes: []StoryNode{
+		{ID: 1, Choices: []Choice{{Text: "前进", Next: 2}}},
+		{ID: 2, Choices: []Choice{{Text: "回去", Next: 1}}},
+	}}
+	if err := backward.Normalize(5, 3); err == nil {
+		t.Error("指向前面节点的选择应当被拒绝")
+	}
+
+	deep := &StoryGraph{Root: ROOT_NODE_ID, Nodes: []StoryNode{
+		{ID: 1, Choices: []Choice{{Text: "a", Next: 2}}},
+		{ID: 2, Choices: []Choice{{Text: "b", Next: 3}}},
+		{ID: 3},
+	}}
+	if err := deep.Normalize(1, 3); err == nil {
+		t.Error("超过深度上限的故事图应当被拒绝")
+	}
+	if err := deep.Normalize(2, 3); err != nil {
+		t.Errorf("合法的故事图被拒绝: %v", err)
+	}
+	if path := deep.MainPath(); len(path) != 3 || !path[2].Ending {
+		t.Errorf("主线路径错误: %+v", path)
+	}
+}
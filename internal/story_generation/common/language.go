@@ -0,0 +1,151 @@
+package common
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 支持的故事语言
+const (
+	LANG_ZH_HANS = "zh-Hans" // 简体中文
+	LANG_ZH_HANT = "zh-Hant" // 繁体中文
+	LANG_EN      = "en"      // 英文
+)
+
+// DEFAULT_LANGUAGE 未指定或无法识别语言时使用的语言
+const DEFAULT_LANGUAGE = LANG_ZH_HANS
+
+// Language 故事语言的设定
+type Language struct {
+	Code string `json:"code" yaml:"code"`
+	Name string `json:"name" yaml:"name"`
+	// 插入提示词要求列表中的写作语言要求
+	Instruction string `json:"instruction" yaml:"instruction"`
+	// 长度单位：中文按字计，英文按词计
+	LengthUnit string `json:"length_unit" yaml:"length_unit"`
+	// 长度相对中文字数的换算比例，用于换算默认长度和朗读时长
+	LengthRatio float64 `json:"length_ratio" yaml:"length_ratio"`
+	// 拼接段落时使用的分隔符：中文段落直接相连，英文段落之间换行
+	SectionSeparator string `json:"section_separator" yaml:"section_separator"`
+}
+
+// LANGUAGES 各语言的设定
+var LANGUAGES = map[string]Language{
+	LANG_ZH_HANS: {
+		Code:        LANG_ZH_HANS,
+		Name:        "简体中文",
+		Instruction: "用简体中文",
+		LengthUnit:  "字",
+		LengthRatio: 1,
+	},
+	LANG_ZH_HANT: {
+		Code:        LANG_ZH_HANT,
+		Name:        "繁體中文",
+		Instruction: "用繁體中文",
+		LengthUnit:  "字",
+		LengthRatio: 1,
+	},
+	LANG_EN: {
+		Code:        LANG_EN,
+		Name:        "English",
+		Instruction: "Write in English",
+		LengthUnit:  "words",
+		LengthRatio: 0.6, // 给儿童朗读英文约每分钟120词
+		// 段落内容首尾的空白已被去掉，直接相连会把上一段的句号和下一段的首词粘在一起
+		SectionSeparator: "\n",
+	},
+}
+
+// 常见的语言代码别名
+var languageAliases = map[string]string{
+	"zh":      LANG_ZH_HANS,
+	"zh-cn":   LANG_ZH_HANS,
+	"zh-sg":   LANG_ZH_HANS,
+	"zh-hans": LANG_ZH_HANS,
+	"zh-tw":   LANG_ZH_HANT,
+	"zh-hk":   LANG_ZH_HANT,
+	"zh-hant": LANG_ZH_HANT,
+	"en":      LANG_EN,
+	"en-us":   LANG_EN,
+	"en-gb":   LANG_EN,
+	"english": LANG_EN,
+}
+
+// GetLanguage 根据语言代码获取语言设定，大小写和下划线不敏感，无法识别时返回默认语言
+func GetLanguage(code string) Language {
+	key := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "_", "-"))
+	if canonical, ok := languageAliases[key]; ok {
+		return LANGUAGES[canonical]
+	}
+	return LANGUAGES[DEFAULT_LANGUAGE]
+}
+
+// IsSupportedLanguage 语言代码是否可以识别，空字符串视为默认语言
+func IsSupportedLanguage(code string) bool {
+	if strings.TrimSpace(code) == "" {
+		return true
+	}
+	_, ok := languageAliases[strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "_", "-"))]
+	return ok
+}
+
+// IsChinese 是否为中文（简体或繁体）
+func (l Language) IsChinese() bool {
+	return l.Code == LANG_ZH_HANS || l.Code == LANG_ZH_HANT
+}
+
+// CountLength 按该语言的长度单位统计文本长度：中文统计字数，英文统计词数
+func (l Language) CountLength(text string) int {
+	if l.IsChinese() {
+		return CountCharacters(text)
+	}
+	return len(strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\'' && r != '-'
+	}))
+}
+
+// ScaleLength 将以中文字数计的长度换算为该语言的长度单位
+func (l Language) ScaleLength(characters int) int {
+	return int(math.Round(float64(characters) * l.LengthRatio))
+}
+
+// ResolveTargetLength 与 common.ResolveTargetLength 相同，朗读时长按该语言的长度单位换算
+func (l Language) ResolveTargetLength(targetLength int, readingMinutes float64, defaultLength int) int {
+	if targetLength > 0 {
+		return targetLength
+	}
+	if readingMinutes > 0 {
+		return l.ScaleLength(MinutesToCharacters(readingMinutes))
+	}
+	return defaultLength
+}
+
+// ContainsWord 文本中是否出现某个词：中文按字面匹配；其他语言不区分大小写，且只匹配完整的词
+func (l Language) ContainsWord(text string, word string) bool {
+	if word == "" {
+		return false
+	}
+	if l.IsChinese() {
+		return strings.Contains(text, word)
+	}
+	text, word = strings.ToLower(text), strings.ToLower(word)
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], word)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
@@ -0,0 +1,55 @@
+package common
+
+import "testing"
+
+func TestGetLanguage(t *testing.T) {
+	cases := map[string]string{
+		"":        LANG_ZH_HANS,
+		"zh-CN":   LANG_ZH_HANS,
+		"zh_TW":   LANG_ZH_HANT,
+		"zh-Hant": LANG_ZH_HANT,
+		"EN":      LANG_EN,
+		"fr":      DEFAULT_LANGUAGE,
+	}
+	for code, expected := range cases {
+		if language := GetLanguage(code); language.Code != expected {
+			t.Errorf("语言 %q 应识别为 %s，实际为 %s", code, expected, language.Code)
+		}
+	}
+	if IsSupportedLanguage("fr") || !IsSupportedLanguage("") {
+		t.Error("语言支持判断错误")
+	}
+}
+
+func TestCountLength(t *testing.T) {
+	if count := GetLanguage(LANG_EN).CountLength("Benny's carrot-cake is ready, isn't it?"); count != 6 {
+		t.Errorf("英文词数统计错误: %d", count)
+	}
+	if count := GetLanguage(LANG_ZH_HANT).CountLength("小兔說：「你好！」"); count != 5 {
+		t.Errorf("中文字数统计错误: %d", count)
+	}
+}
+
+func TestContainsWord(t *testing.T) {
+	english := GetLanguage(LANG_EN)
+	cases := []struct {
+		text     string
+		word     string
+		expected bool
+	}{
+		{"Share the cake.", "share", true},
+		{"They shared the cake.", "share", false},
+		{"A hare and a share.", "share", true},
+		{"Say THANK YOU!", "thank you", true},
+		{"The war-horse ran.", "war", true},
+		{"Look both ways.", "", false},
+	}
+	for _, c := range cases {
+		if got := english.ContainsWord(c.text, c.word); got != c.expected {
+			t.Errorf("ContainsWord(%q, %q) = %v，期望 %v", c.text, c.word, got, c.expected)
+		}
+	}
+	if !GetLanguage(LANG_ZH_HANS).ContainsWord("小熊把蜂蜜分享给了小兔", "分享") {
+		t.Error("中文应按字面匹配")
+	}
+}
@@ -0,0 +1,112 @@
+package common
+
+import (
+	"math"
+	"unicode"
+)
+
+const (
+	READING_CHARS_PER_MINUTE = 200  // 给儿童朗读的语速，单位：字/分钟
+	LENGTH_TOLERANCE         = 0.2  // 段落字数允许偏离预算的比例
+	MAX_TARGET_LENGTH        = 5000 // 请求中目标字数（英文为词数）的上限
+	MAX_READING_MINUTES      = 30   // 请求中朗读分钟数的上限
+)
+
+// CountCharacters 统计文本字数，不计空白和标点
+func CountCharacters(text string) int {
+	count := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			count++
+		}
+	}
+	return count
+}
+
+// MinutesToCharacters 将朗读时长换算为字数
+func MinutesToCharacters(minutes float64) int {
+	return int(math.Round(minutes * READING_CHARS_PER_MINUTE))
+}
+
+// EstimateAudioSeconds 按朗读语速估算文本的音频时长：汉字按 READING_CHARS_PER_MINUTE 字/分钟，
+// 其他文字按英文每分钟约 120 词计
+func EstimateAudioSeconds(text string) int {
+	han, words, inWord := 0, 0, false
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if !inWord {
+				words++
+			}
+			inWord = true
+		default:
+			inWord = false
+		}
+	}
+	minutes := float64(han)/READING_CHARS_PER_MINUTE + float64(words)/(READING_CHARS_PER_MINUTE*0.6)
+	return int(math.Ceil(minutes * 60))
+}
+
+// ResolveTargetLength 根据字数或朗读时长确定目标字数，都未指定时使用默认值
+func ResolveTargetLength(targetLength int, readingMinutes float64, defaultLength int) int {
+	if targetLength > 0 {
+		return targetLength
+	}
+	if readingMinutes > 0 {
+		return MinutesToCharacters(readingMinutes)
+	}
+	return defaultLength
+}
+
+// ValidateTargetLength 检查请求中的目标字数与朗读分钟数，不能为负数或超过上限
+func ValidateTargetLength(errs *ValidationError, targetLength int, readingMinutes float64) {
+	if targetLength < 0 || targetLength > MAX_TARGET_LENGTH {
+		errs.Add("target_length", "must be between 0 and %d", MAX_TARGET_LENGTH)
+	}
+	if readingMinutes < 0 || readingMinutes > MAX_READING_MINUTES {
+		errs.Add("reading_minutes", "must be between 0 and %d", MAX_READING_MINUTES)
+	}
+}
+
+// DistributeLength 将总字数平均分配到各段落，余数依次分给前面的段落
+// total 为 0 时表示不限制字数，返回全 0 的预算
+func DistributeLength(total int, sections int) []int {
+	budgets := make([]int, sections)
+	if total <= 0 || sections == 0 {
+		return budgets
+	}
+	for i := range budgets {
+		budgets[i] = total / sections
+		if i < total%sections {
+			budgets[i]++
+		}
+	}
+	return budgets
+}
+
+// WithinBudget 判断字数是否在预算的容差范围内，budget 为 0 时总是返回 true
+func WithinBudget(count int, budget int) bool {
+	if budget <= 0 {
+		return true
+	}
+	deviation := math.Abs(float64(count-budget)) / float64(budget)
+	return deviation <= LENGTH_TOLERANCE
+}
+
+// TruncateBytes 在不超过 maxBytes 字节的前提下按字符边界截断文本
+func TruncateBytes(text string, maxBytes int) string {
+	if len(text) <= maxBytes {
+		return text
+	}
+	end := 0
+	for i := range text {
+		if i > maxBytes {
+			break
+		}
+		end = i
+	}
+	return text[:end]
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountCharacters(t *testing.T) {
+	if count := CountCharacters("小白说：“你好！” 123"); count != 8 {
+		t.Errorf("字数统计错误: %d", count)
+	}
+}
+
+func TestDistributeLength(t *testing.T) {
+	budgets := DistributeLength(1000, 3)
+	if budgets[0] != 334 || budgets[1] != 333 || budgets[2] != 333 {
+		t.Errorf("字数分配错误: %v", budgets)
+	}
+	for _, budget := range DistributeLength(0, 3) {
+		if budget != 0 {
+			t.Errorf("未指定总字数时不应分配预算: %d", budget)
+		}
+	}
+}
+
+func TestWithinBudget(t *testing.T) {
+	if !WithinBudget(110, 100) || WithinBudget(130, 100) || WithinBudget(70, 100) {
+		t.Error("字数容差判断错误")
+	}
+}
+
+func TestResolveTargetLength(t *testing.T) {
+	if ResolveTargetLength(800, 2, 600) != 800 {
+		t.Error("应优先使用目标字数")
+	}
+	if ResolveTargetLength(0, 2, 600) != 2*READING_CHARS_PER_MINUTE {
+		t.Error("应按朗读时长换算字数")
+	}
+	if ResolveTargetLength(0, 0, 600) != 600 {
+		t.Error("应使用默认字数")
+	}
+}
+
+func TestTruncateBytes(t *testing.T) {
+	// 每个汉字占 3 个字节，5 个字节只能保留 1 个汉字
+	if truncated := TruncateBytes("小白兔", 5); truncated != "小" {
+		t.Errorf("截断错误: %q", truncated)
+	}
+	if truncated := TruncateBytes("小白兔", 9); truncated != "小白兔" {
+		t.Errorf("不应截断: %q", truncated)
+	}
+}
+
+func TestSplitSentences(t *testing.T) {
+	sentences := SplitSentences("小兔说：“我们走吧！”大家都笑了。Owl waved. It was 3.5 km away")
+	expected := []string{"小兔说：“我们走吧！”", "大家都笑了。", "Owl waved.", "It was 3.5 km away"}
+	if len(sentences) != len(expected) {
+		t.Fatalf("句子切分错误: %q", sentences)
+	}
+	for i := range expected {
+		if sentences[i] != expected[i] {
+			t.Errorf("第 %d 句切分错误: %q", i, sentences[i])
+		}
+	}
+}
+
+func TestEstimateAudioSeconds(t *testing.T) {
+	cases := map[string]int{
+		strings.Repeat("小兔子", 100):               90, // 300 字，1.5 分钟
+		strings.Repeat("the little rabbit ", 40): 60, // 120 词，1 分钟
+		"":                                       0,
+	}
+	for text, expected := range cases {
+		if seconds := EstimateAudioSeconds(text); seconds != expected {
+			t.Errorf("EstimateAudioSeconds(%.20q) = %d，期望 %d", text, seconds, expected)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package common
+
+// 生成进度的阶段
+const (
+	PROGRESS_PLAN       = "plan"       // 生成故事计划
+	PROGRESS_DRAFT      = "draft"      // 逐段（分支故事逐个节点）生成草稿
+	PROGRESS_MODERATION = "moderation" // 审核生成的故事文本
+	PROGRESS_FINISH     = "finish"     // 双语对照、拼音、学习单等收尾工作
+	PROGRESS_DONE       = "done"       // 生成完成
+)
+
+// Progress 一次进度更新：阶段、阶段内已完成和总共的步骤数，以及刚完成的段落内容
+type Progress struct {
+	Stage string `json:"stage"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+	// 草稿阶段刚完成的段落（节点）序号和内容，可作为部分结果先行展示
+	Index   int    `json:"index,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// ProgressFunc 接收进度更新的回调，为 nil 时不报告进度
+type ProgressFunc func(Progress)
+
+// Report 报告进度，回调为 nil 时什么也不做
+func (f ProgressFunc) Report(progress Progress) {
+	if f != nil {
+		f(progress)
+	}
+}
@@ -0,0 +1,39 @@
+package common
+
+import (
+	"strings"
+)
+
+// 句末标点与可能紧跟在句末标点后的右引号、右括号
+const (
+	sentenceEnders   = "。！？!?…"
+	sentenceClosings = "”’」』\"')）"
+)
+
+// SplitSentences 按句末标点切分句子，保留标点和紧随其后的右引号；适用于中文和英文
+// 英文句点只有后面跟空白或文本结束时才视为句末，避免切开缩写和小数
+func SplitSentences(text string) []string {
+	var sentences []string
+	runes := []rune(text)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		isEnd := strings.ContainsRune(sentenceEnders, r) ||
+			(r == '.' && (i+1 == len(runes) || runes[i+1] == ' ' || runes[i+1] == '\n' || strings.ContainsRune(sentenceClosings, runes[i+1])))
+		if !isEnd {
+			continue
+		}
+		// 连续的句末标点和右引号归入同一句
+		for i+1 < len(runes) && (strings.ContainsRune(sentenceEnders, runes[i+1]) || runes[i+1] == '.' || strings.ContainsRune(sentenceClosings, runes[i+1])) {
+			i++
+		}
+		if sentence := strings.TrimSpace(string(runes[start : i+1])); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = i + 1
+	}
+	if rest := strings.TrimSpace(string(runes[start:])); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
@@ -0,0 +1,143 @@
+package common
+
+import (
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// 非汉字文本平均每个 token 的字节数，用于估算 token 数
+	BYTES_PER_TOKEN = 4
+)
+
+// Usage 模型调用的累计用量及解析失败次数
+type Usage struct {
+	Calls    int `json:"calls"`
+	Failures int `json:"failures"`
+	// 按提示词和回复文本估算的 token 数
+	PromptTokens     int   `json:"prompt_tokens"`
+	CompletionTokens int   `json:"completion_tokens"`
+	DurationMs       int64 `json:"duration_ms"`
+	// 各阶段模型回复无法解析的次数，键为 "<阶段>/<内容>"，如 plan/outline
+	ParseFailures map[string]int `json:"parse_failures,omitempty"`
+}
+
+var (
+	usageMu sync.Mutex
+	usage   = Usage{ParseFailures: make(map[string]int)}
+)
+
+// Meter 统计一次生成（一个请求或任务）中模型调用的用量。生成流程通过各阶段的选项把 Meter 传到每次模型调用，
+// 只有经由该 Meter 的调用才计入，并发的生成互不影响；方法可以在 nil 上调用，此时只计入进程的累计用量
+type Meter struct {
+	mu    sync.Mutex
+	usage Usage
+}
+
+// NewMeter 开始计量
+func NewMeter() *Meter {
+	return &Meter{}
+}
+
+// Usage 已计量的调用次数和 token 数；m 为 nil 时返回零值
+func (m *Meter) Usage() Usage {
+	if m == nil {
+		return Usage{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+// RecordCall 记录一次模型调用，计入进程的累计用量，m 不为 nil 时同时计入 m
+func (m *Meter) RecordCall(prompt string, response string, duration time.Duration, err error) {
+	call := RecordCall(prompt, response, duration, err)
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage.Calls += call.Calls
+	m.usage.Failures += call.Failures
+	m.usage.PromptTokens += call.PromptTokens
+	m.usage.CompletionTokens += call.CompletionTokens
+	m.usage.DurationMs += call.DurationMs
+}
+
+// CurrentUsage 进程启动以来的累计用量，调用前后各取一次相减即可得到一段时间内的用量
+func CurrentUsage() Usage {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	snapshot := usage
+	snapshot.ParseFailures = make(map[string]int, len(usage.ParseFailures))
+	for key, count := range usage.ParseFailures {
+		snapshot.ParseFailures[key] = count
+	}
+	return snapshot
+}
+
+// Sub 两次快照之间的用量
+func (u Usage) Sub(before Usage) Usage {
+	diff := Usage{
+		Calls:            u.Calls - before.Calls,
+		Failures:         u.Failures - before.Failures,
+		PromptTokens:     u.PromptTokens - before.PromptTokens,
+		CompletionTokens: u.CompletionTokens - before.CompletionTokens,
+		DurationMs:       u.DurationMs - before.DurationMs,
+		ParseFailures:    make(map[string]int),
+	}
+	for key, count := range u.ParseFailures {
+		if count > before.ParseFailures[key] {
+			diff.ParseFailures[key] = count - before.ParseFailures[key]
+		}
+	}
+	return diff
+}
+
+// TotalParseFailures 各阶段解析失败次数之和
+func (u Usage) TotalParseFailures() int {
+	total := 0
+	for _, count := range u.ParseFailures {
+		total += count
+	}
+	return total
+}
+
+// RecordParseFailure 记录一次模型回复无法解析
+func RecordParseFailure(stage string) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	usage.ParseFailures[stage]++
+}
+
+// RecordCall 记录一次模型调用并返回其用量，只计入进程的累计用量；属于某次生成的调用应使用 Meter.RecordCall
+func RecordCall(prompt string, response string, duration time.Duration, err error) Usage {
+	call := Usage{Calls: 1, PromptTokens: EstimateTokens(prompt), DurationMs: duration.Milliseconds()}
+	if err != nil {
+		call.Failures = 1
+	} else {
+		call.CompletionTokens = EstimateTokens(response)
+	}
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	usage.Calls += call.Calls
+	usage.Failures += call.Failures
+	usage.PromptTokens += call.PromptTokens
+	usage.CompletionTokens += call.CompletionTokens
+	usage.DurationMs += call.DurationMs
+	return call
+}
+
+// EstimateTokens 估算文本的 token 数：每个汉字按一个 token 计，其余文本按 BYTES_PER_TOKEN 字节一个 token 计
+func EstimateTokens(text string) int {
+	han, other := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			han++
+		} else if !unicode.IsSpace(r) {
+			other += len(string(r))
+		}
+	}
+	return han + (other+BYTES_PER_TOKEN-1)/BYTES_PER_TOKEN
+}
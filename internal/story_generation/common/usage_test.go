@@ -0,0 +1,52 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	if tokens := EstimateTokens("小兔子 hello!"); tokens != 5 {
+		t.Errorf("估算 token 数错误: %d", tokens)
+	}
+	if EstimateTokens("") != 0 {
+		t.Error("空文本的 token 数应为 0")
+	}
+}
+
+func TestUsageSub(t *testing.T) {
+	before := CurrentUsage()
+	RecordCall("你好", "小兔子", 0, nil)
+	RecordParseFailure("plan/outline")
+	diff := CurrentUsage().Sub(before)
+	if diff.Calls != 1 || diff.PromptTokens != 2 || diff.CompletionTokens != 3 || diff.ParseFailures["plan/outline"] != 1 {
+		t.Errorf("用量差值错误: %+v", diff)
+	}
+	if diff.TotalParseFailures() != 1 {
+		t.Errorf("解析失败次数错误: %d", diff.TotalParseFailures())
+	}
+}
+
+func TestMeter(t *testing.T) {
+	first, second := NewMeter(), NewMeter()
+	before := CurrentUsage()
+	first.RecordCall("你好", "小兔子", 0, nil)
+	// 并发生成时各自只计入自己的调用
+	second.RecordCall("一二三四", "小兔子跳", 0, nil)
+	first.RecordCall("你好", "", 0, errors.New("超时"))
+	var none *Meter
+	none.RecordCall("你好", "小兔子", 0, nil)
+
+	if usage := first.Usage(); usage.Calls != 2 || usage.Failures != 1 || usage.PromptTokens != 4 || usage.CompletionTokens != 3 {
+		t.Errorf("第一个 Meter 的用量错误: %+v", usage)
+	}
+	if usage := second.Usage(); usage.Calls != 1 || usage.PromptTokens != 4 || usage.CompletionTokens != 4 {
+		t.Errorf("第二个 Meter 的用量错误: %+v", usage)
+	}
+	if diff := CurrentUsage().Sub(before); diff.Calls != 4 || diff.PromptTokens != 10 {
+		t.Errorf("累计用量应包含全部调用: %+v", diff)
+	}
+	if none.Usage().Calls != 0 {
+		t.Error("nil Meter 的用量应为零值")
+	}
+}
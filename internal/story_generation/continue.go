@@ -0,0 +1,183 @@
+package story_generation
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/draft_module"
+	"flutterdreams/internal/story_generation/experiment_module"
+	"flutterdreams/internal/story_generation/moderation_module"
+	"flutterdreams/internal/story_generation/plan_module"
+	"flutterdreams/internal/story_generation/story_document"
+	"fmt"
+	"time"
+)
+
+// 续写方式
+const (
+	CONTINUE_MODE_CONTINUATION = "continuation" // 在原故事后追加新的段落
+	CONTINUE_MODE_SEQUEL       = "sequel"       // 同样的角色和背景，新的故事
+)
+
+// ContinueOptions 续写故事时的可选参数
+type ContinueOptions struct {
+	// 续写方式，为空时按 CONTINUE_MODE_CONTINUATION 处理
+	Mode string
+	// 续集的故事前提，为空时由模型根据原故事构思，续写时忽略
+	Premise string
+	// 新增内容的目标字数，为 0 时使用原故事的目标字数
+	TargetLength int
+	// 是否为新故事生成配套学习单
+	ActivitySheet bool
+	// 请求 ID，用于确定性地分配提示词实验变体
+	RequestID string
+	// 各阶段的进度回调，为 nil 时不报告进度
+	Progress common.ProgressFunc
+	// 模型调用的用量计入的 Meter，为 nil 时只计入进程的累计用量
+	Meter *common.Meter
+}
+
+// ContinueStoryDocument 基于已有的故事文档生成续写或续集
+// 新文档沿用原故事的角色设定（包括配音）、年龄段、教学目标和双语对照语言，并以原故事确立的事实作为已知事实
+func ContinueStoryDocument(previous *story_document.StoryDocument, options ContinueOptions) (*story_document.StoryDocument, error) {
+	startTime := time.Now()
+	ageProfile := ageProfileFor(previous.AgeGroup)
+	storyOptions := StoryOptions{
+		TargetLength:  options.TargetLength,
+		AgeGroup:      previous.AgeGroup,
+		Goals:         previous.Goals,
+		ActivitySheet: options.ActivitySheet,
+		Language:      common.GetLanguage(previous.Language).Code,
+		// 原故事带拼音标注时，新故事同样标注
+		Pinyin:    previous.Pinyin != nil,
+		RequestID: options.RequestID,
+		Progress:  options.Progress,
+		Meter:     options.Meter,
+	}
+	if previous.ParallelText != nil {
+		// 原故事带双语对照时，新故事的全部段落重新对齐
+		storyOptions.BilingualTarget = previous.ParallelText.TargetLanguage
+	}
+	storyOptions.assignments = experiment_module.Assign(options.RequestID)
+	variants := experiment_module.Variants(storyOptions.assignments)
+	if storyOptions.TargetLength <= 0 {
+		storyOptions.TargetLength = previous.Metadata.TargetLength
+	}
+	if storyOptions.TargetLength <= 0 {
+		storyOptions.TargetLength = common.GetLanguage(previous.Language).ScaleLength(DEFAULT_TARGET_LENGTH)
+	}
+
+	planInfo := previous.ToPlanInfo()
+	facts := previous.FactLedger().Current()
+	planOptions := plan_module.PlanOptions{AgeProfile: ageProfile, Goals: previous.Goals, Language: storyOptions.Language, PromptVariants: variants, Meter: options.Meter}
+	draftOptions := draft_module.DraftOptions{
+		Characters:     planInfo.CharacterBible,
+		Facts:          facts,
+		TargetLength:   storyOptions.TargetLength,
+		AgeProfile:     ageProfile,
+		Goals:          previous.Goals,
+		Language:       storyOptions.Language,
+		PromptVariants: variants,
+		Progress:       options.Progress,
+		Meter:          options.Meter,
+	}
+
+	var doc *story_document.StoryDocument
+	switch options.Mode {
+	case "", CONTINUE_MODE_CONTINUATION:
+		if previous.Graph != nil {
+			return nil, common.Invalid("mode", "分支故事不支持续写，请使用续集")
+		}
+		if len(previous.Sections) == 0 {
+			return nil, common.Invalid("document", "故事文档没有段落，无法续写")
+		}
+		last := previous.Sections[len(previous.Sections)-1]
+
+		options.Progress.Report(common.Progress{Stage: common.PROGRESS_PLAN, Total: 1})
+		outline, err := plan_module.GenerateContinuationOutline(planInfo, last.Content, common.FormatFacts(facts, storyOptions.Language), planOptions)
+		if err != nil {
+			return nil, fmt.Errorf("生成续写大纲时出错: %w", err)
+		}
+		options.Progress.Report(common.Progress{Stage: common.PROGRESS_PLAN, Done: 1, Total: 1})
+		draftOptions.StartIndex = len(previous.Sections)
+		draftOptions.PreOutlineSection = last.Outline
+		draftOptions.PreContent = last.Content
+		drafts, err := draft_module.GenerateDraftSections(planInfo.InferAttributesString, outline, draftOptions)
+		if err != nil {
+			return nil, fmt.Errorf("生成续写草稿时出错: %w", err)
+		}
+
+		// 新段落逐段审核，审核后的段落接在原故事之后，最终文本由全部段落重新拼接
+		options.Progress.Report(common.Progress{Stage: common.PROGRESS_MODERATION})
+		decisions, err := moderateDrafts(drafts, storyOptions.Language, options.Meter)
+		if err != nil {
+			return nil, err
+		}
+
+		doc = continuationOf(previous)
+		doc.Outline = append(doc.Outline, outline...)
+		doc.AppendDrafts(drafts)
+		doc.FinalText = doc.JoinSections()
+		doc.Moderation = append(doc.Moderation, decisions...)
+	case CONTINUE_MODE_SEQUEL:
+		premise, inputDecision, err := moderation_module.Moderate(moderation_module.STAGE_INPUT, options.Premise, storyOptions.Language, options.Meter)
+		if err != nil {
+			return nil, err
+		}
+		options.Progress.Report(common.Progress{Stage: common.PROGRESS_PLAN, Total: 1})
+		sequelPlan, err := plan_module.GenerateSequelPlan(planInfo, premise, common.FormatFacts(facts, storyOptions.Language), planOptions)
+		if err != nil {
+			return nil, fmt.Errorf("生成续集计划时出错: %w", err)
+		}
+		options.Progress.Report(common.Progress{Stage: common.PROGRESS_PLAN, Done: 1, Total: 1})
+		drafts, err := draft_module.GenerateDraftSections(sequelPlan.InferAttributesString, sequelPlan.OutlineSections, draftOptions)
+		if err != nil {
+			return nil, fmt.Errorf("生成续集草稿时出错: %w", err)
+		}
+
+		options.Progress.Report(common.Progress{Stage: common.PROGRESS_MODERATION})
+		outputDecisions, err := moderateDrafts(drafts, storyOptions.Language, options.Meter)
+		if err != nil {
+			return nil, err
+		}
+		doc = story_document.NewStoryDocument(sequelPlan, drafts)
+		doc.ParentID = previous.ID
+		doc.Moderation = append(doc.Moderation, inputDecision)
+		doc.Moderation = append(doc.Moderation, outputDecisions...)
+		planInfo = sequelPlan
+	default:
+		return nil, common.Invalid("mode", "不支持的续写方式: %s", options.Mode)
+	}
+
+	finishDocument(doc, planInfo.InferAttributesString, ageProfile, storyOptions, startTime)
+	options.Progress.Report(common.Progress{Stage: common.PROGRESS_DONE})
+	return doc, nil
+}
+
+// 复制原故事文档作为续写的基础：新的 ID，清空与全文相关的检查结果和媒体
+func continuationOf(previous *story_document.StoryDocument) *story_document.StoryDocument {
+	doc := *previous
+	doc.ID = story_document.NewID()
+	doc.ParentID = previous.ID
+	doc.Outline = append([]string(nil), previous.Outline...)
+	doc.Sections = append([]story_document.Section(nil), previous.Sections...)
+	doc.Moderation = append([]moderation_module.Decision(nil), previous.Moderation...)
+	doc.Media = nil
+	doc.Readability = nil
+	doc.Coverage = nil
+	doc.ActivitySheet = nil
+	doc.ParallelText = nil
+	doc.Pinyin = nil
+	doc.Metadata = story_document.Metadata{
+		Generator: story_document.GENERATOR,
+		CreatedAt: time.Now(),
+	}
+	return &doc
+}
+
+// 根据年龄段获取年龄段设定，为空时不做年龄段适配
+func ageProfileFor(ageGroup string) *common.AgeProfile {
+	if ageGroup == "" {
+		return nil
+	}
+	profile := common.GetAgeProfile(ageGroup)
+	return &profile
+}
@@ -3,6 +3,8 @@ package draft_module
 import (
 	"flutterdreams/internal/story_generation/common"
 	"flutterdreams/internal/story_generation/edit_module"
+	"flutterdreams/internal/story_generation/fact_module"
+	"flutterdreams/internal/story_generation/prompt_module"
 	"flutterdreams/internal/story_generation/rewrite_module"
 	"fmt"
 	"log"
@@ -17,23 +19,79 @@ const (
 	MAX_CANDIDATE_SIZE = 2
 )
 
+// DraftOptions 生成草稿时的可选上下文
+type DraftOptions struct {
+	// 角色设定，非空时注入每一段的提示词并在成稿后做一致性检查
+	Characters []common.CharacterProfile
+	// 已知事实，通常来自之前生成的故事；每段成稿后提取的事实会追加到账本中
+	Facts []common.Fact
+	// 故事总目标字数，平均分配到各段落，为 0 时不限制
+	TargetLength int
+	// 年龄段设定，非空时在提示词中加入用词和句长要求
+	AgeProfile *common.AgeProfile
+	// 教学目标，非空时要求各段落体现学习目标并使用目标词汇
+	Goals *common.EducationGoals
+	// 续写已有故事时，新段落的起始序号以及已有故事最后一段的大纲和内容
+	StartIndex        int
+	PreOutlineSection string
+	PreContent        string
+	// 故事语言，为空时使用 common.DEFAULT_LANGUAGE
+	Language string
+	// 实验分配的提示词模板变体（阶段 → 变体），为空时使用基准模板
+	PromptVariants map[string]string
+	// 每完成一段（一个节点）报告一次进度，为 nil 时不报告
+	Progress common.ProgressFunc
+	// 模型调用的用量计入的 Meter，为 nil 时只计入进程的累计用量
+	Meter *common.Meter
+}
+
 // 返回：故事草稿
 func GenerateDraft(InferAttributesString string, outlineSections []string) (string, error) {
+	Drafts, err := GenerateDraftSections(InferAttributesString, outlineSections, DraftOptions{})
+	if err != nil {
+		return "", err
+	}
 	story_draft := ""
+	for _, draft := range Drafts {
+		story_draft += draft.Content
+	}
+	return story_draft, nil
+}
+
+// GenerateDraftSections 逐段生成草稿
+// 返回：每个段落的草稿（包含内容与打分）
+func GenerateDraftSections(InferAttributesString string, outlineSections []string, options DraftOptions) ([]Draft, error) {
 	sectionsCount := len(outlineSections)
 	Drafts := make([]Draft, sectionsCount)
+	characterBible := common.FormatCharacterBible(options.Characters)
+	ledger := &common.FactLedger{Facts: options.Facts}
+	budgets := common.DistributeLength(options.TargetLength, sectionsCount)
+	ageGuidance := ""
+	if options.AgeProfile != nil {
+		ageGuidance = options.AgeProfile.Guidance()
+	}
 	// 遍历大纲段落
 	for i, currentSection := range outlineSections {
 		draft := Draft{
-			Index:                 i,
+			Index:                 options.StartIndex + i,
 			CurrentSection:        currentSection,
 			InferAttributesString: InferAttributesString,
+			CharacterBible:        characterBible,
+			TargetLength:          budgets[i],
+			AgeGuidance:           ageGuidance,
+			EducationGuidance:     options.Goals.Guidance(),
+			Language:              options.Language,
+			PromptVariants:        options.PromptVariants,
+			Meter:                 options.Meter,
 		}
 
-		// 只有非第一段才设置 PreOutlineSection
+		// 第一段只有在续写已有故事时才设置 PreOutlineSection
 		if i > 0 {
 			draft.PreOutlineSection = outlineSections[i-1]
 			draft.PreContent = Drafts[i-1].Content
+		} else {
+			draft.PreOutlineSection = options.PreOutlineSection
+			draft.PreContent = options.PreContent
 		}
 
 		// 只有非最后一段才设置 NextOutlineSection
@@ -41,47 +99,167 @@ func GenerateDraft(InferAttributesString string, outlineSections []string) (stri
 			draft.NextOutlineSection = outlineSections[i+1]
 		}
 
-		//取出最理想的候选集
-		content, err := getBestCandidate(draft)
-		if err != nil {
-			return "", fmt.Errorf("无法生成候选集: %v", err)
+		// 注入与当前段落相关的已知事实
+		draft.Facts = common.FormatFacts(ledger.Relevant(draft.CurrentSection, draft.NextOutlineSection, draft.PreContent), draft.Language)
+
+		if err := writeDraft(&draft); err != nil {
+			return nil, err
 		}
-		story_draft += content
+		ledger.Add(draft.NewFacts...)
 		Drafts[i] = draft
+		options.Progress.Report(common.Progress{Stage: common.PROGRESS_DRAFT, Done: i + 1, Total: sectionsCount, Index: draft.Index, Content: draft.Content})
 	}
-	return story_draft, nil
+	return Drafts, nil
 }
 
-func getBestCandidate(draft Draft) (string, error) {
-	prompt := construct_prompt(draft)
+// GenerateGraphDrafts 逐个节点生成分支故事的内容，结果写回故事图的节点中
+// 节点按编号顺序生成，保证父节点先于子节点完成；汇合节点以编号最小的父节点作为上文
+func GenerateGraphDrafts(InferAttributesString string, graph *common.StoryGraph, options DraftOptions) error {
+	characterBible := common.FormatCharacterBible(options.Characters)
+	// 每条路径的节点数为深度加一，按最长路径分配每个节点的字数
+	budget := common.DistributeLength(options.TargetLength, graph.Depth()+1)[0]
+	ageGuidance := ""
+	if options.AgeProfile != nil {
+		ageGuidance = options.AgeProfile.Guidance()
+	}
+	// 每个节点所在路径上累积的事实
+	pathFacts := make(map[int][]common.Fact)
+
+	for i := range graph.Nodes {
+		node := &graph.Nodes[i]
+		draft := Draft{
+			Index:                 node.ID,
+			CurrentSection:        node.Outline,
+			InferAttributesString: InferAttributesString,
+			CharacterBible:        characterBible,
+			TargetLength:          budget,
+			AgeGuidance:           ageGuidance,
+			EducationGuidance:     options.Goals.Guidance(),
+			Language:              options.Language,
+			PromptVariants:        options.PromptVariants,
+			Meter:                 options.Meter,
+		}
+
+		// 复制上文的事实，避免兄弟节点共用同一个底层数组
+		inherited := options.Facts
+		parents, choices := graph.Parents(node.ID)
+		if len(parents) > 0 {
+			draft.PreOutlineSection = parents[0].Outline
+			draft.PreContent = parents[0].Content
+			inherited = pathFacts[parents[0].ID]
+		}
+		ledger := &common.FactLedger{Facts: append([]common.Fact(nil), inherited...)}
+		for _, choice := range choices {
+			draft.IncomingChoices = append(draft.IncomingChoices, choice.Text)
+		}
+
+		var nextOutlines []string
+		for _, choice := range node.Choices {
+			draft.OutgoingChoices = append(draft.OutgoingChoices, choice.Text)
+			if next := graph.Node(choice.Next); next != nil {
+				nextOutlines = append(nextOutlines, choice.Text+"："+next.Outline)
+			}
+		}
+		draft.NextOutlineSection = strings.Join(nextOutlines, "；")
+
+		draft.Facts = common.FormatFacts(ledger.Relevant(draft.CurrentSection, draft.NextOutlineSection, draft.PreContent), draft.Language)
+
+		if err := writeDraft(&draft); err != nil {
+			return fmt.Errorf("节点 %d: %w", node.ID, err)
+		}
+		node.Content = draft.Content
+		node.Scores = draft.Scores
+		node.Facts = draft.NewFacts
+		ledger.Add(draft.NewFacts...)
+		pathFacts[node.ID] = ledger.Facts
+		options.Progress.Report(common.Progress{Stage: common.PROGRESS_DRAFT, Done: i + 1, Total: len(graph.Nodes), Index: node.ID, Content: node.Content})
+	}
+	return nil
+}
+
+// 生成单个段落：取最理想的候选，做角色一致性与事实检查，并提取本段事实
+func writeDraft(draft *Draft) error {
+	//取出最理想的候选集
+	content, scores, err := getBestCandidate(*draft)
+	if err != nil {
+		return fmt.Errorf("无法生成候选集: %w", err)
+	}
+	draft.Content = content
+	draft.Scores = scores
+
+	// 检查角色设定一致性，只记录问题不中断流程
+	issues, err := edit_module.CheckCharacterConsistency(*draft, content)
+	if err != nil {
+		log.Printf("检查角色一致性时发生错误: %v", err)
+	}
+	for _, issue := range issues {
+		log.Printf("Draft Index: %d, 角色 %s 设定矛盾: %s", draft.Index, issue.Character, issue.Description)
+	}
+	draft.CharacterIssues = issues
+
+	// 编辑修正后仍与已知事实矛盾的地方记录下来
+	continuityIssues, err := fact_module.CheckContradictions(*draft, content)
+	if err != nil {
+		log.Printf("检查事实矛盾时发生错误: %v", err)
+	}
+	for _, issue := range continuityIssues {
+		log.Printf("Draft Index: %d, 未解决的事实矛盾 %s: %s", draft.Index, issue.Fact, issue.Description)
+	}
+	draft.ContinuityIssues = continuityIssues
+
+	// 提取本段的事实
+	facts, err := fact_module.ExtractFacts(*draft, content)
+	if err != nil {
+		log.Printf("提取事实时发生错误: %v", err)
+	}
+	draft.NewFacts = facts
+	return nil
+}
+
+func getBestCandidate(draft Draft) (string, common.Scores, error) {
+	prompt, err := construct_prompt(draft)
+	if err != nil {
+		return "", common.Scores{}, err
+	}
 	//生成max_candidate_size个候选集
 	candidateList := make([]string, MAX_CANDIDATE_SIZE)
 	//分数0-10
-	currentScore := 0.0
+	bestScores := common.Scores{}
 	bestCandidate := ""
 	for i := 0; i < MAX_CANDIDATE_SIZE; i++ {
-		candidate, err := generateCandidate(prompt)
+		candidate, err := generateCandidate(prompt, draft.Meter)
 		if err != nil {
-			return "", fmt.Errorf("无法生成候选集: %v", err)
+			return "", common.Scores{}, fmt.Errorf("无法生成候选集: %w", err)
 		}
 		log.Println("Draft Index: ", draft.Index, " candidate Index: ", i, " candidate: ", candidate)
 		candidateList[i] = candidate
-		score, err := getScore(draft, candidateList[i])
+		scores, err := getScores(draft, candidateList[i])
 		if err != nil {
-			return "", fmt.Errorf("无法获取候选集分数: %v", err)
+			return "", common.Scores{}, fmt.Errorf("无法获取候选集分数: %w", err)
 		}
-		if score >= currentScore {
-			currentScore = score
+		if scores.Total >= bestScores.Total {
+			bestScores = scores
 			bestCandidate = candidateList[i]
 		}
 	}
 	//对bestCandidate去掉多余的符号 写个函数
 	bestCandidate = removeExtraSymbols(bestCandidate)
 	// 对故事的情节、事实进行修正
-	bestCandidate = edit_module.Rewrite(draft, bestCandidate)
+	rewritten, err := edit_module.Rewrite(draft, bestCandidate)
+	if err != nil {
+		// 修正失败时保留原候选，不中断整个流程
+		log.Printf("修正段落时发生错误: %v", err)
+	} else {
+		bestCandidate = rewritten
+	}
+	// 字数偏离预算时压缩或扩写
+	bestCandidate, err = edit_module.AdjustLength(bestCandidate, draft.TargetLength, draft.Language, draft.PromptVariants, draft.Meter)
+	if err != nil {
+		log.Printf("调整段落字数时发生错误: %v", err)
+	}
 	log.Println("bestCandidate: ", bestCandidate)
 
-	return bestCandidate, nil
+	return bestCandidate, bestScores, nil
 }
 
 func removeExtraSymbols(candidate string) string {
@@ -100,54 +278,21 @@ func removeExtraSymbols(candidate string) string {
 }
 
 // 生成单个候选集
-func generateCandidate(prompt string) (string, error) {
-	candidate, err := common.ChatWithModel(prompt)
+func generateCandidate(prompt string, meter *common.Meter) (string, error) {
+	candidate, err := meter.ChatWithModel(prompt)
 	if err != nil {
-		return "", fmt.Errorf("无法生成候选集: %v", err)
+		return "", fmt.Errorf("无法生成候选集: %w", err)
 	}
 	return candidate, nil
 }
 
-func construct_prompt(draft Draft) string {
-	basePrompt := "要求：\n" +
-		"1. 用简体中文写作\n" +
-		"2. 不要使用特殊字符、星号或markdown格式\n" +
-		"3. 避免使用括号、方括号或任何可能影响文本转语音的符号\n" +
-		"4. 遵循大纲之间的联系及明暗线\n" +
-		"5. 保持故事的连贯性\n" +
-		"6. 故事有其特殊的风格和特点\n"
-	//draft 是第一段
-	if draft.PreOutlineSection == "" {
-		prompt := fmt.Sprintf("背景信息：%s\n当前段落大纲：%s\n下一段落大纲：%s\n\n开头（即当前）"+basePrompt+"段落的全文如下\n",
-			draft.InferAttributesString,
-			draft.CurrentSection,
-			draft.NextOutlineSection,
-		)
-		return prompt
-	}
-	//draft 是最后一段
-	if draft.NextOutlineSection == "" {
-		prompt := fmt.Sprintf("背景信息：%s\n前一段大纲：%s\n前一段内容：%s\n当前一段大纲：\n%s\n"+basePrompt+"故事结尾全文如下\n",
-			draft.InferAttributesString,
-			draft.PreOutlineSection,
-			draft.PreContent,
-			draft.CurrentSection,
-		)
-		return prompt
-	}
-	//draft 是中间段
-	prompt := fmt.Sprintf("背景信息：%s\n前一段落大纲：%s\n前一段落内容：%s\n下一段落大纲：%s\n当前段落大纲：\n%s\n"+basePrompt+"当前段落的全文如下\n",
-		draft.InferAttributesString,
-		draft.PreOutlineSection,
-		draft.PreContent,
-		draft.NextOutlineSection,
-		draft.CurrentSection,
-	)
-	return prompt
+// 按草稿语言渲染段落提示词，第一段、最后一段和中间段使用不同的上下文
+func construct_prompt(draft Draft) (string, error) {
+	return prompt_module.Render("draft/section", draft.Language, draft.PromptVariants, draft)
 }
 
-// 获取候选集的分数
-func getScore(draft Draft, candidate string) (float64, error) {
+// 获取候选集的各维度分数
+func getScores(draft Draft, candidate string) (common.Scores, error) {
 	// 调用 common 包中的函数，该函数将由 rewrite_module 实现
-	return rewrite_module.GetScore(draft, candidate)
+	return rewrite_module.GetScores(draft, candidate)
 }
@@ -127,7 +127,7 @@ func TestGetBestCandidate(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// 获取最佳候选集
-			bestCandidate, err := getBestCandidate(tc.draft)
+			bestCandidate, _, err := getBestCandidate(tc.draft)
 
 			// 检查错误
 			if (err != nil) != tc.wantErr {
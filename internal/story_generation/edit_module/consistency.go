@@ -0,0 +1,72 @@
+package edit_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"fmt"
+	"strings"
+)
+
+// CheckCharacterConsistency 检查段落中角色的外貌、性格、说话风格等是否与角色设定矛盾
+// 参数：
+// - draft: 当前段落的上下文信息，需包含 CharacterBible
+// - content: 需要检查的段落内容
+// 返回：
+// - 发现的矛盾列表，没有矛盾时返回空列表
+func CheckCharacterConsistency(draft common.Draft, content string) ([]common.CharacterIssue, error) {
+	if draft.CharacterBible == "" {
+		return nil, nil
+	}
+
+	prompt, err := constructConsistencyPrompt(draft, content)
+	if err != nil {
+		return nil, err
+	}
+	response, err := draft.Meter.ChatWithModel(prompt)
+	if err != nil {
+		return nil, fmt.Errorf("调用模型检查角色一致性失败: %w", err)
+	}
+
+	return parseCharacterIssues(draft.Index, response, draft.Language), nil
+}
+
+// 构建角色一致性检查的提示词
+func constructConsistencyPrompt(draft common.Draft, content string) (string, error) {
+	return prompt_module.Render("edit/consistency", draft.Language, draft.PromptVariants, struct {
+		CharacterBible string
+		Content        string
+		NoIssues       string
+	}{draft.CharacterBible, content, noIssues[common.GetLanguage(draft.Language).Code]})
+}
+
+// 解析模型返回的矛盾列表
+func parseCharacterIssues(sectionIndex int, response string, language string) []common.CharacterIssue {
+	none := strings.ToLower(noIssues[common.GetLanguage(language).Code])
+	var issues []common.CharacterIssue
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, "*", ""))
+		if line == "" || strings.Contains(strings.ToLower(line), none) {
+			continue
+		}
+
+		parts := strings.SplitN(line, "：", 2)
+		if len(parts) < 2 {
+			parts = strings.SplitN(line, ":", 2)
+		}
+		if len(parts) < 2 {
+			continue
+		}
+
+		name := strings.TrimSpace(strings.TrimLeft(parts[0], "0123456789.-、 "))
+		description := strings.TrimSpace(parts[1])
+		if name == "" || description == "" {
+			continue
+		}
+		issues = append(issues, common.CharacterIssue{
+			SectionIndex: sectionIndex,
+			Character:    name,
+			Description:  description,
+		})
+	}
+	return issues
+}
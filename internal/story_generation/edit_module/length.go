@@ -0,0 +1,66 @@
+package edit_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"fmt"
+	"log"
+)
+
+const (
+	MAX_LENGTH_ADJUSTMENTS = 2 // 字数调整的最大次数
+)
+
+// AdjustLength 当文本字数超出预算容差时，请模型压缩或扩写
+// 参数：
+// - content: 需要调整的文本
+// - budget: 目标长度，为 0 时不做调整
+// - language: 故事语言，中文按字数、英文按词数统计长度
+// - variants: 各阶段使用的提示词模板变体，为空时使用基准模板
+// - meter: 模型调用的用量计入的 Meter，可以为 nil
+// 返回：
+// - 调整后的文本；多次调整仍未达标时返回最接近预算的一版
+func AdjustLength(content string, budget int, language string, variants map[string]string, meter *common.Meter) (string, error) {
+	lang := common.GetLanguage(language)
+	best := content
+	for attempts := 0; attempts < MAX_LENGTH_ADJUSTMENTS; attempts++ {
+		count := lang.CountLength(best)
+		if common.WithinBudget(count, budget) {
+			return best, nil
+		}
+		log.Printf("长度 %d 偏离预算 %d，进行第 %d 次调整", count, budget, attempts+1)
+
+		prompt, err := constructLengthPrompt(best, count, budget, language, variants)
+		if err != nil {
+			return best, err
+		}
+		response, err := meter.ChatWithModel(prompt)
+		if err != nil {
+			return best, fmt.Errorf("调用模型调整字数失败: %w", err)
+		}
+		adjusted := cleanResponse(response, language)
+		if adjusted == "" {
+			continue
+		}
+		if distance(lang.CountLength(adjusted), budget) < distance(count, budget) {
+			best = adjusted
+		}
+	}
+	return best, nil
+}
+
+// 构建调整字数的提示词，超出预算时压缩，不足时扩写
+func constructLengthPrompt(content string, count int, budget int, language string, variants map[string]string) (string, error) {
+	return prompt_module.Render("edit/length", language, variants, struct {
+		Content string
+		Count   int
+		Budget  int
+	}{content, count, budget})
+}
+
+func distance(count int, budget int) int {
+	if count > budget {
+		return count - budget
+	}
+	return budget - count
+}
@@ -0,0 +1,19 @@
+package edit_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+)
+
+// 模型可能在回复开头加上的说明；修正、调整字数和角色一致性检查的提示词见 prompt_module 的 edit/ 模板
+var responsePrefixes = map[string][]string{
+	common.LANG_ZH_HANS: {"修正后的段落：", "修正后的文本：", "修改后的段落：", "修改后：", "修正后："},
+	common.LANG_ZH_HANT: {"修正後的段落：", "修正後的文字：", "修改後的段落：", "修改後：", "修正後："},
+	common.LANG_EN:      {"Corrected passage:", "Revised passage:", "Corrected:", "Revised:", "Here is the corrected passage:"},
+}
+
+// 角色一致性检查没有发现矛盾时模型输出的内容
+var noIssues = map[string]string{
+	common.LANG_ZH_HANS: "无矛盾",
+	common.LANG_ZH_HANT: "無矛盾",
+	common.LANG_EN:      "No contradictions",
+}
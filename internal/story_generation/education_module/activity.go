@@ -0,0 +1,142 @@
+package education_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	MIN_QUESTIONS = 3
+	MAX_QUESTIONS = 5
+	MAX_ATTEMPTS  = 3
+)
+
+// Question 阅读理解题及参考答案
+type Question struct {
+	Question string `json:"question" yaml:"question"`
+	Answer   string `json:"answer" yaml:"answer"`
+}
+
+// ActivitySheet 故事配套的学习单：阅读理解题、讨论话题和课堂活动
+type ActivitySheet struct {
+	Questions        []Question `json:"questions" yaml:"questions"`
+	DiscussionPrompt string     `json:"discussion_prompt" yaml:"discussion_prompt"`
+	Activity         string     `json:"activity" yaml:"activity"`
+	// 学习单的语言，决定导出纯文本时使用的标题
+	Language string `json:"language,omitempty" yaml:"language,omitempty"`
+}
+
+// GenerateActivitySheet 根据故事全文和故事背景生成学习单
+// 参数：
+// - story: 故事全文
+// - background: 故事的前提、背景和角色信息，可为空
+// - profile: 年龄段设定，为空时不限制难度
+// - goals: 教学目标，非空时讨论话题和活动围绕学习目标展开
+// - language: 故事的语言，学习单使用相同的语言
+// - meter: 模型调用的用量计入的 Meter，可以为 nil
+func GenerateActivitySheet(story string, background string, profile *common.AgeProfile, goals *common.EducationGoals, language string, meter *common.Meter) (*ActivitySheet, error) {
+	prompt, err := constructActivityPrompt(story, background, profile, goals, language)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempts := 0; attempts < MAX_ATTEMPTS; attempts++ {
+		response, err := meter.ChatWithModel(prompt)
+		if err != nil {
+			return nil, fmt.Errorf("调用模型生成学习单失败: %w", err)
+		}
+		sheet := parseActivitySheet(response, language)
+		if len(sheet.Questions) >= MIN_QUESTIONS && sheet.Activity != "" {
+			return sheet, nil
+		}
+		common.RecordParseFailure("education/activity_sheet")
+	}
+	return nil, fmt.Errorf("未能生成有效的学习单")
+}
+
+// 构建生成学习单的提示词
+func constructActivityPrompt(story string, background string, profile *common.AgeProfile, goals *common.EducationGoals, language string) (string, error) {
+	var ageGuidance string
+	if profile != nil {
+		ageGuidance = profile.Guidance()
+	}
+	return prompt_module.Render("education/activity", language, nil, struct {
+		Story             string
+		Background        string
+		MinQuestions      int
+		MaxQuestions      int
+		AgeGuidance       string
+		EducationGuidance string
+	}{story, background, MIN_QUESTIONS, MAX_QUESTIONS, ageGuidance, goals.Guidance()})
+}
+
+// 解析学习单，标签使用该语言的标签
+func parseActivitySheet(response string, language string) *ActivitySheet {
+	labels := labelsFor(language)
+	sheet := &ActivitySheet{Language: common.GetLanguage(language).Code}
+	answers := make(map[int]string)
+	var order []int
+	questions := make(map[int]string)
+
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, "*", ""))
+		if match := labels.question.FindStringSubmatch(line); match != nil {
+			index, _ := strconv.Atoi(match[1])
+			if _, ok := questions[index]; !ok {
+				order = append(order, index)
+			}
+			questions[index] = strings.TrimSpace(match[2])
+		} else if match := labels.answer.FindStringSubmatch(line); match != nil {
+			index, _ := strconv.Atoi(match[1])
+			answers[index] = strings.TrimSpace(match[2])
+		} else if value, ok := cutLabel(line, labels.discussion); ok {
+			sheet.DiscussionPrompt = value
+		} else if value, ok := cutLabel(line, labels.activity); ok {
+			sheet.Activity = value
+		}
+	}
+
+	for _, index := range order {
+		if len(sheet.Questions) == MAX_QUESTIONS {
+			break
+		}
+		sheet.Questions = append(sheet.Questions, Question{Question: questions[index], Answer: answers[index]})
+	}
+	return sheet
+}
+
+// 去掉 "标签：" 前缀，标签不区分大小写
+func cutLabel(line string, label string) (string, bool) {
+	for _, sep := range []string{"：", ":"} {
+		prefix := label + sep
+		if len(line) >= len(prefix) && strings.EqualFold(line[:len(prefix)], prefix) {
+			return strings.TrimSpace(line[len(prefix):]), true
+		}
+	}
+	return "", false
+}
+
+// Text 将学习单渲染为可打印的纯文本，便于随故事一起导出，标题使用学习单的语言
+func (s *ActivitySheet) Text() string {
+	labels := labelsFor(s.Language)
+	var builder strings.Builder
+
+	builder.WriteString(labels.comprehension + "\n")
+	for i, question := range s.Questions {
+		builder.WriteString(fmt.Sprintf("%d. %s\n", i+1, question.Question))
+	}
+
+	builder.WriteString("\n" + labels.discussion + "\n")
+	builder.WriteString(s.DiscussionPrompt)
+	builder.WriteString("\n\n" + labels.activity + "\n")
+	builder.WriteString(s.Activity)
+
+	builder.WriteString("\n\n" + labels.answers + "\n")
+	for i, question := range s.Questions {
+		builder.WriteString(fmt.Sprintf("%d. %s\n", i+1, question.Answer))
+	}
+	return builder.String()
+}
@@ -0,0 +1,109 @@
+// 教学目标模块：检查成稿后的故事是否覆盖了目标词汇和学习目标
+package education_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// WordCoverage 单个目标词汇的覆盖情况
+type WordCoverage struct {
+	Word     string `json:"word" yaml:"word"`
+	Present  bool   `json:"present" yaml:"present"`
+	Sections []int  `json:"sections,omitempty" yaml:"sections,omitempty"` // 出现该词的段落序号
+}
+
+// ObjectiveCoverage 单个学习目标的覆盖情况
+type ObjectiveCoverage struct {
+	Objective string `json:"objective" yaml:"objective"`
+	Stated    bool   `json:"stated" yaml:"stated"`
+	Evidence  string `json:"evidence,omitempty" yaml:"evidence,omitempty"` // 故事中体现该目标的内容
+}
+
+// CoverageReport 教学目标覆盖报告
+type CoverageReport struct {
+	Words      []WordCoverage      `json:"words,omitempty" yaml:"words,omitempty"`
+	Objectives []ObjectiveCoverage `json:"objectives,omitempty" yaml:"objectives,omitempty"`
+	Complete   bool                `json:"complete" yaml:"complete"`
+}
+
+// CheckCoverage 检查各段落是否覆盖了目标词汇，以及整个故事是否表达了学习目标
+// 目标词汇中文按字面匹配，其他语言按完整的词匹配且不区分大小写；学习目标由模型按故事的语言 language 判断（调用计入 meter，可以为 nil），调用失败的目标记为未覆盖
+func CheckCoverage(goals *common.EducationGoals, sections []string, language string, meter *common.Meter) CoverageReport {
+	report := CoverageReport{Complete: true}
+	if goals.Empty() {
+		return report
+	}
+
+	lang := common.GetLanguage(language)
+	for _, word := range goals.Vocabulary {
+		coverage := WordCoverage{Word: word}
+		for i, section := range sections {
+			if lang.ContainsWord(section, word) {
+				coverage.Sections = append(coverage.Sections, i)
+			}
+		}
+		coverage.Present = len(coverage.Sections) > 0
+		report.Complete = report.Complete && coverage.Present
+		report.Words = append(report.Words, coverage)
+	}
+
+	story := strings.Join(sections, "\n")
+	for _, objective := range goals.Objectives {
+		coverage := ObjectiveCoverage{Objective: objective}
+		stated, evidence, err := checkObjective(objective, story, language, meter)
+		if err != nil {
+			log.Printf("检查学习目标 %s 时发生错误: %v", objective, err)
+		}
+		coverage.Stated = stated
+		coverage.Evidence = evidence
+		report.Complete = report.Complete && coverage.Stated
+		report.Objectives = append(report.Objectives, coverage)
+	}
+	return report
+}
+
+// 请模型判断故事是否表达了学习目标
+func checkObjective(objective string, story string, language string, meter *common.Meter) (bool, string, error) {
+	prompt, err := prompt_module.Render("education/objective", language, nil, struct {
+		Objective string
+		Story     string
+	}{objective, story})
+	if err != nil {
+		return false, "", err
+	}
+
+	response, err := meter.ChatWithModel(prompt)
+	if err != nil {
+		return false, "", fmt.Errorf("调用模型检查学习目标失败: %w", err)
+	}
+	stated, evidence := parseObjectiveResult(response, language)
+	return stated, evidence, nil
+}
+
+// 解析学习目标判断结果，回答使用该语言的 是/否
+func parseObjectiveResult(response string, language string) (bool, string) {
+	labels := labelsFor(language)
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, "*", ""))
+		parts := strings.SplitN(line, "｜", 2)
+		if len(parts) < 2 {
+			parts = strings.SplitN(line, "|", 2)
+		}
+		answer := strings.TrimSpace(parts[0])
+		evidence := ""
+		if len(parts) == 2 {
+			evidence = strings.TrimSpace(parts[1])
+		}
+		switch {
+		case strings.EqualFold(answer, labels.yes):
+			return true, evidence
+		case strings.EqualFold(answer, labels.no):
+			return false, evidence
+		}
+	}
+	return false, ""
+}
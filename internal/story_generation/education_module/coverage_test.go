@@ -0,0 +1,98 @@
+package education_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCheckCoverageVocabulary(t *testing.T) {
+	goals := common.NewEducationGoals(nil, []string{"分享", "谢谢", " ", "勇敢"})
+	sections := []string{"小熊把蜂蜜分享给了小兔。", "小兔说：谢谢你，我们一起分享吧。"}
+
+	report := CheckCoverage(goals, sections, common.LANG_ZH_HANS, nil)
+	if len(report.Words) != 3 {
+		t.Fatalf("目标词汇数量错误: %+v", report.Words)
+	}
+	if !report.Words[0].Present || len(report.Words[0].Sections) != 2 {
+		t.Errorf("分享 应出现在两个段落: %+v", report.Words[0])
+	}
+	if report.Words[2].Present || report.Complete {
+		t.Errorf("勇敢 未出现，报告应不完整: %+v", report)
+	}
+}
+
+// 英文词汇按完整的词匹配，不区分大小写
+func TestCheckCoverageEnglishVocabulary(t *testing.T) {
+	goals := common.NewEducationGoals(nil, []string{"share", "Thank you", "brave"})
+	sections := []string{"Share your honey, said the bear.", "The rabbit said thank you. Nobody was shared out.", "Everyone braved the rain."}
+
+	report := CheckCoverage(goals, sections, common.LANG_EN, nil)
+	if fmt.Sprint(report.Words[0].Sections) != "[0]" {
+		t.Errorf("share 只应匹配完整的词: %+v", report.Words[0])
+	}
+	if !report.Words[1].Present {
+		t.Errorf("Thank you 应不区分大小写匹配: %+v", report.Words[1])
+	}
+	if report.Words[2].Present {
+		t.Errorf("brave 不应匹配 braved: %+v", report.Words[2])
+	}
+}
+
+func TestCheckCoverageEmpty(t *testing.T) {
+	if report := CheckCoverage(nil, []string{"故事"}, common.LANG_ZH_HANS, nil); !report.Complete {
+		t.Error("没有教学目标时报告应为完整")
+	}
+}
+
+func TestParseObjectiveResult(t *testing.T) {
+	stated, evidence := parseObjectiveResult("是｜小熊说：好东西要和朋友一起分享。", common.LANG_ZH_HANS)
+	if !stated || evidence == "" {
+		t.Errorf("解析错误: %v, %s", stated, evidence)
+	}
+	if stated, _ := parseObjectiveResult("否｜故事没有提到刷牙", common.LANG_ZH_HANS); stated {
+		t.Error("解析错误: 应为未传达")
+	}
+	if stated, evidence := parseObjectiveResult("yes | Benny said sharing makes everyone happy.", common.LANG_EN); !stated || evidence == "" {
+		t.Errorf("英文结果解析错误: %v, %s", stated, evidence)
+	}
+}
+
+func TestParseActivitySheet(t *testing.T) {
+	response := `问题1：小熊把什么分享给了小兔？
+答案1：蜂蜜
+问题2：小兔说了什么？
+答案2：谢谢你
+问题3：他们最后做了什么？
+答案3：一起吃蜂蜜
+讨论：你和朋友分享过什么？
+活动：和同桌交换一件小玩具，说说感受。`
+
+	sheet := parseActivitySheet(response, common.LANG_ZH_HANS)
+	if len(sheet.Questions) != 3 || sheet.Questions[1].Answer != "谢谢你" {
+		t.Errorf("阅读理解题解析错误: %+v", sheet.Questions)
+	}
+	if sheet.DiscussionPrompt == "" || sheet.Activity == "" {
+		t.Errorf("讨论话题或活动解析错误: %+v", sheet)
+	}
+}
+
+func TestParseEnglishActivitySheet(t *testing.T) {
+	response := `Question 1: What did Bear share with Bunny?
+Answer 1: Honey
+Question 2: What did Bunny say?
+Answer 2: Thank you
+Question 3: What did they do in the end?
+Answer 3: They ate the honey together
+Discussion: What have you shared with a friend?
+Activity: Swap a small toy with a classmate and talk about how it feels.`
+
+	sheet := parseActivitySheet(response, common.LANG_EN)
+	if len(sheet.Questions) != 3 || sheet.Questions[1].Answer != "Thank you" || sheet.Activity == "" {
+		t.Errorf("英文学习单解析错误: %+v", sheet)
+	}
+	if text := sheet.Text(); !strings.HasPrefix(text, "Reading comprehension\n") || !strings.Contains(text, "\nAnswer key\n") {
+		t.Errorf("英文学习单应使用英文标题:\n%s", text)
+	}
+}
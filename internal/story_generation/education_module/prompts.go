@@ -0,0 +1,59 @@
+package education_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"regexp"
+)
+
+// 各语言学习单和学习目标判断结果的标签：模型按模板中的格式输出，据此解析，导出纯文本时也使用这些标签；
+// 生成学习单和判断学习目标的提示词见 prompt_module 的 education/ 模板
+type labels struct {
+	question   *regexp.Regexp
+	answer     *regexp.Regexp
+	discussion string
+	activity   string
+	// 导出纯文本时阅读理解题和参考答案的标题
+	comprehension string
+	answers       string
+	// 学习目标是否传达的回答
+	yes string
+	no  string
+}
+
+var languageLabels = map[string]labels{
+	common.LANG_ZH_HANS: {
+		question:      regexp.MustCompile(`^问题\s*(\d+)\s*[：:]\s*(.+)$`),
+		answer:        regexp.MustCompile(`^答案\s*(\d+)\s*[：:]\s*(.+)$`),
+		discussion:    "讨论",
+		activity:      "活动",
+		comprehension: "阅读理解",
+		answers:       "参考答案",
+		yes:           "是",
+		no:            "否",
+	},
+	common.LANG_ZH_HANT: {
+		question:      regexp.MustCompile(`^問題\s*(\d+)\s*[：:]\s*(.+)$`),
+		answer:        regexp.MustCompile(`^答案\s*(\d+)\s*[：:]\s*(.+)$`),
+		discussion:    "討論",
+		activity:      "活動",
+		comprehension: "閱讀理解",
+		answers:       "參考答案",
+		yes:           "是",
+		no:            "否",
+	},
+	common.LANG_EN: {
+		question:      regexp.MustCompile(`(?i)^question\s*(\d+)\s*[：:]\s*(.+)$`),
+		answer:        regexp.MustCompile(`(?i)^answer\s*(\d+)\s*[：:]\s*(.+)$`),
+		discussion:    "Discussion",
+		activity:      "Activity",
+		comprehension: "Reading comprehension",
+		answers:       "Answer key",
+		yes:           "Yes",
+		no:            "No",
+	},
+}
+
+// 某个语言的标签，未知语言使用默认语言
+func labelsFor(language string) labels {
+	return languageLabels[common.GetLanguage(language).Code]
+}
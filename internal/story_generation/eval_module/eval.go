@@ -0,0 +1,124 @@
+// 离线评测模块：读取故事前提数据集，汇总每个故事的评分、长度、解析失败、耗时和 token 用量
+//
+// 数据集为 JSONL 文件，每行一个前提。前提依次取 premise、body、title 字段，
+// ID 依次取 id、request_id 字段，都没有时使用行号。
+package eval_module
+
+import (
+	"bufio"
+	"encoding/json"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/story_document"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 单行 JSONL 的最大长度
+const MAX_LINE_BYTES = 1 << 20
+
+// Item 数据集中的一个故事前提，AgeGroup、Language、TargetLength 为空时使用命令行的默认值
+type Item struct {
+	ID           string `json:"id"`
+	Premise      string `json:"premise"`
+	AgeGroup     string `json:"age_group,omitempty"`
+	Language     string `json:"language,omitempty"`
+	TargetLength int    `json:"target_length,omitempty"`
+}
+
+// 数据集的一行，兼容 requests.jsonl 的 request_id/title/body 格式
+type rawItem struct {
+	Item
+	RequestID string `json:"request_id"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+}
+
+// ReadItems 读取 JSONL 数据集，忽略空行，某一行无法解析或没有前提时返回错误
+func ReadItems(path string) ([]Item, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var items []Item
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), MAX_LINE_BYTES)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var raw rawItem
+		if err := json.Unmarshal([]byte(text), &raw); err != nil {
+			return nil, fmt.Errorf("第 %d 行无法解析: %v", line, err)
+		}
+		item := raw.Item
+		item.Premise = firstNonEmpty(item.Premise, raw.Body, raw.Title)
+		if item.Premise == "" {
+			return nil, fmt.Errorf("第 %d 行没有故事前提", line)
+		}
+		item.ID = firstNonEmpty(item.ID, raw.RequestID, strconv.Itoa(line))
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Result 单个故事的评测结果
+type Result struct {
+	ID       string `json:"id"`
+	Premise  string `json:"premise"`
+	Language string `json:"language"`
+	// 生成失败时的错误信息，其余指标只统计成功的故事
+	Error        string        `json:"error,omitempty"`
+	TargetLength int           `json:"target_length,omitempty"`
+	Length       int           `json:"length"`
+	Sections     int           `json:"sections"`
+	Scores       common.Scores `json:"scores"`
+	DurationMs   int64         `json:"duration_ms"`
+	// 生成该故事期间的模型调用用量
+	Usage common.Usage `json:"usage"`
+	// 故事文档的保存路径
+	Output string `json:"output,omitempty"`
+}
+
+// NewResult 根据生成的故事文档（或错误）、耗时和用量构造评测结果
+func NewResult(item Item, doc *story_document.StoryDocument, err error, durationMs int64, usage common.Usage) Result {
+	result := Result{
+		ID:         item.ID,
+		Premise:    item.Premise,
+		Language:   common.GetLanguage(item.Language).Code,
+		DurationMs: durationMs,
+		Usage:      usage,
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	language := common.GetLanguage(doc.Language)
+	result.Language = language.Code
+	result.TargetLength = doc.Metadata.TargetLength
+	result.Length = language.CountLength(doc.FinalText)
+	result.Sections = len(doc.Sections)
+	if doc.Graph != nil && len(doc.Sections) == 0 {
+		result.Sections = len(doc.Graph.Nodes)
+	}
+	result.Scores = doc.AverageScores()
+	return result
+}
@@ -0,0 +1,99 @@
+package eval_module
+
+import (
+	"errors"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/story_document"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadItems(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "premises.jsonl")
+	data := `{"request_id": "user-001", "title": "标题", "body": "小兔子交朋友"}
+
+{"id": "en-1", "premise": "A bunny makes friends", "language": "en", "target_length": 200}
+{"title": "小熊过河"}
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	items, err := ReadItems(path)
+	if err != nil {
+		t.Fatalf("读取数据集失败: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("应读取 3 个前提: %+v", items)
+	}
+	if items[0].ID != "user-001" || items[0].Premise != "小兔子交朋友" {
+		t.Errorf("requests.jsonl 格式解析错误: %+v", items[0])
+	}
+	if items[1].Language != common.LANG_EN || items[1].TargetLength != 200 {
+		t.Errorf("可选字段解析错误: %+v", items[1])
+	}
+	if items[2].ID != "4" || items[2].Premise != "小熊过河" {
+		t.Errorf("应使用行号作为 ID: %+v", items[2])
+	}
+
+	if err := os.WriteFile(path, []byte(`{"id": "1"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadItems(path); err == nil {
+		t.Error("没有前提时应返回错误")
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	doc := &story_document.StoryDocument{
+		Language:  common.LANG_ZH_HANS,
+		FinalText: strings.Repeat("兔", 90),
+		Sections: []story_document.Section{
+			{Scores: common.Scores{Coherence: 8, Quality: 6, Fluency: 7, Total: 21}},
+			{Scores: common.Scores{Coherence: 6, Quality: 8, Fluency: 9, Total: 23}},
+		},
+	}
+	doc.Metadata.TargetLength = 100
+	usage := common.Usage{Calls: 10, PromptTokens: 2000, CompletionTokens: 1000, ParseFailures: map[string]int{"rewrite/score": 1}}
+
+	results := []Result{
+		NewResult(Item{ID: "1"}, doc, nil, 1000, usage),
+		NewResult(Item{ID: "2"}, nil, errors.New("模型不可用"), 3000, common.Usage{Calls: 1, Failures: 1, ParseFailures: map[string]int{"plan/outline": 2}}),
+	}
+	if results[0].Length != 90 || results[0].Sections != 2 || results[0].Scores.Total != 22 {
+		t.Errorf("评测结果错误: %+v", results[0])
+	}
+
+	report := BuildReport("deepseek", Pricing{PromptPer1K: 1, CompletionPer1K: 2}, results)
+	if report.Stories != 2 || report.Errors != 1 {
+		t.Errorf("故事数统计错误: %+v", report)
+	}
+	if report.Scores["coherence"].Mean != 7 || report.Scores["total"].Count != 1 {
+		t.Errorf("评分只应统计成功的故事: %+v", report.Scores)
+	}
+	if report.LengthRatio.Mean != 0.9 || report.Latency.Max != 3000 || report.Latency.P50 != 1000 {
+		t.Errorf("长度或耗时统计错误: %+v %+v", report.LengthRatio, report.Latency)
+	}
+	if report.Usage.Calls != 11 || report.Usage.TotalParseFailures() != 3 || report.Cost != 4 {
+		t.Errorf("用量或成本统计错误: %+v %v", report.Usage, report.Cost)
+	}
+
+	var builder strings.Builder
+	if err := WriteReport(&builder, report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(builder.String(), "plan/outline: 2") {
+		t.Errorf("报告缺少解析失败明细:\n%s", builder.String())
+	}
+}
+
+func TestStats(t *testing.T) {
+	stats := NewStats([]float64{5, 1, 4, 2, 3})
+	if stats.Min != 1 || stats.Max != 5 || stats.Mean != 3 || stats.P50 != 3 || stats.P95 != 5 {
+		t.Errorf("统计量错误: %+v", stats)
+	}
+	if NewStats(nil) != (Stats{}) {
+		t.Error("空数据的统计量应为 0")
+	}
+}
@@ -0,0 +1,165 @@
+package eval_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+)
+
+// Stats 一组数值的统计量，P50、P95 按最近秩法计算
+type Stats struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+}
+
+// NewStats 计算统计量，没有数值时各项为 0
+func NewStats(values []float64) Stats {
+	if len(values) == 0 {
+		return Stats{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, value := range sorted {
+		sum += value
+	}
+	return Stats{
+		Count: len(sorted),
+		Mean:  sum / float64(len(sorted)),
+		Min:   sorted[0],
+		Max:   sorted[len(sorted)-1],
+		P50:   percentile(sorted, 50),
+		P95:   percentile(sorted, 95),
+	}
+}
+
+// 已排序数值的第 p 百分位数
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Pricing 每千个 token 的价格，用于估算成本
+type Pricing struct {
+	PromptPer1K     float64 `json:"prompt_per_1k"`
+	CompletionPer1K float64 `json:"completion_per_1k"`
+}
+
+// Cost 按价格估算用量的成本
+func (p Pricing) Cost(usage common.Usage) float64 {
+	return (float64(usage.PromptTokens)*p.PromptPer1K + float64(usage.CompletionTokens)*p.CompletionPer1K) / 1000
+}
+
+// Report 一次评测的汇总指标
+type Report struct {
+	Model   string  `json:"model"`
+	Pricing Pricing `json:"pricing"`
+	Stories int     `json:"stories"`
+	Errors  int     `json:"errors"`
+	// 各评分维度（连贯性、内容质量、表达流畅度、总分）的统计
+	Scores map[string]Stats `json:"scores"`
+	// 故事长度（按语言的长度单位）及长度与目标长度之比
+	Length      Stats `json:"length"`
+	LengthRatio Stats `json:"length_ratio"`
+	// 每个故事的生成耗时（毫秒），包括失败的故事
+	Latency Stats `json:"latency_ms"`
+	// 全部故事的累计用量，其中包括各阶段的解析失败次数
+	Usage common.Usage `json:"usage"`
+	Cost  float64      `json:"cost"`
+}
+
+// BuildReport 汇总各故事的评测结果
+func BuildReport(model string, pricing Pricing, results []Result) Report {
+	report := Report{
+		Model:   model,
+		Pricing: pricing,
+		Stories: len(results),
+		Scores:  make(map[string]Stats),
+		Usage:   common.Usage{ParseFailures: make(map[string]int)},
+	}
+
+	dimensions := map[string][]float64{}
+	var lengths, ratios, latencies []float64
+	for _, result := range results {
+		latencies = append(latencies, float64(result.DurationMs))
+		report.Usage.Calls += result.Usage.Calls
+		report.Usage.Failures += result.Usage.Failures
+		report.Usage.PromptTokens += result.Usage.PromptTokens
+		report.Usage.CompletionTokens += result.Usage.CompletionTokens
+		report.Usage.DurationMs += result.Usage.DurationMs
+		for stage, count := range result.Usage.ParseFailures {
+			report.Usage.ParseFailures[stage] += count
+		}
+		if result.Error != "" {
+			report.Errors++
+			continue
+		}
+
+		dimensions["coherence"] = append(dimensions["coherence"], result.Scores.Coherence)
+		dimensions["quality"] = append(dimensions["quality"], result.Scores.Quality)
+		dimensions["fluency"] = append(dimensions["fluency"], result.Scores.Fluency)
+		dimensions["total"] = append(dimensions["total"], result.Scores.Total)
+		lengths = append(lengths, float64(result.Length))
+		if result.TargetLength > 0 {
+			ratios = append(ratios, float64(result.Length)/float64(result.TargetLength))
+		}
+	}
+	for dimension, values := range dimensions {
+		report.Scores[dimension] = NewStats(values)
+	}
+	report.Length = NewStats(lengths)
+	report.LengthRatio = NewStats(ratios)
+	report.Latency = NewStats(latencies)
+	report.Cost = pricing.Cost(report.Usage)
+	return report
+}
+
+// WriteReport 以文本形式输出汇总指标
+func WriteReport(w io.Writer, report Report) error {
+	fmt.Fprintf(w, "模型: %s  故事数: %d  失败: %d\n\n", report.Model, report.Stories, report.Errors)
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "指标\t平均\t最小\t最大\tP50\tP95")
+	rows := []struct {
+		name  string
+		stats Stats
+	}{
+		{"连贯性", report.Scores["coherence"]},
+		{"内容质量", report.Scores["quality"]},
+		{"表达流畅度", report.Scores["fluency"]},
+		{"总分", report.Scores["total"]},
+		{"长度", report.Length},
+		{"长度/目标", report.LengthRatio},
+		{"耗时(ms)", report.Latency},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(table, "%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\n",
+			row.name, row.stats.Mean, row.stats.Min, row.stats.Max, row.stats.P50, row.stats.P95)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\n模型调用: %d  调用失败: %d  输入 token: %d  输出 token: %d  估算成本: %.4f\n",
+		report.Usage.Calls, report.Usage.Failures, report.Usage.PromptTokens, report.Usage.CompletionTokens, report.Cost)
+	fmt.Fprintf(w, "解析失败: %d\n", report.Usage.TotalParseFailures())
+	stages := make([]string, 0, len(report.Usage.ParseFailures))
+	for stage := range report.Usage.ParseFailures {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+	for _, stage := range stages {
+		fmt.Fprintf(w, "  %s: %d\n", stage, report.Usage.ParseFailures[stage])
+	}
+	return nil
+}
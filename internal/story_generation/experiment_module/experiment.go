@@ -0,0 +1,123 @@
+// 实验模块：按实验配置把请求分配到各阶段的提示词变体，记录每个故事的分配结果、
+// rewrite_module 评分和用户反馈，并离线比较各变体的效果
+//
+// 分配只取决于实验名称和请求 ID，同一个请求 ID 总是分配到同一个变体；
+// 同一阶段同时配置多个实验时，请求只参与排在前面的实验。
+package experiment_module
+
+import (
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"fmt"
+	"hash/fnv"
+)
+
+const (
+	// CONTROL 对照组，使用基准模板
+	CONTROL = "control"
+	// TOTAL_TRAFFIC 流量百分比的总和上限
+	TOTAL_TRAFFIC = 100
+)
+
+// STAGES 可以进行实验的阶段
+var STAGES = map[string]bool{
+	"plan":    true,
+	"draft":   true,
+	"rewrite": true,
+	"edit":    true,
+}
+
+// Assignment 请求在某个实验中分配到的变体
+type Assignment struct {
+	Experiment string `json:"experiment" yaml:"experiment"`
+	Stage      string `json:"stage" yaml:"stage"`
+	Variant    string `json:"variant" yaml:"variant"`
+}
+
+// Assign 按当前配置的实验为请求分配变体，请求 ID 为空或未落入任何变体的流量时不参与实验
+func Assign(requestID string) []Assignment {
+	return assign(config.GetConfig().Experiments.Active, requestID)
+}
+
+func assign(experiments []config.Experiment, requestID string) []Assignment {
+	if requestID == "" {
+		return nil
+	}
+	var assignments []Assignment
+	assigned := make(map[string]bool)
+	for _, experiment := range experiments {
+		if assigned[experiment.Stage] {
+			continue
+		}
+		variant, ok := pick(experiment, bucket(experiment.Name, requestID))
+		if !ok {
+			continue
+		}
+		assigned[experiment.Stage] = true
+		assignments = append(assignments, Assignment{Experiment: experiment.Name, Stage: experiment.Stage, Variant: variant})
+	}
+	return assignments
+}
+
+// 请求在实验中的分桶，取值 0-99
+func bucket(experiment string, requestID string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(experiment + "/" + requestID))
+	return int(hash.Sum32() % TOTAL_TRAFFIC)
+}
+
+// 按变体的流量百分比依次划分分桶，超出流量总和的分桶不参与实验
+func pick(experiment config.Experiment, bucket int) (string, bool) {
+	upper := 0
+	for _, variant := range experiment.Variants {
+		upper += variant.Traffic
+		if bucket < upper {
+			return variant.Name, true
+		}
+	}
+	return "", false
+}
+
+// Variants 分配结果对应的各阶段模板变体（阶段 → 变体），对照组使用基准模板不列出
+func Variants(assignments []Assignment) map[string]string {
+	variants := make(map[string]string)
+	for _, assignment := range assignments {
+		if assignment.Variant != CONTROL {
+			variants[assignment.Stage] = assignment.Variant
+		}
+	}
+	return variants
+}
+
+// Validate 检查实验配置：名称唯一、阶段有效、流量合法，且每个非对照变体都有对应的模板
+func Validate(experiments []config.Experiment) error {
+	names := make(map[string]bool)
+	for _, experiment := range experiments {
+		if experiment.Name == "" || names[experiment.Name] {
+			return fmt.Errorf("实验名称为空或重复: %q", experiment.Name)
+		}
+		names[experiment.Name] = true
+		if !STAGES[experiment.Stage] {
+			return fmt.Errorf("实验 %s 的阶段 %q 无效", experiment.Name, experiment.Stage)
+		}
+		total := 0
+		variants := make(map[string]bool)
+		for _, variant := range experiment.Variants {
+			if variant.Name == "" || variants[variant.Name] {
+				return fmt.Errorf("实验 %s 的变体名称为空或重复: %q", experiment.Name, variant.Name)
+			}
+			variants[variant.Name] = true
+			if variant.Traffic < 0 {
+				return fmt.Errorf("实验 %s 的变体 %s 流量为负数", experiment.Name, variant.Name)
+			}
+			total += variant.Traffic
+			if variant.Name != CONTROL && !prompt_module.HasVariant(experiment.Stage, variant.Name) {
+				return fmt.Errorf("实验 %s 的变体 %s 没有对应的 %s 阶段模板", experiment.Name, variant.Name, experiment.Stage)
+			}
+		}
+		if total > TOTAL_TRAFFIC {
+			return fmt.Errorf("实验 %s 的流量总和 %d%% 超过 100%%", experiment.Name, total)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package experiment_module
+
+import (
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/common"
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+var testExperiments = []config.Experiment{
+	{
+		Name:  "draft-wording",
+		Stage: "draft",
+		Variants: []config.ExperimentVariant{
+			{Name: CONTROL, Traffic: 50},
+			{Name: "vivid", Traffic: 50},
+		},
+	},
+	// 与上一个实验同一阶段，不会被分配
+	{
+		Name:     "draft-other",
+		Stage:    "draft",
+		Variants: []config.ExperimentVariant{{Name: "other", Traffic: 100}},
+	},
+	{
+		Name:     "edit-partial",
+		Stage:    "edit",
+		Variants: []config.ExperimentVariant{{Name: "strict", Traffic: 10}},
+	},
+}
+
+func TestAssignIsDeterministic(t *testing.T) {
+	counts := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		requestID := fmt.Sprintf("request-%d", i)
+		assignments := assign(testExperiments, requestID)
+		again := assign(testExperiments, requestID)
+		if fmt.Sprint(assignments) != fmt.Sprint(again) {
+			t.Fatalf("同一请求 ID 的分配结果不一致: %v %v", assignments, again)
+		}
+		for _, assignment := range assignments {
+			if assignment.Experiment == "draft-other" {
+				t.Fatalf("同一阶段只应参与第一个实验: %v", assignments)
+			}
+			counts[assignment.Experiment+"/"+assignment.Variant]++
+		}
+	}
+
+	// 流量比例大致符合配置
+	if counts["draft-wording/control"] < 900 || counts["draft-wording/vivid"] < 900 {
+		t.Errorf("各变体的流量不均: %v", counts)
+	}
+	if counts["edit-partial/strict"] < 120 || counts["edit-partial/strict"] > 280 {
+		t.Errorf("未覆盖的流量不应参与实验: %v", counts)
+	}
+	if assign(testExperiments, "") != nil {
+		t.Error("请求 ID 为空时不应参与实验")
+	}
+}
+
+func TestVariants(t *testing.T) {
+	variants := Variants([]Assignment{
+		{Experiment: "a", Stage: "draft", Variant: CONTROL},
+		{Experiment: "b", Stage: "edit", Variant: "strict"},
+	})
+	if len(variants) != 1 || variants["edit"] != "strict" {
+		t.Errorf("变体映射错误: %v", variants)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []config.Experiment{{Name: "a", Stage: "draft", Variants: []config.ExperimentVariant{{Name: CONTROL, Traffic: 100}}}}
+	if err := Validate(valid); err != nil {
+		t.Errorf("合法配置校验失败: %v", err)
+	}
+	invalid := [][]config.Experiment{
+		{{Name: "a", Stage: "service", Variants: []config.ExperimentVariant{{Name: CONTROL, Traffic: 10}}}},
+		{{Name: "a", Stage: "draft", Variants: []config.ExperimentVariant{{Name: CONTROL, Traffic: 60}, {Name: CONTROL, Traffic: 10}}}},
+		{{Name: "a", Stage: "draft", Variants: []config.ExperimentVariant{{Name: CONTROL, Traffic: 101}}}},
+		// 没有对应的变体模板
+		{{Name: "a", Stage: "draft", Variants: []config.ExperimentVariant{{Name: "missing", Traffic: 10}}}},
+		append(valid, valid[0]),
+	}
+	for _, experiments := range invalid {
+		if err := Validate(experiments); err == nil {
+			t.Errorf("应校验失败: %+v", experiments)
+		}
+	}
+}
+
+func TestRecordsAndReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "experiments", "records.jsonl")
+	control := []Assignment{{Experiment: "draft-wording", Stage: "draft", Variant: CONTROL}}
+	vivid := []Assignment{{Experiment: "draft-wording", Stage: "draft", Variant: "vivid"}}
+	records := []Record{
+		{Type: RECORD_STORY, StoryID: "s1", Assignments: control, Scores: &common.Scores{Coherence: 6, Quality: 6, Fluency: 6, Total: 6}},
+		{Type: RECORD_STORY, StoryID: "s2", Assignments: control, Scores: &common.Scores{Coherence: 8, Quality: 8, Fluency: 8, Total: 8}},
+		{Type: RECORD_STORY, StoryID: "s3", Assignments: vivid, Scores: &common.Scores{Coherence: 9, Quality: 8, Fluency: 7, Total: 8.5}},
+		{Type: RECORD_FEEDBACK, StoryID: "s1", Rating: 3},
+		{Type: RECORD_FEEDBACK, StoryID: "s3", Rating: 5},
+		{Type: RECORD_FEEDBACK, StoryID: "s3", Rating: 4},
+		// 未参与实验的故事的反馈不计入
+		{Type: RECORD_FEEDBACK, StoryID: "unknown", Rating: 1},
+	}
+	for _, record := range records {
+		if err := appendRecord(path, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded, err := ReadRecords(path)
+	if err != nil || len(loaded) != len(records) {
+		t.Fatalf("读取记录失败: %d %v", len(loaded), err)
+	}
+
+	reports := BuildReport(loaded)
+	if len(reports) != 2 || reports[0].Variant != CONTROL || reports[1].Variant != "vivid" {
+		t.Fatalf("汇总结果错误: %+v", reports)
+	}
+	if reports[0].Stories != 2 || reports[0].Total != 7 || reports[0].Feedback != 1 || reports[0].Rating != 3 {
+		t.Errorf("对照组汇总错误: %+v", reports[0])
+	}
+	if reports[1].Feedback != 2 || reports[1].Rating != 4.5 {
+		t.Errorf("变体汇总错误: %+v", reports[1])
+	}
+	if math.Abs(reports[1].TotalDelta-1.5) > 1e-9 || math.Abs(reports[1].RatingDelta-1.5) > 1e-9 {
+		t.Errorf("与对照组的差值错误: %+v", reports[1])
+	}
+}
+
+func TestRecordFeedbackValidation(t *testing.T) {
+	if err := RecordFeedback("s1", 6, ""); err == nil {
+		t.Error("评分超出范围时应返回错误")
+	}
+	if err := RecordFeedback("", 3, ""); err == nil {
+		t.Error("缺少故事 ID 时应返回错误")
+	}
+}
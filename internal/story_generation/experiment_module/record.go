@@ -0,0 +1,133 @@
+package experiment_module
+
+import (
+	"bufio"
+	"encoding/json"
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/common"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 记录类型
+const (
+	RECORD_STORY    = "story"    // 生成的故事及其分配结果和评分
+	RECORD_FEEDBACK = "feedback" // 用户对故事的反馈
+)
+
+const (
+	MIN_RATING = 1
+	MAX_RATING = 5
+	// 读取记录时单行的最大字节数
+	MAX_RECORD_SIZE = 1 << 20
+)
+
+// Record 实验记录文件中的一行
+type Record struct {
+	Type      string `json:"type"`
+	StoryID   string `json:"story_id"`
+	RequestID string `json:"request_id,omitempty"`
+	Language  string `json:"language,omitempty"`
+	// 故事记录：分配结果及各段落评分的平均值
+	Assignments []Assignment   `json:"assignments,omitempty"`
+	Scores      *common.Scores `json:"scores,omitempty"`
+	// 反馈记录：1-5 分的评分和评论
+	Rating    int       `json:"rating,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var logMu sync.Mutex
+
+// RecordStory 记录参与实验的故事，没有分配结果或未配置记录文件时不记录
+func RecordStory(storyID string, requestID string, language string, assignments []Assignment, scores common.Scores) error {
+	if len(assignments) == 0 {
+		return nil
+	}
+	return appendRecord(config.GetConfig().Experiments.Log, Record{
+		Type:        RECORD_STORY,
+		StoryID:     storyID,
+		RequestID:   requestID,
+		Language:    language,
+		Assignments: assignments,
+		Scores:      &scores,
+		CreatedAt:   time.Now(),
+	})
+}
+
+// RecordFeedback 记录用户对故事的评分和评论
+func RecordFeedback(storyID string, rating int, comment string) error {
+	if storyID == "" {
+		return fmt.Errorf("缺少故事 ID")
+	}
+	if rating < MIN_RATING || rating > MAX_RATING {
+		return fmt.Errorf("评分必须在 %d-%d 之间", MIN_RATING, MAX_RATING)
+	}
+	path := config.GetConfig().Experiments.Log
+	if path == "" {
+		return fmt.Errorf("未配置实验记录文件 experiments.log")
+	}
+	return appendRecord(path, Record{
+		Type:      RECORD_FEEDBACK,
+		StoryID:   storyID,
+		Rating:    rating,
+		Comment:   comment,
+		CreatedAt: time.Now(),
+	})
+}
+
+// 追加一行记录，path 为空时不记录
+func appendRecord(path string, record Record) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("创建实验记录目录失败: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("打开实验记录文件失败: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入实验记录失败: %v", err)
+	}
+	return nil
+}
+
+// ReadRecords 读取实验记录文件
+func ReadRecords(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开实验记录文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), MAX_RECORD_SIZE)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("第 %d 行解析失败: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
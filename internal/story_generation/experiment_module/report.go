@@ -0,0 +1,114 @@
+package experiment_module
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// VariantReport 某个实验中一个变体的汇总
+type VariantReport struct {
+	Experiment string `json:"experiment"`
+	Stage      string `json:"stage"`
+	Variant    string `json:"variant"`
+	Stories    int    `json:"stories"`
+	// 各故事评分的平均值
+	Coherence float64 `json:"coherence"`
+	Quality   float64 `json:"quality"`
+	Fluency   float64 `json:"fluency"`
+	Total     float64 `json:"total"`
+	// 收到的反馈数及平均用户评分
+	Feedback int     `json:"feedback"`
+	Rating   float64 `json:"rating"`
+	// 与同一实验对照组的差值，没有对照组或本身是对照组时为 0
+	TotalDelta  float64 `json:"total_delta"`
+	RatingDelta float64 `json:"rating_delta"`
+}
+
+// BuildReport 按实验和变体汇总记录，反馈按故事 ID 归入故事所在的变体；
+// 结果按实验名称排序，同一实验中对照组在前
+func BuildReport(records []Record) []VariantReport {
+	// 故事 ID → 分配结果
+	stories := make(map[string][]Assignment)
+	ratings := make(map[string][]int)
+	for _, record := range records {
+		switch record.Type {
+		case RECORD_STORY:
+			stories[record.StoryID] = record.Assignments
+		case RECORD_FEEDBACK:
+			ratings[record.StoryID] = append(ratings[record.StoryID], record.Rating)
+		}
+	}
+
+	reports := make(map[Assignment]*VariantReport)
+	ratingSums := make(map[Assignment]int)
+	for _, record := range records {
+		if record.Type != RECORD_STORY || record.Scores == nil {
+			continue
+		}
+		for _, assignment := range record.Assignments {
+			report, ok := reports[assignment]
+			if !ok {
+				report = &VariantReport{Experiment: assignment.Experiment, Stage: assignment.Stage, Variant: assignment.Variant}
+				reports[assignment] = report
+			}
+			report.Stories++
+			report.Coherence += record.Scores.Coherence
+			report.Quality += record.Scores.Quality
+			report.Fluency += record.Scores.Fluency
+			report.Total += record.Scores.Total
+			for _, rating := range ratings[record.StoryID] {
+				report.Feedback++
+				ratingSums[assignment] += rating
+			}
+		}
+	}
+
+	var result []VariantReport
+	for assignment, report := range reports {
+		count := float64(report.Stories)
+		report.Coherence /= count
+		report.Quality /= count
+		report.Fluency /= count
+		report.Total /= count
+		if report.Feedback > 0 {
+			report.Rating = float64(ratingSums[assignment]) / float64(report.Feedback)
+		}
+		result = append(result, *report)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Experiment != result[j].Experiment {
+			return result[i].Experiment < result[j].Experiment
+		}
+		if (result[i].Variant == CONTROL) != (result[j].Variant == CONTROL) {
+			return result[i].Variant == CONTROL
+		}
+		return result[i].Variant < result[j].Variant
+	})
+
+	// 与对照组比较
+	for i := range result {
+		for _, control := range result {
+			if control.Experiment == result[i].Experiment && control.Variant == CONTROL && result[i].Variant != CONTROL {
+				result[i].TotalDelta = result[i].Total - control.Total
+				if result[i].Feedback > 0 && control.Feedback > 0 {
+					result[i].RatingDelta = result[i].Rating - control.Rating
+				}
+			}
+		}
+	}
+	return result
+}
+
+// WriteReport 以表格形式输出汇总结果
+func WriteReport(w io.Writer, reports []VariantReport) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "实验\t阶段\t变体\t故事数\t连贯性\t内容质量\t表达流畅度\t总分\t总分差\t反馈数\t用户评分\t评分差")
+	for _, r := range reports {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t%+.2f\t%d\t%.2f\t%+.2f\n",
+			r.Experiment, r.Stage, r.Variant, r.Stories, r.Coherence, r.Quality, r.Fluency, r.Total,
+			r.TotalDelta, r.Feedback, r.Rating, r.RatingDelta)
+	}
+	return table.Flush()
+}
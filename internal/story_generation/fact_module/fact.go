@@ -0,0 +1,119 @@
+// 事实账本模块：在每段成稿后提取事实，并检查段落与已知事实之间的矛盾
+package fact_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"fmt"
+	"strings"
+)
+
+// ExtractFacts 从段落中提取物品归属、角色位置、已揭示信息和时间等事实
+func ExtractFacts(draft common.Draft, content string) ([]common.Fact, error) {
+	prompt, err := constructExtractPrompt(draft, content)
+	if err != nil {
+		return nil, err
+	}
+	response, err := draft.Meter.ChatWithModel(prompt)
+	if err != nil {
+		return nil, fmt.Errorf("调用模型提取事实失败: %w", err)
+	}
+	return parseFacts(draft.Index, response, draft.Language), nil
+}
+
+// CheckContradictions 检查段落与已知事实之间仍然存在的矛盾
+// 参数：
+// - draft: 当前段落的上下文信息，需包含 Facts
+// - content: 编辑修正后的段落内容
+func CheckContradictions(draft common.Draft, content string) ([]common.ContinuityIssue, error) {
+	if draft.Facts == "" {
+		return nil, nil
+	}
+
+	prompt, err := constructContradictionPrompt(draft, content)
+	if err != nil {
+		return nil, err
+	}
+	response, err := draft.Meter.ChatWithModel(prompt)
+	if err != nil {
+		return nil, fmt.Errorf("调用模型检查事实矛盾失败: %w", err)
+	}
+	return parseContinuityIssues(draft.Index, response, draft.Language), nil
+}
+
+// 构建提取事实的提示词，类别使用该语言的名称
+func constructExtractPrompt(draft common.Draft, content string) (string, error) {
+	var categories []string
+	for _, category := range common.FACT_CATEGORIES {
+		categories = append(categories, common.FactCategoryName(category, draft.Language))
+	}
+	return prompt_module.Render("fact/extract", draft.Language, draft.PromptVariants, struct {
+		Content    string
+		Categories []string
+	}{content, categories})
+}
+
+// 构建检查事实矛盾的提示词
+func constructContradictionPrompt(draft common.Draft, content string) (string, error) {
+	return prompt_module.Render("fact/contradiction", draft.Language, draft.PromptVariants, struct {
+		Facts    string
+		Content  string
+		NoIssues string
+	}{draft.Facts, content, noIssues[common.GetLanguage(draft.Language).Code]})
+}
+
+// 解析事实列表，类别可以是该语言的名称，保存为对应的事实类别
+func parseFacts(sectionIndex int, response string, language string) []common.Fact {
+	var facts []common.Fact
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, "*", ""))
+		parts := strings.SplitN(line, "｜", 3)
+		if len(parts) < 3 {
+			parts = strings.SplitN(line, "|", 3)
+		}
+		if len(parts) < 3 {
+			continue
+		}
+
+		category, ok := common.ParseFactCategory(strings.TrimSpace(strings.TrimLeft(parts[0], "0123456789.-、 ")), language)
+		if !ok {
+			continue
+		}
+		fact := common.Fact{
+			SectionIndex: sectionIndex,
+			Category:     category,
+			Subject:      strings.TrimSpace(parts[1]),
+			Content:      strings.TrimSpace(parts[2]),
+		}
+		if fact.Content != "" {
+			facts = append(facts, fact)
+		}
+	}
+	return facts
+}
+
+// 解析事实矛盾列表
+func parseContinuityIssues(sectionIndex int, response string, language string) []common.ContinuityIssue {
+	none := strings.ToLower(noIssues[common.GetLanguage(language).Code])
+	var issues []common.ContinuityIssue
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, "*", ""))
+		if line == "" || strings.Contains(strings.ToLower(line), none) {
+			continue
+		}
+
+		parts := strings.SplitN(line, "：", 2)
+		if len(parts) < 2 {
+			parts = strings.SplitN(line, ":", 2)
+		}
+		if len(parts) < 2 || strings.TrimSpace(parts[1]) == "" {
+			continue
+		}
+		issues = append(issues, common.ContinuityIssue{
+			SectionIndex: sectionIndex,
+			Fact:         strings.TrimSpace(strings.TrimLeft(parts[0], "0123456789.-、 ")),
+			Description:  strings.TrimSpace(parts[1]),
+		})
+	}
+	return issues
+}
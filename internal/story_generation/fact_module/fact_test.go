@@ -0,0 +1,82 @@
+package fact_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"testing"
+)
+
+func TestParseFacts(t *testing.T) {
+	response := `物品｜会发光的星星｜小白拿着
+位置｜小白｜在山顶的大树下
+时间｜故事｜傍晚
+心情｜小白｜很开心
+这不是事实`
+
+	facts := parseFacts(1, response, common.LANG_ZH_HANS)
+	if len(facts) != 3 {
+		t.Fatalf("事实数量错误: %d, %+v", len(facts), facts)
+	}
+	if facts[0].Category != common.FACT_POSSESSION || facts[0].Subject != "会发光的星星" || facts[0].SectionIndex != 1 {
+		t.Errorf("事实解析错误: %+v", facts[0])
+	}
+}
+
+func TestParseEnglishFacts(t *testing.T) {
+	facts := parseFacts(0, "Item | golden key | Benny has it\nlocation | Benny | by the river\nmood | Benny | happy", common.LANG_EN)
+	if len(facts) != 2 || facts[0].Category != common.FACT_POSSESSION || facts[1].Category != common.FACT_LOCATION {
+		t.Errorf("英文事实解析错误: %+v", facts)
+	}
+	if formatted := common.FormatFacts(facts, common.LANG_EN); formatted != "item｜golden key｜Benny has it\nlocation｜Benny｜by the river" {
+		t.Errorf("英文事实格式化错误: %q", formatted)
+	}
+	if issues := parseContinuityIssues(0, "No contradictions.", common.LANG_EN); len(issues) != 0 {
+		t.Errorf("无矛盾时不应返回问题: %+v", issues)
+	}
+}
+
+func TestParseContinuityIssues(t *testing.T) {
+	if issues := parseContinuityIssues(2, "无矛盾", common.LANG_ZH_HANS); len(issues) != 0 {
+		t.Errorf("无矛盾时不应返回问题: %+v", issues)
+	}
+
+	issues := parseContinuityIssues(2, "1. 位置｜小白｜在山顶：段落中小白突然出现在河边", common.LANG_ZH_HANS)
+	if len(issues) != 1 || issues[0].Fact != "位置｜小白｜在山顶" || issues[0].SectionIndex != 2 {
+		t.Errorf("事实矛盾解析错误: %+v", issues)
+	}
+}
+
+func TestFactLedgerRelevant(t *testing.T) {
+	ledger := &common.FactLedger{}
+	ledger.Add(parseFacts(0, "位置｜小白｜在洞里\n时间｜故事｜傍晚\n物品｜雨伞｜小灰拿着", common.LANG_ZH_HANS)...)
+	ledger.Add(parseFacts(1, "位置｜小白｜在山顶\n时间｜故事｜深夜", common.LANG_ZH_HANS)...)
+
+	relevant := ledger.Relevant("小白在山顶看星星")
+	if len(relevant) != 2 {
+		t.Fatalf("相关事实数量错误: %+v", relevant)
+	}
+	if relevant[0].Content != "在山顶" {
+		t.Errorf("位置应以最新段落为准: %+v", relevant[0])
+	}
+	if relevant[1].Category != common.FACT_TIME || relevant[1].Content != "深夜" {
+		t.Errorf("应包含最新的时间: %+v", relevant[1])
+	}
+}
+
+// 物品按物品记录，只保留最新的持有者或状态
+func TestFactLedgerPossession(t *testing.T) {
+	ledger := &common.FactLedger{}
+	ledger.Add(parseFacts(0, "物品｜钥匙｜小白拿着\n揭示｜小白｜宝箱在山洞里", common.LANG_ZH_HANS)...)
+	ledger.Add(parseFacts(1, "物品｜钥匙｜小灰拿着\n揭示｜小白｜山洞里有熊", common.LANG_ZH_HANS)...)
+	ledger.Add(parseFacts(2, "物品｜钥匙｜掉进了河里", common.LANG_ZH_HANS)...)
+
+	current := ledger.Current()
+	if len(current) != 3 {
+		t.Fatalf("当前事实数量错误: %+v", current)
+	}
+	if current[0].Content != "掉进了河里" || current[0].SectionIndex != 2 {
+		t.Errorf("物品应只保留最新状态: %+v", current[0])
+	}
+	if current[1].Content != "宝箱在山洞里" || current[2].Content != "山洞里有熊" {
+		t.Errorf("揭示信息应累积: %+v", current[1:])
+	}
+}
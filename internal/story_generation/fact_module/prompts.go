@@ -0,0 +1,12 @@
+package fact_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+)
+
+// 没有发现事实矛盾时模型输出的内容；提取事实和检查矛盾的提示词见 prompt_module 的 fact/ 模板
+var noIssues = map[string]string{
+	common.LANG_ZH_HANS: "无矛盾",
+	common.LANG_ZH_HANT: "無矛盾",
+	common.LANG_EN:      "No contradictions",
+}
@@ -0,0 +1,324 @@
+// 异步任务模块：提交后立即返回任务 ID，由 worker 池在后台运行生成流程，
+// 客户端轮询任务的状态、阶段进度、已完成的段落（部分结果）和最终结果，避免长时间占用 HTTP 连接
+//
+// 任务保存在可替换的 Store 中（内存或磁盘）。运行任务的函数只存在于提交它的进程中，
+// 服务重启后仍未结束的任务标记为失败，需要重新提交。
+package job_module
+
+import (
+	"encoding/json"
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/moderation_module"
+	"flutterdreams/internal/story_generation/story_document"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_CONCURRENCY       = 2
+	DEFAULT_RETENTION_MINUTES = 24 * 60
+	// 等待运行的任务数上限，超过时拒绝提交
+	MAX_QUEUED_JOBS = 100
+	// 清理过期任务的间隔，保留时长更短时按保留时长清理
+	EXPIRE_INTERVAL = 10 * time.Minute
+)
+
+// 任务状态
+const (
+	STATUS_PENDING   = "pending"
+	STATUS_RUNNING   = "running"
+	STATUS_SUCCEEDED = "succeeded"
+	STATUS_FAILED    = "failed"
+	STATUS_BLOCKED   = "blocked" // 未通过内容审核
+)
+
+// 任务类型
+const (
+	KIND_GENERATE_STORY = "generateStory" // 完整流程，结果为故事文档
+	KIND_STORY          = "story"         // /story 的快速流程，结果为故事、音频和图片
+)
+
+// Section 已完成的段落（分支故事为节点），任务结束前即可展示
+type Section struct {
+	Index   int    `json:"index"`
+	Content string `json:"content"`
+}
+
+// Job 任务的状态、进度和结果
+type Job struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Status    string `json:"status"`
+	RequestID string `json:"request_id,omitempty"`
+	// 提交任务的用户 ID，未启用认证时为空
+	OwnerID string `json:"owner_id,omitempty"`
+	// 提交时的请求体
+	Input json.RawMessage `json:"input,omitempty"`
+	// 最近一次进度更新
+	Progress *common.Progress `json:"progress,omitempty"`
+	// 部分结果：已完成的段落，按完成顺序排列
+	Sections []Section `json:"sections,omitempty"`
+	// 最终结果，格式由任务类型决定
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	// 失败的错误类型，见 common.ERROR_*
+	ErrorCode string `json:"error_code,omitempty"`
+	// 被内容审核拦截时的审核结果
+	Moderation *moderation_module.Decision `json:"moderation,omitempty"`
+	CreatedAt  time.Time                   `json:"created_at"`
+	UpdatedAt  time.Time                   `json:"updated_at"`
+	FinishedAt time.Time                   `json:"finished_at,omitempty"`
+}
+
+// Done 任务是否已结束
+func (j *Job) Done() bool {
+	return j.Status == STATUS_SUCCEEDED || j.Status == STATUS_FAILED || j.Status == STATUS_BLOCKED
+}
+
+func (j *Job) clone() *Job {
+	copied := *j
+	copied.Sections = append([]Section(nil), j.Sections...)
+	if j.Progress != nil {
+		progress := *j.Progress
+		copied.Progress = &progress
+	}
+	return &copied
+}
+
+// Runner 任务的执行函数，通过 progress 报告进度，返回值序列化为 JSON 作为任务结果
+type Runner func(progress common.ProgressFunc) (interface{}, error)
+
+// Manager 把任务放入队列，由 worker 运行并把状态写入存储
+type Manager struct {
+	// mu 保证同一任务的读取、修改、写回不会交错
+	mu        sync.Mutex
+	store     Store
+	queue     chan string
+	runners   map[string]Runner
+	retention time.Duration
+}
+
+// NewManager 创建任务管理器并启动 concurrency 个 worker 和定时清理过期任务的 goroutine；
+// 存储中未结束的任务标记为失败
+func NewManager(store Store, concurrency int, retention time.Duration) (*Manager, error) {
+	if concurrency <= 0 {
+		concurrency = DEFAULT_CONCURRENCY
+	}
+	m := &Manager{
+		store:     store,
+		queue:     make(chan string, MAX_QUEUED_JOBS),
+		runners:   make(map[string]Runner),
+		retention: retention,
+	}
+	if err := m.recover(); err != nil {
+		return nil, err
+	}
+	for i := 0; i < concurrency; i++ {
+		go m.work()
+	}
+	if retention > 0 {
+		m.expire(time.Now())
+		interval := EXPIRE_INTERVAL
+		if retention < interval {
+			interval = retention
+		}
+		go m.expireEvery(interval)
+	}
+	return m, nil
+}
+
+var (
+	defaultOnce    sync.Once
+	defaultManager *Manager
+	defaultErr     error
+)
+
+// Default 按配置创建的全局任务管理器
+func Default() (*Manager, error) {
+	defaultOnce.Do(func() {
+		cfg := config.GetConfig().Jobs
+		store, err := NewStore(cfg.Store, cfg.Dir)
+		if err != nil {
+			defaultErr = err
+			return
+		}
+		retention := cfg.RetentionMinutes
+		if retention <= 0 {
+			retention = DEFAULT_RETENTION_MINUTES
+		}
+		defaultManager, defaultErr = NewManager(store, cfg.Concurrency, time.Duration(retention)*time.Minute)
+	})
+	return defaultManager, defaultErr
+}
+
+// 上次运行时未结束的任务无法继续，标记为失败
+func (m *Manager) recover() error {
+	jobs, err := m.store.List()
+	if err != nil {
+		return fmt.Errorf("读取任务失败: %v", err)
+	}
+	now := time.Now()
+	for _, job := range jobs {
+		if job.Done() {
+			continue
+		}
+		job.Status = STATUS_FAILED
+		job.Error = "服务重启，任务中断，请重新提交"
+		job.UpdatedAt = now
+		job.FinishedAt = now
+		if err := m.store.Put(job); err != nil {
+			return err
+		}
+		log.Printf("任务 %s 因服务重启中断", job.ID)
+	}
+	return nil
+}
+
+// Submit 提交任务，立即返回任务的初始状态；队列已满时返回错误
+func (m *Manager) Submit(kind string, ownerID string, requestID string, input interface{}, run Runner) (*Job, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	job := &Job{
+		ID:        story_document.NewID(),
+		Kind:      kind,
+		Status:    STATUS_PENDING,
+		RequestID: requestID,
+		OwnerID:   ownerID,
+		Input:     data,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.queue) >= cap(m.queue) {
+		return nil, fmt.Errorf("排队的任务过多，请稍后再提交")
+	}
+	if err := m.store.Put(job); err != nil {
+		return nil, err
+	}
+	m.runners[job.ID] = run
+	m.queue <- job.ID
+	log.Printf("任务 %s（%s）已提交", job.ID, kind)
+	return job, nil
+}
+
+// Get 任务的当前状态
+func (m *Manager) Get(id string) (*Job, error) {
+	return m.store.Get(id)
+}
+
+// 定时清理过期任务
+func (m *Manager) expireEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		m.expire(now)
+	}
+}
+
+// 删除结束时间超过保留时长的任务；已结束的任务不再被修改，不需要持有锁
+func (m *Manager) expire(now time.Time) {
+	if m.retention <= 0 {
+		return
+	}
+	jobs, err := m.store.List()
+	if err != nil {
+		log.Printf("清理过期任务时发生错误: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		if job.Done() && now.Sub(job.FinishedAt) > m.retention {
+			if err := m.store.Delete(job.ID); err != nil {
+				log.Printf("删除过期任务 %s 时发生错误: %v", job.ID, err)
+			}
+		}
+	}
+}
+
+// 读取任务、修改后写回存储
+func (m *Manager) update(id string, change func(job *Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, err := m.store.Get(id)
+	if err != nil {
+		log.Printf("读取任务 %s 时发生错误: %v", id, err)
+		return
+	}
+	change(job)
+	job.UpdatedAt = time.Now()
+	if err := m.store.Put(job); err != nil {
+		log.Printf("保存任务 %s 时发生错误: %v", id, err)
+	}
+}
+
+// worker 依次从队列取出任务运行
+func (m *Manager) work() {
+	for id := range m.queue {
+		m.mu.Lock()
+		run := m.runners[id]
+		delete(m.runners, id)
+		m.mu.Unlock()
+
+		m.update(id, func(job *Job) {
+			job.Status = STATUS_RUNNING
+		})
+		result, err := m.run(id, run)
+		m.finish(id, result, err)
+	}
+}
+
+// 执行一个任务；执行过程中的 panic 转为内部错误，只影响这一个任务，worker 继续处理后续任务
+func (m *Manager) run(id string, run Runner) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("任务 %s 发生 panic: %v\n%s", id, r, debug.Stack())
+			result, err = nil, fmt.Errorf("执行任务时发生内部错误: %v", r)
+		}
+	}()
+	return run(func(progress common.Progress) {
+		m.update(id, func(job *Job) {
+			if progress.Stage == common.PROGRESS_DRAFT && progress.Content != "" {
+				job.Sections = append(job.Sections, Section{Index: progress.Index, Content: progress.Content})
+			}
+			// 段落内容已记录在 Sections 中
+			progress.Content = ""
+			job.Progress = &progress
+		})
+	})
+}
+
+// 记录任务的结果或错误
+func (m *Manager) finish(id string, result interface{}, err error) {
+	var data []byte
+	if err == nil {
+		data, err = json.Marshal(result)
+	}
+	m.update(id, func(job *Job) {
+		job.FinishedAt = time.Now()
+		if blocked, ok := moderation_module.IsBlocked(err); ok {
+			job.Status = STATUS_BLOCKED
+			job.Error = blocked.Error()
+			job.ErrorCode = common.ERROR_BLOCKED
+			job.Moderation = &blocked.Decision
+		} else if err != nil {
+			job.Status = STATUS_FAILED
+			// 错误详情只写入日志
+			job.Error = common.PublicMessage(err)
+			job.ErrorCode = common.ErrorCode(err)
+		} else {
+			job.Status = STATUS_SUCCEEDED
+			job.Result = data
+		}
+	})
+	if err != nil {
+		log.Printf("任务 %s 失败: %v", id, err)
+	}
+}
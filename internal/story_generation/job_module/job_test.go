@@ -0,0 +1,204 @@
+package job_module
+
+import (
+	"encoding/json"
+	"errors"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/moderation_module"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 等待任务结束
+func waitDone(t *testing.T, m *Manager, id string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("读取任务失败: %v", err)
+		}
+		if job.Done() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("任务没有在限定时间内结束")
+	return nil
+}
+
+func TestStores(t *testing.T) {
+	disk, err := NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, store := range map[string]Store{STORE_MEMORY: NewMemoryStore(), STORE_DISK: disk} {
+		now := time.Now()
+		first := &Job{ID: "a-1", Status: STATUS_PENDING, CreatedAt: now, Progress: &common.Progress{Stage: common.PROGRESS_PLAN}}
+		second := &Job{ID: "b-2", Status: STATUS_SUCCEEDED, CreatedAt: now.Add(time.Second), Result: json.RawMessage(`{"story":"小兔子"}`)}
+		for _, job := range []*Job{second, first} {
+			if err := store.Put(job); err != nil {
+				t.Fatalf("%s: 保存任务失败: %v", name, err)
+			}
+		}
+		// 保存后修改原对象不影响存储中的任务
+		first.Progress.Stage = common.PROGRESS_DRAFT
+
+		job, err := store.Get("a-1")
+		if err != nil || job.Progress.Stage != common.PROGRESS_PLAN {
+			t.Errorf("%s: 读取任务错误: %+v %v", name, job, err)
+		}
+		jobs, err := store.List()
+		if err != nil || len(jobs) != 2 || jobs[0].ID != "a-1" || string(jobs[1].Result) != `{"story":"小兔子"}` {
+			t.Errorf("%s: 任务列表错误: %+v %v", name, jobs, err)
+		}
+		if err := store.Delete("a-1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Get("a-1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: 删除后应返回 ErrNotFound: %v", name, err)
+		}
+		if _, err := store.Get("../b-2"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: 无效 ID 应返回 ErrNotFound: %v", name, err)
+		}
+	}
+	if _, err := NewStore("sqlite", ""); err == nil {
+		t.Error("不支持的存储类型应返回错误")
+	}
+}
+
+func TestManager(t *testing.T) {
+	m, err := NewManager(NewMemoryStore(), 2, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	job, err := m.Submit(KIND_GENERATE_STORY, "user-1", "req-1", map[string]string{"premise": "小兔子"}, func(progress common.ProgressFunc) (interface{}, error) {
+		progress.Report(common.Progress{Stage: common.PROGRESS_PLAN, Done: 1, Total: 1})
+		progress.Report(common.Progress{Stage: common.PROGRESS_DRAFT, Done: 1, Total: 2, Index: 0, Content: "第一段"})
+		<-release
+		return map[string]string{"story": "第一段第二段"}, nil
+	})
+	if err != nil {
+		t.Fatalf("提交任务失败: %v", err)
+	}
+	if job.Status != STATUS_PENDING || job.OwnerID != "user-1" || string(job.Input) != `{"premise":"小兔子"}` {
+		t.Errorf("任务初始状态错误: %+v", job)
+	}
+
+	// 任务运行中即可读取进度和已完成的段落
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		running, _ := m.Get(job.ID)
+		if len(running.Sections) == 1 {
+			if running.Status != STATUS_RUNNING || running.Progress.Stage != common.PROGRESS_DRAFT || running.Progress.Content != "" {
+				t.Errorf("运行中的任务状态错误: %+v", running)
+			}
+			if running.Sections[0].Content != "第一段" {
+				t.Errorf("部分结果错误: %+v", running.Sections)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("没有收到进度更新")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(release)
+	done := waitDone(t, m, job.ID)
+	if done.Status != STATUS_SUCCEEDED || string(done.Result) != `{"story":"第一段第二段"}` {
+		t.Errorf("任务结果错误: %+v", done)
+	}
+
+	failed, _ := m.Submit(KIND_STORY, "", "", nil, func(common.ProgressFunc) (interface{}, error) {
+		return nil, common.Upstream("doubao", errors.New("401 invalid api key"))
+	})
+	blocked, _ := m.Submit(KIND_STORY, "", "", nil, func(common.ProgressFunc) (interface{}, error) {
+		return nil, &moderation_module.BlockedError{Decision: moderation_module.Decision{Stage: moderation_module.STAGE_INPUT}}
+	})
+	// 错误详情只写入日志，任务中只保存概括的说明
+	if job := waitDone(t, m, failed.ID); job.Status != STATUS_FAILED || job.Error != "调用 doubao 失败" || job.ErrorCode != common.ERROR_UPSTREAM {
+		t.Errorf("失败的任务状态错误: %+v", job)
+	}
+	if job := waitDone(t, m, blocked.ID); job.Status != STATUS_BLOCKED || job.Moderation == nil {
+		t.Errorf("被拦截的任务状态错误: %+v", job)
+	}
+}
+
+func TestRunnerPanic(t *testing.T) {
+	m, err := NewManager(NewMemoryStore(), 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	panicked, _ := m.Submit(KIND_STORY, "", "", nil, func(common.ProgressFunc) (interface{}, error) {
+		panic("nil map")
+	})
+	if job := waitDone(t, m, panicked.ID); job.Status != STATUS_FAILED || job.Error != "内部错误" || job.ErrorCode != common.ERROR_INTERNAL {
+		t.Errorf("panic 的任务状态错误: %+v", job)
+	}
+
+	// 唯一的 worker 仍可以处理后续任务
+	next, _ := m.Submit(KIND_STORY, "", "", nil, func(common.ProgressFunc) (interface{}, error) {
+		return "ok", nil
+	})
+	if job := waitDone(t, m, next.ID); job.Status != STATUS_SUCCEEDED {
+		t.Errorf("后续任务状态错误: %+v", job)
+	}
+}
+
+func TestRecoverAndExpire(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	store.Put(&Job{ID: "running", Status: STATUS_RUNNING, CreatedAt: old})
+	store.Put(&Job{ID: "finished", Status: STATUS_SUCCEEDED, CreatedAt: old, FinishedAt: old})
+
+	// 损坏的任务文件被跳过，不影响启动
+	if err := os.WriteFile(filepath.Join(store.dir, "broken.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewManager(store, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := m.Get("running")
+	if err != nil || job.Status != STATUS_FAILED || job.Error == "" {
+		t.Errorf("重启前未结束的任务应标记为失败: %+v %v", job, err)
+	}
+
+	// 启动时清理过期的任务
+	if _, err := m.Get("finished"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("过期的任务应被删除: %v", err)
+	}
+	jobs, err := store.List()
+	if err != nil || len(jobs) != 1 || jobs[0].ID != "running" {
+		t.Errorf("应跳过损坏的任务文件: %+v %v", jobs, err)
+	}
+}
+
+// 运行期间按保留时长定时清理，不依赖新任务的提交
+func TestExpireOnTicker(t *testing.T) {
+	store := NewMemoryStore()
+	m, err := NewManager(store, 1, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	store.Put(&Job{ID: "finished", Status: STATUS_SUCCEEDED, CreatedAt: now, FinishedAt: now})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := m.Get("finished"); errors.Is(err, ErrNotFound) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Error("过期的任务应被定时删除")
+}
@@ -0,0 +1,205 @@
+package job_module
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 任务存储类型
+const (
+	STORE_MEMORY = "memory"
+	STORE_DISK   = "disk"
+)
+
+// ErrNotFound 任务不存在或已过期
+var ErrNotFound = errors.New("任务不存在或已过期")
+
+// 任务 ID 只允许字母、数字和连字符，避免在磁盘存储中拼出目录外的路径
+var validID = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// Store 任务存储，Put 覆盖同 ID 的任务；实现需要支持并发调用
+type Store interface {
+	Put(job *Job) error
+	// Get 任务不存在时返回 ErrNotFound
+	Get(id string) (*Job, error)
+	// List 按创建时间排列的全部任务，无法读取的单个任务会被跳过
+	List() ([]*Job, error)
+	Delete(id string) error
+}
+
+// NewStore 按类型创建任务存储，kind 为空时使用内存存储
+func NewStore(kind string, dir string) (Store, error) {
+	switch kind {
+	case "", STORE_MEMORY:
+		return NewMemoryStore(), nil
+	case STORE_DISK:
+		return NewDiskStore(dir)
+	default:
+		return nil, fmt.Errorf("不支持的任务存储: %s", kind)
+	}
+}
+
+// MemoryStore 保存在内存中的任务，服务重启后丢失
+type MemoryStore struct {
+	mu   sync.RWMutex
+	jobs map[string]*Job
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]*Job)}
+}
+
+func (s *MemoryStore) Put(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = job.clone()
+	return nil
+}
+
+func (s *MemoryStore) Get(id string) (*Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return job.clone(), nil
+}
+
+func (s *MemoryStore) List() ([]*Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job.clone())
+	}
+	sortJobs(jobs)
+	return jobs, nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+// DiskStore 每个任务保存为目录中的一个 JSON 文件，服务重启后仍可查询
+type DiskStore struct {
+	dir string
+}
+
+// NewDiskStore 创建磁盘存储，目录不存在时自动创建
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("磁盘任务存储需要配置目录")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DiskStore{dir: dir}, nil
+}
+
+func (s *DiskStore) path(id string) (string, error) {
+	if !validID.MatchString(id) {
+		return "", ErrNotFound
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// Put 先写入临时文件再重命名，读取时不会读到写了一半的文件
+func (s *DiskStore) Put(job *Job) error {
+	path, err := s.path(job.ID)
+	if err != nil {
+		return fmt.Errorf("无效的任务 ID: %s", job.ID)
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(s.dir, job.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+func (s *DiskStore) Get(id string) (*Job, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("任务 %s 的文件已损坏: %v", id, err)
+	}
+	return &job, nil
+}
+
+func (s *DiskStore) List() ([]*Job, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		job, err := s.Get(strings.TrimSuffix(name, ".json"))
+		if errors.Is(err, ErrNotFound) {
+			// 读取目录后被删除
+			continue
+		}
+		if err != nil {
+			// 单个文件损坏不影响其他任务，也不阻止服务启动
+			log.Printf("跳过无法读取的任务文件 %s: %v", name, err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sortJobs(jobs)
+	return jobs, nil
+}
+
+func (s *DiskStore) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func sortJobs(jobs []*Job) {
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+}
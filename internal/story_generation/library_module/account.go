@@ -0,0 +1,118 @@
+package library_module
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// 账户与 API key：每个用户可以有多个 API key，故事和音频归创建它们的用户所有；
+// 管理员可以管理用户、查看所有人的故事。API key 只在创建时返回一次，故事库中只保存其 SHA-256 摘要。
+// 用户和 API key 各自可以设置用量限额，用量按用户、key 和日期累计。
+
+// API_KEY_PREFIX API key 的前缀，便于在日志和配置中识别
+const API_KEY_PREFIX = "fd_"
+
+var (
+	// ErrUnauthorized API key 不存在或已撤销
+	ErrUnauthorized = errors.New("API key 无效或已撤销")
+	// ErrNameTaken 用户名已被使用
+	ErrNameTaken = errors.New("用户名已存在")
+)
+
+// User 用户
+type User struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Admin bool   `json:"admin"`
+	// 用户所有 API key 合计的用量限额
+	Quota     Quota     `json:"quota"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIKey API key 的元数据，不包含 key 本身
+type APIKey struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name,omitempty"`
+	// key 的前几个字符，用于区分同一用户的多个 key
+	Prefix string `json:"prefix"`
+	// 这个 key 的用量限额，与用户的限额同时生效
+	Quota      Quota     `json:"quota"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+	Revoked    bool      `json:"revoked"`
+}
+
+// AccountStore 用户、API key、用量和音频归属的存储
+type AccountStore interface {
+	// CreateUser 用户名已存在时返回 ErrNameTaken
+	CreateUser(name string, admin bool, quota Quota) (*User, error)
+	// GetUser 用户不存在时返回 ErrNotFound
+	GetUser(id string) (*User, error)
+	ListUsers() ([]User, error)
+	// HasAdmin 是否已有管理员
+	HasAdmin() (bool, error)
+	// SetQuota 修改用户的限额，用户不存在时返回 ErrNotFound
+	SetQuota(userID string, quota Quota) error
+
+	// CreateKey 为用户创建 API key，返回 key 的元数据和 key 本身
+	CreateKey(userID string, name string) (*APIKey, string, error)
+	// GetKey key 不存在时返回 ErrNotFound
+	GetKey(id string) (*APIKey, error)
+	ListKeys(userID string) ([]APIKey, error)
+	// RevokeKey 撤销后不能再用于认证，key 不存在时返回 ErrNotFound
+	RevokeKey(id string) error
+	// SetKeyQuota 修改 key 的限额，key 不存在时返回 ErrNotFound
+	SetKeyQuota(id string, quota Quota) error
+	// Authenticate 返回 key 所属的用户及 key 的元数据，key 无效或已撤销时返回 ErrUnauthorized
+	Authenticate(key string) (*User, *APIKey, error)
+
+	// RecordUsage 把用量累加到用户和 key 在 at 当天的记录中
+	RecordUsage(userID string, keyID string, at time.Time, usage Usage) error
+	// UsageSince since 当天及之后的累计用量，keyID 为空时为用户所有 key 的合计
+	UsageSince(userID string, keyID string, since time.Time) (Usage, error)
+
+	// SetMediaOwner 记录本地音频文件的所有者
+	SetMediaOwner(file string, ownerID string) error
+	// MediaOwner 没有记录时返回 ErrNotFound
+	MediaOwner(file string) (string, error)
+}
+
+// 生成新的 API key：前缀加 32 字节随机数的十六进制
+func newKey() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return API_KEY_PREFIX + hex.EncodeToString(data), nil
+}
+
+// HashKey API key 的 SHA-256 摘要；key 是高熵随机数，不需要加盐或慢哈希
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Accounts 当前打开的故事库中的账户存储，故事库没有打开或不支持账户时为 nil
+func Accounts() AccountStore {
+	accounts, _ := Default().(AccountStore)
+	return accounts
+}
+
+// EnsureAdmin 还没有管理员时创建名为 admin 的管理员及其 API key，返回 key 本身；
+// 已有管理员时返回空字符串
+func EnsureAdmin(accounts AccountStore) (string, error) {
+	exists, err := accounts.HasAdmin()
+	if err != nil || exists {
+		return "", err
+	}
+	user, err := accounts.CreateUser("admin", true, Quota{})
+	if err != nil {
+		return "", err
+	}
+	_, key, err := accounts.CreateKey(user.ID, "bootstrap")
+	return key, err
+}
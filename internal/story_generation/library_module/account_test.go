@@ -0,0 +1,189 @@
+package library_module
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccounts(t *testing.T) {
+	store := openTestStore(t)
+	user, err := store.CreateUser("王老师", false, Quota{StoriesPerDay: 5})
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	if _, err := store.CreateUser("王老师", false, Quota{}); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("重复的用户名应返回 ErrNameTaken: %v", err)
+	}
+
+	key, plain, err := store.CreateKey(user.ID, "教室电脑")
+	if err != nil {
+		t.Fatalf("创建 API key 失败: %v", err)
+	}
+	if !strings.HasPrefix(plain, API_KEY_PREFIX) || !strings.HasPrefix(plain, key.Prefix) {
+		t.Errorf("API key 格式错误: %s, %+v", plain, key)
+	}
+	// 数据库中只保存摘要
+	var stored int
+	store.db.QueryRow("SELECT COUNT(*) FROM api_keys WHERE hash = ?", plain).Scan(&stored)
+	if stored != 0 {
+		t.Error("数据库中不应保存 API key 本身")
+	}
+
+	authenticated, authenticatedKey, err := store.Authenticate(plain)
+	if err != nil || authenticated.ID != user.ID || authenticated.Quota.StoriesPerDay != 5 || authenticatedKey.ID != key.ID {
+		t.Fatalf("认证失败: %+v, %+v, %v", authenticated, authenticatedKey, err)
+	}
+	keys, _ := store.ListKeys(user.ID)
+	if len(keys) != 1 || keys[0].LastUsedAt.IsZero() {
+		t.Errorf("应记录最近使用时间: %+v", keys)
+	}
+	if _, _, err := store.Authenticate(plain + "0"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("错误的 key 应返回 ErrUnauthorized: %v", err)
+	}
+
+	if err := store.RevokeKey(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Authenticate(plain); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("撤销后的 key 应返回 ErrUnauthorized: %v", err)
+	}
+	if err := store.RevokeKey("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("撤销不存在的 key 应返回 ErrNotFound: %v", err)
+	}
+	if _, _, err := store.CreateKey("missing", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("为不存在的用户创建 key 应返回 ErrNotFound: %v", err)
+	}
+
+	if err := store.SetMediaOwner("a.mp3", user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if owner, err := store.MediaOwner("a.mp3"); err != nil || owner != user.ID {
+		t.Errorf("音频所有者错误: %s, %v", owner, err)
+	}
+	if _, err := store.MediaOwner("b.mp3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("没有记录的音频应返回 ErrNotFound: %v", err)
+	}
+}
+
+func TestEnsureAdmin(t *testing.T) {
+	store := openTestStore(t)
+	key, err := EnsureAdmin(store)
+	if err != nil || key == "" {
+		t.Fatalf("创建管理员失败: %s, %v", key, err)
+	}
+	admin, _, err := store.Authenticate(key)
+	if err != nil || !admin.Admin || admin.Name != "admin" {
+		t.Errorf("管理员错误: %+v, %v", admin, err)
+	}
+	if key, err := EnsureAdmin(store); err != nil || key != "" {
+		t.Errorf("已有管理员时不应再次创建: %s, %v", key, err)
+	}
+}
+
+func TestListByOwner(t *testing.T) {
+	store := openTestStore(t)
+	mine := newTestStory(t, "小兔子找朋友", nil, "", "", time.Now())
+	mine.OwnerID = "user-1"
+	other := newTestStory(t, "勇敢的小龙", nil, "", "", time.Now())
+	other.OwnerID = "user-2"
+	for _, story := range []*Story{mine, other} {
+		if err := store.Save(story); err != nil {
+			t.Fatal(err)
+		}
+	}
+	page, err := store.List(Query{OwnerID: "user-1"})
+	if err != nil || page.Total != 1 || page.Stories[0].ID != mine.ID || page.Stories[0].OwnerID != "user-1" {
+		t.Errorf("按所有者筛选错误: %+v, %v", page, err)
+	}
+	if loaded, _ := store.Get(other.ID); loaded.OwnerID != "user-2" {
+		t.Errorf("所有者错误: %+v", loaded.Summary)
+	}
+}
+
+func TestQuotaAndUsage(t *testing.T) {
+	store := openTestStore(t)
+	user, _ := store.CreateUser("王老师", false, Quota{})
+	key, _, _ := store.CreateKey(user.ID, "")
+	other, _, _ := store.CreateKey(user.ID, "")
+
+	quota := Quota{StoriesPerDay: 3, TokensPerMonth: 1000, AudioMinutesPerMonth: 10, ImagesPerMonth: 5}
+	if err := store.SetQuota(user.ID, quota); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetKeyQuota(key.ID, Quota{StoriesPerDay: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetQuota("missing", quota); !errors.Is(err, ErrNotFound) {
+		t.Errorf("修改不存在的用户应返回 ErrNotFound: %v", err)
+	}
+	if loaded, _ := store.GetUser(user.ID); loaded.Quota != quota {
+		t.Errorf("用户限额错误: %+v", loaded.Quota)
+	}
+	if loaded, _ := store.GetKey(key.ID); loaded.Quota.StoriesPerDay != 1 {
+		t.Errorf("key 限额错误: %+v", loaded.Quota)
+	}
+
+	now := time.Now()
+	lastMonth := StartOfMonth(now).AddDate(0, -1, 0)
+	records := []struct {
+		keyID string
+		at    time.Time
+		usage Usage
+	}{
+		{key.ID, now, Usage{Stories: 1, PromptTokens: 100, CompletionTokens: 200, AudioSeconds: 90, Images: 1}},
+		{key.ID, now, Usage{Stories: 1, PromptTokens: 10}},
+		{other.ID, now, Usage{Images: 2}},
+		{key.ID, lastMonth, Usage{Stories: 5, PromptTokens: 5000}},
+	}
+	for _, record := range records {
+		if err := store.RecordUsage(user.ID, record.keyID, record.at, record.usage); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	today, _ := store.UsageSince(user.ID, "", StartOfDay(now))
+	if today != (Usage{Stories: 2, PromptTokens: 110, CompletionTokens: 200, AudioSeconds: 90, Images: 3}) {
+		t.Errorf("用户当天的用量错误: %+v", today)
+	}
+	keyToday, _ := store.UsageSince(user.ID, key.ID, StartOfDay(now))
+	if keyToday.Stories != 2 || keyToday.Images != 1 {
+		t.Errorf("key 当天的用量错误: %+v", keyToday)
+	}
+	month, _ := store.UsageSince(user.ID, "", StartOfMonth(now))
+	if month.Stories != 2 {
+		t.Errorf("上个月的用量不应计入本月: %+v", month)
+	}
+	if empty, err := store.UsageSince("missing", "", StartOfMonth(now)); err != nil || !empty.Empty() {
+		t.Errorf("没有用量时应为 0: %+v, %v", empty, err)
+	}
+}
+
+func TestQuotaExceeded(t *testing.T) {
+	quota := Quota{StoriesPerDay: 3, TokensPerMonth: 1000, AudioMinutesPerMonth: 1, ImagesPerMonth: 2}
+	cases := []struct {
+		today, month, demand Usage
+		expected             []string
+	}{
+		{Usage{Stories: 2}, Usage{PromptTokens: 999}, Usage{Stories: 1}, nil},
+		{Usage{Stories: 3}, Usage{}, Usage{Stories: 1}, []string{QUOTA_STORIES_PER_DAY}},
+		{Usage{}, Usage{PromptTokens: 500, CompletionTokens: 500}, Usage{Stories: 1}, []string{QUOTA_TOKENS_PER_MONTH}},
+		// 加上预估的 token 数会超出时同样不能执行
+		{Usage{}, Usage{PromptTokens: 600}, Usage{Stories: 1, PromptTokens: 400}, nil},
+		{Usage{}, Usage{PromptTokens: 601}, Usage{Stories: 1, PromptTokens: 400}, []string{QUOTA_TOKENS_PER_MONTH}},
+		// 不生成故事时不检查故事数和 token 数
+		{Usage{Stories: 3}, Usage{PromptTokens: 1000}, Usage{AudioSeconds: 30}, nil},
+		{Usage{}, Usage{AudioSeconds: 40}, Usage{AudioSeconds: 30}, []string{QUOTA_AUDIO_MINUTES_PER_MONTH}},
+		{Usage{}, Usage{Images: 2}, Usage{Stories: 1, Images: 1}, []string{QUOTA_IMAGES_PER_MONTH}},
+	}
+	for _, c := range cases {
+		exceeded := quota.Exceeded(c.today, c.month, c.demand)
+		if strings.Join(exceeded, ",") != strings.Join(c.expected, ",") {
+			t.Errorf("%+v %+v %+v: 超出 %v，期望 %v", c.today, c.month, c.demand, exceeded, c.expected)
+		}
+	}
+	if exceeded := (Quota{}).Exceeded(Usage{Stories: 100}, Usage{Images: 100}, Usage{Stories: 1, Images: 1}); len(exceeded) != 0 {
+		t.Errorf("没有限额时不应超出: %v", exceeded)
+	}
+}
@@ -0,0 +1,173 @@
+// 故事库模块：持久化保存生成的故事文档、媒体引用、请求参数和生成元数据，
+// 支持分页列出、按标题、角色名、故事类型、年龄段和创建时间筛选、中文全文检索、查看和删除；
+// 启用认证时还保存用户、API key 以及故事和音频的所有者
+package library_module
+
+import (
+	"encoding/json"
+	"errors"
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/story_document"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// DEFAULT_PATH 未配置 library.path 时的数据库文件
+	DEFAULT_PATH      = "data/stories.db"
+	DEFAULT_PAGE_SIZE = 20
+	MAX_PAGE_SIZE     = 100
+)
+
+// 故事来源
+const (
+	SOURCE_STORY          = "story"         // /story 的快速流程
+	SOURCE_GENERATE_STORY = "generateStory" // 完整生成流程
+	SOURCE_CONTINUE_STORY = "continueStory" // 续写或续集
+)
+
+// ErrNotFound 故事不存在
+var ErrNotFound = errors.New("故事不存在")
+
+// Story 故事库中的一个故事
+type Story struct {
+	Summary
+	// 生成故事时的请求体
+	Request  json.RawMessage               `json:"request,omitempty"`
+	Document *story_document.StoryDocument `json:"document"`
+}
+
+// Summary 列表中展示的故事摘要，字段取自故事文档和请求参数，用于筛选
+type Summary struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	// 所有者的用户 ID，未启用认证时为空
+	OwnerID    string    `json:"owner_id,omitempty"`
+	Title      string    `json:"title"`
+	Premise    string    `json:"premise"`
+	StoryType  string    `json:"story_type,omitempty"`
+	AgeGroup   string    `json:"age_group,omitempty"`
+	Language   string    `json:"language,omitempty"`
+	Model      string    `json:"model,omitempty"`
+	Characters []string  `json:"characters,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Query 列表的筛选条件和分页，Title、Character 按包含匹配，StoryType、AgeGroup 按相等匹配
+type Query struct {
+	// 只列出该用户的故事，为空时不限
+	OwnerID string
+	// 全文检索标题、正文、角色名和标签，非空时结果按相关度排列
+	Text      string
+	Title     string
+	Character string
+	StoryType string
+	AgeGroup  string
+	// 创建时间范围 [CreatedFrom, CreatedTo)，零值表示不限
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// 页码从 1 开始
+	Page     int
+	PageSize int
+}
+
+// normalize 补全默认页码和每页数量
+func (q Query) normalize() Query {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = DEFAULT_PAGE_SIZE
+	}
+	if q.PageSize > MAX_PAGE_SIZE {
+		q.PageSize = MAX_PAGE_SIZE
+	}
+	return q
+}
+
+// Page 一页故事摘要，按创建时间从新到旧排列（全文检索时按相关度）
+type Page struct {
+	Stories  []Summary `json:"stories"`
+	Total    int       `json:"total"`
+	Page     int       `json:"page"`
+	PageSize int       `json:"page_size"`
+}
+
+// Store 故事存储
+type Store interface {
+	// Save 保存故事，ID 相同时覆盖
+	Save(story *Story) error
+	// Get 故事不存在时返回 ErrNotFound
+	Get(id string) (*Story, error)
+	List(query Query) (Page, error)
+	// Delete 故事不存在时返回 ErrNotFound
+	Delete(id string) error
+	Close() error
+}
+
+// NewStory 根据故事文档和请求体构造故事库条目，摘要字段取自文档
+func NewStory(source string, storyType string, request interface{}, doc *story_document.StoryDocument) (*Story, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	story := &Story{
+		Summary: Summary{
+			ID:        doc.ID,
+			Source:    source,
+			Title:     doc.Title,
+			Premise:   doc.Premise,
+			StoryType: storyType,
+			AgeGroup:  doc.AgeGroup,
+			Language:  doc.Language,
+			Model:     doc.Metadata.Model,
+			CreatedAt: doc.Metadata.CreatedAt,
+		},
+		Request:  data,
+		Document: doc,
+	}
+	if story.ID == "" {
+		story.ID = story_document.NewID()
+		doc.ID = story.ID
+	}
+	if story.CreatedAt.IsZero() {
+		story.CreatedAt = time.Now()
+	}
+	for _, character := range doc.Characters {
+		story.Characters = append(story.Characters, character.Name)
+	}
+	return story, nil
+}
+
+var (
+	mu      sync.Mutex
+	current Store
+)
+
+// Open 按配置打开故事库，配置为禁用时不打开
+func Open(cfg config.LibraryConfig) error {
+	if cfg.Disabled {
+		return nil
+	}
+	path := cfg.Path
+	if path == "" {
+		path = DEFAULT_PATH
+	}
+	store, err := OpenSQLite(path)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	current = store
+	mu.Unlock()
+	log.Printf("故事库: %s", path)
+	return nil
+}
+
+// Default 当前打开的故事库，没有打开时为 nil
+func Default() Store {
+	mu.Lock()
+	defer mu.Unlock()
+	return current
+}
@@ -0,0 +1,158 @@
+package library_module
+
+import (
+	"errors"
+	"flutterdreams/internal/story_generation/story_document"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 打开临时目录中的故事库
+func openTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	store, err := OpenSQLite(filepath.Join(t.TempDir(), "library", "stories.db"))
+	if err != nil {
+		t.Fatalf("打开故事库失败: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// 构造测试用的故事
+func newTestStory(t *testing.T, title string, characters []string, storyType string, ageGroup string, createdAt time.Time) *Story {
+	t.Helper()
+	doc := &story_document.StoryDocument{
+		Version:   story_document.CURRENT_VERSION,
+		ID:        story_document.NewID(),
+		Title:     title,
+		Premise:   title + "的故事",
+		FinalText: "从前，" + title,
+		AgeGroup:  ageGroup,
+		Language:  "zh-Hans",
+		Metadata:  story_document.Metadata{Generator: story_document.GENERATOR, Model: "deepseek", CreatedAt: createdAt},
+	}
+	for _, name := range characters {
+		doc.Characters = append(doc.Characters, story_document.Character{Name: name})
+	}
+	doc.AddMedia(story_document.MEDIA_AUDIO, "http://localhost:8080/getAudio?filename=a.mp3", "")
+	story, err := NewStory(SOURCE_STORY, storyType, map[string]string{"story_content": title}, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return story
+}
+
+func TestSaveAndGet(t *testing.T) {
+	store := openTestStore(t)
+	story := newTestStory(t, "小兔子找朋友", []string{"小白", "大熊"}, "童话", "3-5岁", time.Now())
+	if err := store.Save(story); err != nil {
+		t.Fatalf("保存故事失败: %v", err)
+	}
+
+	loaded, err := store.Get(story.ID)
+	if err != nil {
+		t.Fatalf("读取故事失败: %v", err)
+	}
+	if loaded.Title != "小兔子找朋友" || loaded.StoryType != "童话" || len(loaded.Characters) != 2 || loaded.Characters[1] != "大熊" {
+		t.Errorf("故事摘要错误: %+v", loaded.Summary)
+	}
+	if loaded.Document.FinalText != "从前，小兔子找朋友" || len(loaded.Document.Media) != 1 || loaded.Document.Metadata.Model != "deepseek" {
+		t.Errorf("故事文档错误: %+v", loaded.Document)
+	}
+	if string(loaded.Request) != `{"story_content":"小兔子找朋友"}` {
+		t.Errorf("请求参数错误: %s", loaded.Request)
+	}
+	if loaded.CreatedAt.UnixMilli() != story.CreatedAt.UnixMilli() {
+		t.Errorf("创建时间错误: %v", loaded.CreatedAt)
+	}
+
+	if err := store.Delete(story.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(story.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("删除后应返回 ErrNotFound: %v", err)
+	}
+	if err := store.Delete(story.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("删除不存在的故事应返回 ErrNotFound: %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	store := openTestStore(t)
+	start := time.Now().Add(-time.Hour)
+	stories := []*Story{
+		newTestStory(t, "小兔子找朋友", []string{"小白"}, "童话", "3-5岁", start),
+		newTestStory(t, "勇敢的小龙", []string{"龙宝", "小白"}, "冒险", "6-8岁", start.Add(time.Minute)),
+		newTestStory(t, "100%的小熊", []string{"大熊"}, "童话", "6-8岁", start.Add(2*time.Minute)),
+	}
+	for _, story := range stories {
+		if err := store.Save(story); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		query    Query
+		expected []string
+	}{
+		{Query{}, []string{"100%的小熊", "勇敢的小龙", "小兔子找朋友"}},
+		{Query{Title: "小"}, []string{"100%的小熊", "勇敢的小龙", "小兔子找朋友"}},
+		{Query{Title: "%"}, []string{"100%的小熊"}},
+		{Query{Character: "小白"}, []string{"勇敢的小龙", "小兔子找朋友"}},
+		{Query{StoryType: "童话", AgeGroup: "6-8岁"}, []string{"100%的小熊"}},
+		{Query{PageSize: 2, Page: 2}, []string{"小兔子找朋友"}},
+	}
+	for _, c := range cases {
+		page, err := store.List(c.query)
+		if err != nil {
+			t.Fatalf("%+v: 列出故事失败: %v", c.query, err)
+		}
+		var titles []string
+		for _, summary := range page.Stories {
+			titles = append(titles, summary.Title)
+		}
+		if len(titles) != len(c.expected) {
+			t.Errorf("%+v: 结果为 %v，期望 %v", c.query, titles, c.expected)
+			continue
+		}
+		for i := range titles {
+			if titles[i] != c.expected[i] {
+				t.Errorf("%+v: 结果为 %v，期望 %v", c.query, titles, c.expected)
+				break
+			}
+		}
+	}
+
+	page, _ := store.List(Query{PageSize: 2})
+	if page.Total != 3 || page.Page != 1 || page.PageSize != 2 || len(page.Stories) != 2 {
+		t.Errorf("分页信息错误: %+v", page)
+	}
+	page, _ = store.List(Query{PageSize: 1000})
+	if page.PageSize != MAX_PAGE_SIZE {
+		t.Errorf("每页数量应限制为 %d: %d", MAX_PAGE_SIZE, page.PageSize)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stories.db")
+	store, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	story := newTestStory(t, "小兔子找朋友", nil, "", "", time.Now())
+	if err := store.Save(story); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// 再次打开时不重复执行已完成的升级
+	store, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("再次打开故事库失败: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.Get(story.ID); err != nil {
+		t.Errorf("重新打开后应能读取故事: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package library_module
+
+import "time"
+
+// 限额的各项名称
+const (
+	QUOTA_STORIES_PER_DAY         = "stories_per_day"
+	QUOTA_TOKENS_PER_MONTH        = "tokens_per_month"
+	QUOTA_AUDIO_MINUTES_PER_MONTH = "audio_minutes_per_month"
+	QUOTA_IMAGES_PER_MONTH        = "images_per_month"
+)
+
+// Quota 用户或 API key 的用量限额，各项为 0 时不限
+type Quota struct {
+	StoriesPerDay        int `json:"stories_per_day"`
+	TokensPerMonth       int `json:"tokens_per_month"`
+	AudioMinutesPerMonth int `json:"audio_minutes_per_month"`
+	ImagesPerMonth       int `json:"images_per_month"`
+}
+
+// Usage 一段时间内的用量：生成的故事数、模型 token 数（按文本估算）、合成的音频时长和生成的图片数
+type Usage struct {
+	Stories          int `json:"stories"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	AudioSeconds     int `json:"audio_seconds"`
+	Images           int `json:"images"`
+}
+
+// Tokens 提示词和回复的 token 数之和
+func (u Usage) Tokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Empty 是否没有任何用量
+func (u Usage) Empty() bool {
+	return u == Usage{}
+}
+
+// Exceeded 检查本次请求能否执行，返回会超出的各项限额名称。today、month 为当天和当月已有的用量，
+// demand 为本次请求将产生的用量：生成故事时检查故事数和 token 数（token 数在生成前无法预知，demand 中为预估值，
+// 已用完或加上预估值会超出时都不能执行），AudioSeconds、Images 大于 0 时检查音频时长和图片数
+func (q Quota) Exceeded(today Usage, month Usage, demand Usage) []string {
+	var exceeded []string
+	if demand.Stories > 0 && q.StoriesPerDay > 0 && today.Stories+demand.Stories > q.StoriesPerDay {
+		exceeded = append(exceeded, QUOTA_STORIES_PER_DAY)
+	}
+	if demand.Stories > 0 && q.TokensPerMonth > 0 && (month.Tokens() >= q.TokensPerMonth || month.Tokens()+demand.Tokens() > q.TokensPerMonth) {
+		exceeded = append(exceeded, QUOTA_TOKENS_PER_MONTH)
+	}
+	if demand.AudioSeconds > 0 && q.AudioMinutesPerMonth > 0 && month.AudioSeconds+demand.AudioSeconds > q.AudioMinutesPerMonth*60 {
+		exceeded = append(exceeded, QUOTA_AUDIO_MINUTES_PER_MONTH)
+	}
+	if demand.Images > 0 && q.ImagesPerMonth > 0 && month.Images+demand.Images > q.ImagesPerMonth {
+		exceeded = append(exceeded, QUOTA_IMAGES_PER_MONTH)
+	}
+	return exceeded
+}
+
+// 用量按天记录，日期为本地时间的 2006-01-02
+const dayFormat = "2006-01-02"
+
+// StartOfDay 当天零点
+func StartOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// StartOfMonth 当月一日零点
+func StartOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
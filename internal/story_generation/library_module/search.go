@@ -0,0 +1,129 @@
+package library_module
+
+import (
+	"flutterdreams/internal/story_generation/story_document"
+	"strings"
+	"unicode"
+)
+
+// 全文检索：SQLite FTS5 的 unicode61 分词器把连续的汉字当作一个词，无法检索句子中间的词语，
+// 因此写入索引前先在 Go 中分词：中日韩文字切成重叠的二元组（"小龙怕黑" → 小龙 龙怕 怕黑 黑），
+// 每段末尾的单字也作为一个词，这样任何单字都是某个词的开头，单字查询可用前缀匹配；
+// 其他文字按字母数字切分并转为小写。
+
+// 索引列在 bm25 中的权重，依次为 story_id、title、text、characters、tags
+const rankExpression = "bm25(stories_fts, 0, 10, 1, 5, 3)"
+
+// 是否为按二元组切分的文字
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// 把文本切分为连续的中日韩文字段和字母数字词
+func splitRuns(text string) (runs [][]rune, words []string) {
+	var run []rune
+	var word strings.Builder
+	flush := func() {
+		if len(run) > 0 {
+			runs = append(runs, run)
+			run = nil
+		}
+		if word.Len() > 0 {
+			words = append(words, strings.ToLower(word.String()))
+			word.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if word.Len() > 0 {
+				flush()
+			}
+			run = append(run, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if len(run) > 0 {
+				flush()
+			}
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return runs, words
+}
+
+// tokenize 把文本转换为写入索引的词，以空格分隔
+func tokenize(texts ...string) string {
+	var tokens []string
+	for _, text := range texts {
+		runs, words := splitRuns(text)
+		for _, run := range runs {
+			for i := 0; i+1 < len(run); i++ {
+				tokens = append(tokens, string(run[i:i+2]))
+			}
+			tokens = append(tokens, string(run[len(run)-1]))
+		}
+		tokens = append(tokens, words...)
+	}
+	return strings.Join(tokens, " ")
+}
+
+// matchExpression 把检索词转换为 FTS5 查询：多字的中文词要求包含其全部二元组，
+// 单字和其他文字的词按前缀匹配，各个词之间为“且”的关系；没有可检索的词时返回空字符串
+func matchExpression(text string) string {
+	var terms []string
+	runs, words := splitRuns(text)
+	for _, run := range runs {
+		if len(run) == 1 {
+			terms = append(terms, quoteTerm(string(run))+"*")
+			continue
+		}
+		for i := 0; i+1 < len(run); i++ {
+			terms = append(terms, quoteTerm(string(run[i:i+2])))
+		}
+	}
+	for _, word := range words {
+		terms = append(terms, quoteTerm(word)+"*")
+	}
+	return strings.Join(terms, " AND ")
+}
+
+func quoteTerm(term string) string {
+	return `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+}
+
+// 故事在索引各列中的内容
+type indexEntry struct {
+	Title      string
+	Text       string
+	Characters string
+	Tags       string
+}
+
+// 根据故事文档生成索引内容：正文包括前提、背景和全文（没有全文时为各段落），
+// 标签为故事类型、教学目标、目标词汇和角色种类
+func newIndexEntry(storyType string, doc *story_document.StoryDocument) indexEntry {
+	text := []string{doc.Premise, doc.Setting, doc.FinalText}
+	if doc.FinalText == "" {
+		for _, section := range doc.Sections {
+			text = append(text, section.Content)
+		}
+	}
+	var characters []string
+	tags := []string{storyType}
+	for _, character := range doc.Characters {
+		characters = append(characters, character.Name)
+		tags = append(tags, character.Species)
+	}
+	if doc.Goals != nil {
+		tags = append(tags, doc.Goals.Objectives...)
+		tags = append(tags, doc.Goals.Vocabulary...)
+	}
+	return indexEntry{
+		Title:      tokenize(doc.Title),
+		Text:       tokenize(text...),
+		Characters: tokenize(characters...),
+		Tags:       tokenize(tags...),
+	}
+}
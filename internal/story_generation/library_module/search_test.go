@@ -0,0 +1,168 @@
+package library_module
+
+import (
+	"database/sql"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/plan_module"
+	"flutterdreams/internal/story_generation/story_document"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenize(t *testing.T) {
+	cases := map[string]string{
+		"小龙怕黑":            "小龙 龙怕 怕黑 黑",
+		"龙":               "龙",
+		"勇敢的Dragon，Tom2只": "勇敢 敢的 的 只 dragon tom2",
+		"":                "",
+	}
+	for text, expected := range cases {
+		if actual := tokenize(text); actual != expected {
+			t.Errorf("tokenize(%q) = %q，期望 %q", text, actual, expected)
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	cases := map[string]string{
+		"怕黑的小龙":    `"怕黑" AND "黑的" AND "的小" AND "小龙"`,
+		"龙 dragon": `"龙"* AND "dragon"*`,
+		`a"b`:      `"a"* AND "b"*`,
+		"，。！":      "",
+	}
+	for text, expected := range cases {
+		if actual := matchExpression(text); actual != expected {
+			t.Errorf("matchExpression(%q) = %q，期望 %q", text, actual, expected)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	store := openTestStore(t)
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	dragon := newTestStory(t, "勇敢的小龙", []string{"龙宝"}, "冒险", "3-5岁", start)
+	dragon.Document.FinalText = "小龙龙宝很怕黑，每天晚上都不敢睡觉。"
+	rabbit := newTestStory(t, "小兔子找朋友", []string{"小白"}, "童话", "3-5岁", start.AddDate(0, 0, 1))
+	rabbit.Document.FinalText = "小白在森林里遇到了一条小龙。"
+	bear := newTestStory(t, "The Brave Bear", []string{"Teddy"}, "童话", "6-8岁", start.AddDate(0, 0, 2))
+	bear.Document.FinalText = "Teddy was afraid of the dark."
+	for _, story := range []*Story{dragon, rabbit, bear} {
+		if err := store.Save(story); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		query    Query
+		expected []string
+	}{
+		// 标题命中的权重高于正文
+		{Query{Text: "小龙"}, []string{"勇敢的小龙", "小兔子找朋友"}},
+		{Query{Text: "怕黑 小龙"}, []string{"勇敢的小龙"}},
+		{Query{Text: "龙"}, []string{"勇敢的小龙", "小兔子找朋友"}},
+		{Query{Text: "龙宝"}, []string{"勇敢的小龙"}},
+		{Query{Text: "冒险"}, []string{"勇敢的小龙"}},
+		{Query{Text: "DARK afr"}, []string{"The Brave Bear"}},
+		{Query{Text: "小龙", StoryType: "童话"}, []string{"小兔子找朋友"}},
+		{Query{Text: "小龙", CreatedTo: start.AddDate(0, 0, 1)}, []string{"勇敢的小龙"}},
+		{Query{CreatedFrom: start.AddDate(0, 0, 1), AgeGroup: "3-5岁"}, []string{"小兔子找朋友"}},
+		{Query{Text: "大象"}, nil},
+	}
+	for _, c := range cases {
+		page, err := store.List(c.query)
+		if err != nil {
+			t.Fatalf("%+v: 检索失败: %v", c.query, err)
+		}
+		var titles []string
+		for _, summary := range page.Stories {
+			titles = append(titles, summary.Title)
+		}
+		if len(titles) != len(c.expected) || page.Total != len(c.expected) {
+			t.Errorf("%+v: 结果为 %v（共 %d 个），期望 %v", c.query, titles, page.Total, c.expected)
+			continue
+		}
+		for i := range titles {
+			if titles[i] != c.expected[i] {
+				t.Errorf("%+v: 结果为 %v，期望 %v", c.query, titles, c.expected)
+				break
+			}
+		}
+	}
+
+	// 覆盖保存和删除后索引同步更新
+	dragon.Document.FinalText = "小龙不再怕黑了。"
+	if err := store.Save(dragon); err != nil {
+		t.Fatal(err)
+	}
+	if page, _ := store.List(Query{Text: "睡觉"}); page.Total != 0 {
+		t.Errorf("覆盖保存后旧内容仍可检索: %+v", page)
+	}
+	if err := store.Delete(dragon.ID); err != nil {
+		t.Fatal(err)
+	}
+	if page, _ := store.List(Query{Text: "怕黑"}); page.Total != 0 {
+		t.Errorf("删除后仍可检索: %+v", page)
+	}
+}
+
+// 完整生成流程的故事文档由计划生成标题，保存后可以按标题检索
+func TestSearchGeneratedStoryTitle(t *testing.T) {
+	store := openTestStore(t)
+	planInfo := &plan_module.PlanInfo{
+		Title:           "月亮船",
+		Premise:         "小兔子想去天上看看",
+		Characters:      []string{"小白"},
+		OutlineSections: []string{"小白做了一架梯子"},
+		Language:        "zh-Hans",
+	}
+	doc := story_document.NewStoryDocument(planInfo, []common.Draft{{Index: 0, CurrentSection: "小白做了一架梯子", Content: "小白找来木头，做了一架长长的梯子。"}})
+	story, err := NewStory(SOURCE_GENERATE_STORY, "", map[string]string{"premise": planInfo.Premise}, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(story); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := store.List(Query{Text: "月亮船"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Stories[0].ID != doc.ID || page.Stories[0].Title != "月亮船" {
+		t.Errorf("按标题检索结果错误: %+v", page)
+	}
+}
+
+func TestReindexOnUpgrade(t *testing.T) {
+	// 建立索引之前（版本 1）的数据库
+	path := filepath.Join(t.TempDir(), "stories.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	story := newTestStory(t, "勇敢的小龙", nil, "", "", time.Now())
+	document, _ := story_document.Marshal(story.Document, story_document.FORMAT_JSON)
+	for _, statement := range []string{migrations[0], "PRAGMA user_version = 1"} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO stories
+		(id, source, title, premise, story_type, age_group, language, model, characters, request, document, created_at)
+		VALUES (?, ?, ?, ?, '', '', '', '', '', 'null', ?, ?)`,
+		story.ID, story.Source, story.Title, story.Premise, string(document), story.CreatedAt.UnixMilli()); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	store, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("升级故事库失败: %v", err)
+	}
+	defer store.Close()
+	page, err := store.List(Query{Text: "小龙"})
+	if err != nil || page.Total != 1 {
+		t.Errorf("升级后应为已有故事建立索引: %+v, %v", page, err)
+	}
+}
@@ -0,0 +1,386 @@
+package library_module
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flutterdreams/internal/story_generation/story_document"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// 数据库结构的各版本，按顺序执行，已执行的版本记录在 PRAGMA user_version 中
+var migrations = []string{
+	`CREATE TABLE stories (
+		id         TEXT PRIMARY KEY,
+		source     TEXT NOT NULL,
+		title      TEXT NOT NULL,
+		premise    TEXT NOT NULL,
+		story_type TEXT NOT NULL,
+		age_group  TEXT NOT NULL,
+		language   TEXT NOT NULL,
+		model      TEXT NOT NULL,
+		characters TEXT NOT NULL,
+		request    TEXT NOT NULL,
+		document   TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE INDEX stories_created_at ON stories (created_at);
+	CREATE INDEX stories_story_type ON stories (story_type);
+	CREATE INDEX stories_age_group ON stories (age_group);`,
+	// 全文检索索引，各列保存 tokenize 分词后的内容
+	`CREATE VIRTUAL TABLE stories_fts USING fts5(
+		story_id UNINDEXED, title, text, characters, tags,
+		tokenize = 'unicode61 remove_diacritics 2'
+	);`,
+	// 账户：用户、API key（只保存摘要）、故事和音频的所有者
+	`CREATE TABLE users (
+		id         TEXT PRIMARY KEY,
+		name       TEXT NOT NULL UNIQUE,
+		admin      INTEGER NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE TABLE api_keys (
+		id           TEXT PRIMARY KEY,
+		user_id      TEXT NOT NULL REFERENCES users (id),
+		name         TEXT NOT NULL,
+		prefix       TEXT NOT NULL,
+		hash         TEXT NOT NULL UNIQUE,
+		created_at   INTEGER NOT NULL,
+		last_used_at INTEGER NOT NULL,
+		revoked      INTEGER NOT NULL
+	);
+	CREATE INDEX api_keys_user_id ON api_keys (user_id);
+	CREATE TABLE media (
+		file       TEXT PRIMARY KEY,
+		owner_id   TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	ALTER TABLE stories ADD COLUMN owner_id TEXT NOT NULL DEFAULT '';
+	CREATE INDEX stories_owner_id ON stories (owner_id, created_at);`,
+	// 用户和 API key 的用量限额，以及按天累计的用量
+	`ALTER TABLE users ADD COLUMN stories_per_day INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE users ADD COLUMN tokens_per_month INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE users ADD COLUMN audio_minutes_per_month INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE users ADD COLUMN images_per_month INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE api_keys ADD COLUMN stories_per_day INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE api_keys ADD COLUMN tokens_per_month INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE api_keys ADD COLUMN audio_minutes_per_month INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE api_keys ADD COLUMN images_per_month INTEGER NOT NULL DEFAULT 0;
+	CREATE TABLE usage (
+		user_id           TEXT NOT NULL,
+		key_id            TEXT NOT NULL,
+		day               TEXT NOT NULL,
+		stories           INTEGER NOT NULL DEFAULT 0,
+		prompt_tokens     INTEGER NOT NULL DEFAULT 0,
+		completion_tokens INTEGER NOT NULL DEFAULT 0,
+		audio_seconds     INTEGER NOT NULL DEFAULT 0,
+		images            INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (user_id, key_id, day)
+	);`,
+}
+
+// 创建全文检索索引的版本，从更早的版本升级时为已有的故事建立索引
+const searchVersion = 2
+
+// 角色名在 characters 列中以换行分隔，首尾也加上换行便于按名称匹配
+const characterSeparator = "\n"
+
+// SQLiteStore 保存在 SQLite 数据库中的故事库
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLite 打开（不存在时创建）数据库文件并升级到最新的表结构
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite 同一时间只允许一个写入者，使用单个连接避免 database is locked
+	db.SetMaxOpenConns(1)
+	store := &SQLiteStore{db: db}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化故事库 %s 失败: %v", path, err)
+	}
+	return store, nil
+}
+
+func (s *SQLiteStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	from := version
+	for ; version < len(migrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("升级到版本 %d 失败: %v", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	if from > 0 && from < searchVersion {
+		return s.reindex()
+	}
+	return nil
+}
+
+// 为全部故事重建全文检索索引
+func (s *SQLiteStore) reindex() error {
+	rows, err := s.db.Query("SELECT id, story_type, document FROM stories")
+	if err != nil {
+		return err
+	}
+	entries := make(map[string]indexEntry)
+	for rows.Next() {
+		var id, storyType, document string
+		if err := rows.Scan(&id, &storyType, &document); err != nil {
+			rows.Close()
+			return err
+		}
+		doc, err := story_document.Unmarshal([]byte(document), story_document.FORMAT_JSON)
+		if err != nil {
+			log.Printf("故事 %s 的文档无法解析，不建立索引: %v", id, err)
+			continue
+		}
+		entries[id] = newIndexEntry(storyType, doc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM stories_fts"); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for id, entry := range entries {
+		if err := insertIndex(tx, id, entry); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	log.Printf("已为 %d 个故事建立全文检索索引", len(entries))
+	return tx.Commit()
+}
+
+func insertIndex(tx *sql.Tx, id string, entry indexEntry) error {
+	_, err := tx.Exec("INSERT INTO stories_fts (story_id, title, text, characters, tags) VALUES (?, ?, ?, ?, ?)",
+		id, entry.Title, entry.Text, entry.Characters, entry.Tags)
+	return err
+}
+
+func (s *SQLiteStore) Save(story *Story) error {
+	document, err := story_document.Marshal(story.Document, story_document.FORMAT_JSON)
+	if err != nil {
+		return err
+	}
+	request := string(story.Request)
+	if request == "" {
+		request = "null"
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT OR REPLACE INTO stories
+		(id, source, owner_id, title, premise, story_type, age_group, language, model, characters, request, document, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		story.ID, story.Source, story.OwnerID, story.Title, story.Premise, story.StoryType, story.AgeGroup, story.Language, story.Model,
+		joinCharacters(story.Characters), request, string(document), story.CreatedAt.UnixMilli())
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM stories_fts WHERE story_id = ?", story.ID)
+	}
+	if err == nil {
+		err = insertIndex(tx, story.ID, newIndexEntry(story.StoryType, story.Document))
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Get(id string) (*Story, error) {
+	row := s.db.QueryRow("SELECT "+summaryColumns+", request, document FROM stories WHERE id = ?", id)
+	var story Story
+	var request, document string
+	if err := scanSummary(row, &story.Summary, &request, &document); err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	story.Request = json.RawMessage(request)
+	doc, err := story_document.Unmarshal([]byte(document), story_document.FORMAT_JSON)
+	if err != nil {
+		return nil, fmt.Errorf("故事 %s 的文档无法解析: %v", id, err)
+	}
+	story.Document = doc
+	return &story, nil
+}
+
+func (s *SQLiteStore) List(query Query) (Page, error) {
+	query = query.normalize()
+	from, order := " FROM stories", " ORDER BY stories.created_at DESC, stories.id"
+	where, args := listConditions(query)
+	if match := matchExpression(query.Text); match != "" {
+		from = " FROM stories JOIN stories_fts ON stories_fts.story_id = stories.id"
+		order = " ORDER BY " + rankExpression + ", stories.created_at DESC, stories.id"
+		if where == "" {
+			where = " WHERE stories_fts MATCH ?"
+		} else {
+			where += " AND stories_fts MATCH ?"
+		}
+		args = append(args, match)
+	}
+	page := Page{Stories: []Summary{}, Page: query.Page, PageSize: query.PageSize}
+	if err := s.db.QueryRow("SELECT COUNT(*)"+from+where, args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	rows, err := s.db.Query("SELECT "+summaryColumns+from+where+order+" LIMIT ? OFFSET ?",
+		append(args, query.PageSize, (query.Page-1)*query.PageSize)...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var summary Summary
+		if err := scanSummary(rows, &summary); err != nil {
+			return page, err
+		}
+		page.Stories = append(page.Stories, summary)
+	}
+	return page, rows.Err()
+}
+
+func (s *SQLiteStore) Delete(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec("DELETE FROM stories WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec("DELETE FROM stories_fts WHERE story_id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// 带表名的摘要列，与全文检索索引联合查询时不会与索引的列重名
+const summaryColumns = "stories.id, stories.source, stories.owner_id, stories.title, stories.premise, stories.story_type, stories.age_group, " +
+	"stories.language, stories.model, stories.characters, stories.created_at"
+
+// 列表的筛选条件
+func listConditions(query Query) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if query.OwnerID != "" {
+		conditions = append(conditions, "stories.owner_id = ?")
+		args = append(args, query.OwnerID)
+	}
+	if query.Title != "" {
+		conditions = append(conditions, `stories.title LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(query.Title)+"%")
+	}
+	if query.Character != "" {
+		conditions = append(conditions, `stories.characters LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(query.Character)+"%")
+	}
+	if query.StoryType != "" {
+		conditions = append(conditions, "stories.story_type = ?")
+		args = append(args, query.StoryType)
+	}
+	if query.AgeGroup != "" {
+		conditions = append(conditions, "stories.age_group = ?")
+		args = append(args, query.AgeGroup)
+	}
+	if !query.CreatedFrom.IsZero() {
+		conditions = append(conditions, "stories.created_at >= ?")
+		args = append(args, query.CreatedFrom.UnixMilli())
+	}
+	if !query.CreatedTo.IsZero() {
+		conditions = append(conditions, "stories.created_at < ?")
+		args = append(args, query.CreatedTo.UnixMilli())
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// 转义 LIKE 中的通配符
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+}
+
+func joinCharacters(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return characterSeparator + strings.Join(names, characterSeparator) + characterSeparator
+}
+
+func splitCharacters(text string) []string {
+	text = strings.Trim(text, characterSeparator)
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, characterSeparator)
+}
+
+// sql.Row 与 sql.Rows 共同的扫描接口
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// 扫描摘要列，extra 为摘要列之后的其他列
+func scanSummary(row scanner, summary *Summary, extra ...interface{}) error {
+	var characters string
+	var createdAt int64
+	dest := []interface{}{&summary.ID, &summary.Source, &summary.OwnerID, &summary.Title, &summary.Premise, &summary.StoryType,
+		&summary.AgeGroup, &summary.Language, &summary.Model, &characters, &createdAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	summary.Characters = splitCharacters(characters)
+	summary.CreatedAt = time.UnixMilli(createdAt)
+	return nil
+}
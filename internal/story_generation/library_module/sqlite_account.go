@@ -0,0 +1,263 @@
+package library_module
+
+import (
+	"database/sql"
+	"flutterdreams/internal/story_generation/story_document"
+	"time"
+)
+
+func (s *SQLiteStore) CreateUser(name string, admin bool, quota Quota) (*User, error) {
+	user := &User{
+		ID:        story_document.NewID(),
+		Name:      name,
+		Admin:     admin,
+		Quota:     quota,
+		CreatedAt: time.Now(),
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE name = ?", name).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrNameTaken
+	}
+	if _, err := tx.Exec(`INSERT INTO users (id, name, admin, created_at,
+		stories_per_day, tokens_per_month, audio_minutes_per_month, images_per_month) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.ID, user.Name, user.Admin, user.CreatedAt.UnixMilli(),
+		quota.StoriesPerDay, quota.TokensPerMonth, quota.AudioMinutesPerMonth, quota.ImagesPerMonth); err != nil {
+		return nil, err
+	}
+	return user, tx.Commit()
+}
+
+// 用户和 API key 表中限额列的扫描目标
+func quotaDest(quota *Quota) []interface{} {
+	return []interface{}{&quota.StoriesPerDay, &quota.TokensPerMonth, &quota.AudioMinutesPerMonth, &quota.ImagesPerMonth}
+}
+
+const userColumns = "users.id, users.name, users.admin, users.created_at, " +
+	"users.stories_per_day, users.tokens_per_month, users.audio_minutes_per_month, users.images_per_month"
+
+// 扫描用户列，extra 为用户列之后的其他列
+func scanUser(row scanner, user *User, extra ...interface{}) error {
+	var createdAt int64
+	dest := append([]interface{}{&user.ID, &user.Name, &user.Admin, &createdAt}, quotaDest(&user.Quota)...)
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	user.CreatedAt = time.UnixMilli(createdAt)
+	return nil
+}
+
+// 修改 table 中 id 对应行的限额，不存在时返回 ErrNotFound
+func (s *SQLiteStore) setQuota(table string, id string, quota Quota) error {
+	result, err := s.db.Exec("UPDATE "+table+" SET stories_per_day = ?, tokens_per_month = ?, audio_minutes_per_month = ?, images_per_month = ? WHERE id = ?",
+		quota.StoriesPerDay, quota.TokensPerMonth, quota.AudioMinutesPerMonth, quota.ImagesPerMonth, id)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) SetQuota(userID string, quota Quota) error {
+	return s.setQuota("users", userID, quota)
+}
+
+func (s *SQLiteStore) SetKeyQuota(id string, quota Quota) error {
+	return s.setQuota("api_keys", id, quota)
+}
+
+func (s *SQLiteStore) GetUser(id string) (*User, error) {
+	var user User
+	err := scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id), &user)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *SQLiteStore) ListUsers() ([]User, error) {
+	rows, err := s.db.Query("SELECT " + userColumns + " FROM users ORDER BY users.created_at, users.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLiteStore) HasAdmin() (bool, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM users WHERE admin").Scan(&count)
+	return count > 0, err
+}
+
+func (s *SQLiteStore) CreateKey(userID string, name string) (*APIKey, string, error) {
+	if _, err := s.GetUser(userID); err != nil {
+		return nil, "", err
+	}
+	key, err := newKey()
+	if err != nil {
+		return nil, "", err
+	}
+	apiKey := &APIKey{
+		ID:        story_document.NewID(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:len(API_KEY_PREFIX)+6],
+		CreatedAt: time.Now(),
+	}
+	if _, err := s.db.Exec("INSERT INTO api_keys (id, user_id, name, prefix, hash, created_at, last_used_at, revoked) VALUES (?, ?, ?, ?, ?, ?, 0, 0)",
+		apiKey.ID, apiKey.UserID, apiKey.Name, apiKey.Prefix, HashKey(key), apiKey.CreatedAt.UnixMilli()); err != nil {
+		return nil, "", err
+	}
+	return apiKey, key, nil
+}
+
+const keyColumns = "api_keys.id, api_keys.user_id, api_keys.name, api_keys.prefix, api_keys.created_at, api_keys.last_used_at, api_keys.revoked, " +
+	"api_keys.stories_per_day, api_keys.tokens_per_month, api_keys.audio_minutes_per_month, api_keys.images_per_month"
+
+func scanKey(row scanner, key *APIKey) error {
+	var createdAt, lastUsedAt int64
+	dest := append([]interface{}{&key.ID, &key.UserID, &key.Name, &key.Prefix, &createdAt, &lastUsedAt, &key.Revoked}, quotaDest(&key.Quota)...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	key.CreatedAt = time.UnixMilli(createdAt)
+	if lastUsedAt > 0 {
+		key.LastUsedAt = time.UnixMilli(lastUsedAt)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetKey(id string) (*APIKey, error) {
+	var key APIKey
+	err := scanKey(s.db.QueryRow("SELECT "+keyColumns+" FROM api_keys WHERE id = ?", id), &key)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *SQLiteStore) ListKeys(userID string) ([]APIKey, error) {
+	rows, err := s.db.Query("SELECT "+keyColumns+" FROM api_keys WHERE user_id = ? ORDER BY created_at, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		if err := scanKey(rows, &key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQLiteStore) RevokeKey(id string) error {
+	result, err := s.db.Exec("UPDATE api_keys SET revoked = 1 WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Authenticate 按摘要查找 key，并记录最近一次使用时间
+func (s *SQLiteStore) Authenticate(key string) (*User, *APIKey, error) {
+	var user User
+	var apiKey APIKey
+	var createdAt, lastUsedAt int64
+	row := s.db.QueryRow(`SELECT `+userColumns+`, api_keys.id, api_keys.name, api_keys.prefix, api_keys.created_at, api_keys.last_used_at,
+		api_keys.stories_per_day, api_keys.tokens_per_month, api_keys.audio_minutes_per_month, api_keys.images_per_month
+		FROM api_keys JOIN users ON users.id = api_keys.user_id
+		WHERE api_keys.hash = ? AND NOT api_keys.revoked`, HashKey(key))
+	err := scanUser(row, &user, append([]interface{}{&apiKey.ID, &apiKey.Name, &apiKey.Prefix, &createdAt, &lastUsedAt}, quotaDest(&apiKey.Quota)...)...)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrUnauthorized
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	apiKey.UserID = user.ID
+	apiKey.CreatedAt = time.UnixMilli(createdAt)
+	apiKey.LastUsedAt = now
+	if _, err := s.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", now.UnixMilli(), apiKey.ID); err != nil {
+		return nil, nil, err
+	}
+	return &user, &apiKey, nil
+}
+
+func (s *SQLiteStore) RecordUsage(userID string, keyID string, at time.Time, usage Usage) error {
+	if usage.Empty() {
+		return nil
+	}
+	_, err := s.db.Exec(`INSERT INTO usage (user_id, key_id, day, stories, prompt_tokens, completion_tokens, audio_seconds, images)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, key_id, day) DO UPDATE SET
+			stories = stories + excluded.stories,
+			prompt_tokens = prompt_tokens + excluded.prompt_tokens,
+			completion_tokens = completion_tokens + excluded.completion_tokens,
+			audio_seconds = audio_seconds + excluded.audio_seconds,
+			images = images + excluded.images`,
+		userID, keyID, at.Format(dayFormat), usage.Stories, usage.PromptTokens, usage.CompletionTokens, usage.AudioSeconds, usage.Images)
+	return err
+}
+
+func (s *SQLiteStore) UsageSince(userID string, keyID string, since time.Time) (Usage, error) {
+	query := `SELECT COALESCE(SUM(stories), 0), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0),
+		COALESCE(SUM(audio_seconds), 0), COALESCE(SUM(images), 0) FROM usage WHERE user_id = ? AND day >= ?`
+	args := []interface{}{userID, since.Format(dayFormat)}
+	if keyID != "" {
+		query += " AND key_id = ?"
+		args = append(args, keyID)
+	}
+	var usage Usage
+	err := s.db.QueryRow(query, args...).Scan(&usage.Stories, &usage.PromptTokens, &usage.CompletionTokens, &usage.AudioSeconds, &usage.Images)
+	return usage, err
+}
+
+func (s *SQLiteStore) SetMediaOwner(file string, ownerID string) error {
+	_, err := s.db.Exec("INSERT OR REPLACE INTO media (file, owner_id, created_at) VALUES (?, ?, ?)",
+		file, ownerID, time.Now().UnixMilli())
+	return err
+}
+
+func (s *SQLiteStore) MediaOwner(file string) (string, error) {
+	var owner string
+	err := s.db.QueryRow("SELECT owner_id FROM media WHERE file = ?", file).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return owner, err
+}
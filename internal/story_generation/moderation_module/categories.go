@@ -0,0 +1,137 @@
+package moderation_module
+
+import (
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/common"
+)
+
+// DEFAULT_CATEGORIES 各语言内置的审核类别，可通过配置文件 moderation.categories 整体替换
+var DEFAULT_CATEGORIES = map[string][]config.ModerationCategory{
+	common.LANG_ZH_HANS: {
+		{
+			Name:     "色情",
+			Action:   ACTION_BLOCK,
+			Keywords: []string{"色情", "裸体", "性爱", "成人内容"},
+		},
+		{
+			Name:     "血腥暴力",
+			Action:   ACTION_BLOCK,
+			Keywords: []string{"血腥", "肢解", "虐杀", "屠杀", "砍头"},
+		},
+		{
+			Name:     "歧视仇恨",
+			Action:   ACTION_BLOCK,
+			Keywords: []string{"种族歧视", "仇恨", "低等民族"},
+		},
+		{
+			Name:     "自我伤害",
+			Action:   ACTION_BLOCK,
+			Keywords: []string{"自杀", "自残", "割腕"},
+		},
+		{
+			Name:     "暴力",
+			Action:   ACTION_REWRITE,
+			Keywords: []string{"杀死", "打死", "枪杀", "殴打", "战争"},
+		},
+		{
+			Name:     "恐怖",
+			Action:   ACTION_REWRITE,
+			Keywords: []string{"恐怖", "鬼魂", "僵尸", "尸体", "噩梦"},
+		},
+		{
+			Name:     "不文明用语",
+			Action:   ACTION_REWRITE,
+			Keywords: []string{"笨蛋", "蠢货", "滚开", "闭嘴"},
+		},
+		{
+			Name:     "危险行为",
+			Action:   ACTION_FLAG,
+			Keywords: []string{"玩火", "独自出门", "跟陌生人走", "爬窗户", "吃药"},
+		},
+	},
+	common.LANG_ZH_HANT: {
+		{
+			Name:     "色情",
+			Action:   ACTION_BLOCK,
+			Keywords: []string{"色情", "裸體", "性愛", "成人內容"},
+		},
+		{
+			Name:     "血腥暴力",
+			Action:   ACTION_BLOCK,
+			Keywords: []string{"血腥", "肢解", "虐殺", "屠殺", "砍頭"},
+		},
+		{
+			Name:     "歧視仇恨",
+			Action:   ACTION_BLOCK,
+			Keywords: []string{"種族歧視", "仇恨", "低等民族"},
+		},
+		{
+			Name:     "自我傷害",
+			Action:   ACTION_BLOCK,
+			Keywords: []string{"自殺", "自殘", "割腕"},
+		},
+		{
+			Name:     "暴力",
+			Action:   ACTION_REWRITE,
+			Keywords: []string{"殺死", "打死", "槍殺", "毆打", "戰爭"},
+		},
+		{
+			Name:     "恐怖",
+			Action:   ACTION_REWRITE,
+			Keywords: []string{"恐怖", "鬼魂", "殭屍", "屍體", "噩夢"},
+		},
+		{
+			Name:     "不文明用語",
+			Action:   ACTION_REWRITE,
+			Keywords: []string{"笨蛋", "蠢貨", "滾開", "閉嘴"},
+		},
+		{
+			Name:     "危險行為",
+			Action:   ACTION_FLAG,
+			Keywords: []string{"玩火", "獨自出門", "跟陌生人走", "爬窗戶", "吃藥"},
+		},
+	},
+	// 英文关键词按完整的词匹配，需要列出常见的词形变化
+	common.LANG_EN: {
+		{
+			Name:     "sexual content",
+			Action:   ACTION_BLOCK,
+			Keywords: []string{"porn", "pornography", "nude", "naked", "sex", "adult content"},
+		},
+		{
+			Name:     "gore",
+			Action:   ACTION_BLOCK,
+			Keywords: []string{"gore", "gory", "dismember", "dismembered", "massacre", "behead", "beheaded"},
+		},
+		{
+			Name:     "hate",
+			Action:   ACTION_BLOCK,
+			Keywords: []string{"racist", "racism", "hate speech", "inferior race"},
+		},
+		{
+			Name:     "self-harm",
+			Action:   ACTION_BLOCK,
+			Keywords: []string{"suicide", "self-harm", "slit her wrists", "slit his wrists"},
+		},
+		{
+			Name:     "violence",
+			Action:   ACTION_REWRITE,
+			Keywords: []string{"kill", "killed", "killing", "shot dead", "beat up", "beaten up", "war"},
+		},
+		{
+			Name:     "horror",
+			Action:   ACTION_REWRITE,
+			Keywords: []string{"horror", "ghost", "ghosts", "zombie", "zombies", "corpse", "dead body", "nightmare"},
+		},
+		{
+			Name:     "rude language",
+			Action:   ACTION_REWRITE,
+			Keywords: []string{"stupid", "idiot", "shut up", "get lost"},
+		},
+		{
+			Name:     "dangerous behaviour",
+			Action:   ACTION_FLAG,
+			Keywords: []string{"play with fire", "played with fire", "playing with fire", "went out alone", "followed a stranger", "climbed out the window", "took pills"},
+		},
+	},
+}
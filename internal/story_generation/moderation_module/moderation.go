@@ -0,0 +1,249 @@
+// 内容审核模块：对用户输入的故事前提、生成的故事文本和图片提示词进行儿童适宜性审核
+//
+// 审核结合两部分：可配置的关键词类别列表，以及大模型分类器。
+// 每个类别对应一个处置动作（拦截、改写或标记），多个结果取最严格的动作。
+package moderation_module
+
+import (
+	"errors"
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 审核阶段
+const (
+	STAGE_INPUT        = "input"        // 用户输入的故事前提
+	STAGE_OUTPUT       = "output"       // 生成的故事文本
+	STAGE_IMAGE_PROMPT = "image_prompt" // 生成的图片提示词
+)
+
+// 处置动作，按严格程度从低到高排列
+const (
+	ACTION_ALLOW   = "allow"
+	ACTION_FLAG    = "flag"
+	ACTION_REWRITE = "rewrite"
+	ACTION_BLOCK   = "block"
+)
+
+// 分类器类型
+const (
+	CLASSIFIER_LLM  = "llm"
+	CLASSIFIER_NONE = "none"
+)
+
+var actionSeverity = map[string]int{
+	ACTION_ALLOW:   0,
+	ACTION_FLAG:    1,
+	ACTION_REWRITE: 2,
+	ACTION_BLOCK:   3,
+}
+
+// Decision 一次审核的结果
+type Decision struct {
+	Stage      string   `json:"stage" yaml:"stage"`
+	Action     string   `json:"action" yaml:"action"`
+	Categories []string `json:"categories,omitempty" yaml:"categories,omitempty"`
+	Reasons    []string `json:"reasons,omitempty" yaml:"reasons,omitempty"`
+	// 内容是否已被改写为适合儿童的版本
+	Rewritten bool `json:"rewritten,omitempty" yaml:"rewritten,omitempty"`
+}
+
+// BlockedError 内容被拦截时返回的错误
+type BlockedError struct {
+	Decision Decision
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("内容未通过审核（%s）: %s", e.Decision.Stage, strings.Join(e.Decision.Reasons, "；"))
+}
+
+func (e *BlockedError) Code() string {
+	return common.ERROR_BLOCKED
+}
+
+// IsBlocked 判断错误是否由内容拦截导致
+func IsBlocked(err error) (*BlockedError, bool) {
+	var blocked *BlockedError
+	if errors.As(err, &blocked) {
+		return blocked, true
+	}
+	return nil, false
+}
+
+// Moderate 按故事语言审核文本，分类和改写的模型调用计入 meter（可以为 nil）
+// 返回：
+// - 审核后的文本，动作为 rewrite 时为改写后的文本，否则为原文
+// - 审核结果
+// - 动作为 block 时返回 *BlockedError
+func Moderate(stage string, text string, language string, meter *common.Meter) (string, Decision, error) {
+	decision := Decision{Stage: stage, Action: ACTION_ALLOW}
+	if strings.TrimSpace(text) == "" {
+		return text, decision, nil
+	}
+
+	// 关键词检查，非中文按完整的词匹配
+	lang := common.GetLanguage(language)
+	for _, category := range categories(language) {
+		for _, keyword := range category.Keywords {
+			if keyword != "" && lang.ContainsWord(text, keyword) {
+				decision.merge(category.Name, category.Action, fmt.Sprintf(labelsFor(language).keywordReason, category.Name, keyword))
+				break
+			}
+		}
+	}
+
+	// 大模型分类
+	if classifierEnabled() {
+		category, reason, err := classify(stage, text, language, meter)
+		if err != nil {
+			log.Printf("内容审核分类器调用失败: %v", err)
+			decision.merge("", ACTION_FLAG, "分类器不可用，仅完成关键词检查")
+		} else if category != "" {
+			decision.merge(category, actionForCategory(category, language), reason)
+		}
+	}
+
+	switch decision.Action {
+	case ACTION_BLOCK:
+		log.Printf("内容审核拦截（%s）: %v", stage, decision.Reasons)
+		return text, decision, &BlockedError{Decision: decision}
+	case ACTION_REWRITE:
+		rewritten, err := rewriteForChildren(stage, text, language, decision.Reasons, meter)
+		if err != nil {
+			// 改写失败时无法保证内容适宜，按拦截处理
+			decision.Action = ACTION_BLOCK
+			decision.Reasons = append(decision.Reasons, fmt.Sprintf("改写失败: %v", err))
+			return text, decision, &BlockedError{Decision: decision}
+		}
+		decision.Rewritten = true
+		log.Printf("内容审核改写（%s）: %v", stage, decision.Reasons)
+		return rewritten, decision, nil
+	case ACTION_FLAG:
+		log.Printf("内容审核标记（%s）: %v", stage, decision.Reasons)
+	}
+	return text, decision, nil
+}
+
+// 合并一条审核结果，动作取更严格的一个
+func (d *Decision) merge(category string, action string, reason string) {
+	if category != "" && !containsString(d.Categories, category) {
+		d.Categories = append(d.Categories, category)
+	}
+	if reason != "" {
+		d.Reasons = append(d.Reasons, reason)
+	}
+	if actionSeverity[action] > actionSeverity[d.Action] {
+		d.Action = action
+	}
+}
+
+// 构建分类提示词并解析结果，内容适宜时返回空类别
+func classify(stage string, text string, language string, meter *common.Meter) (string, string, error) {
+	var names []string
+	for _, category := range categories(language) {
+		names = append(names, category.Name)
+	}
+
+	prompt, err := prompt_module.Render("moderation/classify", language, nil, struct {
+		Subject    string
+		Text       string
+		Categories []string
+	}{labelsFor(language).stage(stage), text, names})
+	if err != nil {
+		return "", "", err
+	}
+	response, err := meter.ChatWithModel(prompt)
+	if err != nil {
+		return "", "", err
+	}
+	category, reason := parseClassification(response, language)
+	return category, reason, nil
+}
+
+// 解析分类结果，英文的判定词不区分大小写
+func parseClassification(response string, language string) (string, string) {
+	labels := labelsFor(language)
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, "*", ""))
+		if !strings.HasPrefix(strings.ToLower(line), strings.ToLower(labels.unsuitable)) {
+			continue
+		}
+		parts := strings.SplitN(line, "｜", 3)
+		if len(parts) < 3 {
+			parts = strings.SplitN(line, "|", 3)
+		}
+		if len(parts) < 2 {
+			return labels.other, line
+		}
+		category := strings.TrimSpace(parts[1])
+		reason := ""
+		if len(parts) == 3 {
+			reason = strings.TrimSpace(parts[2])
+		}
+		return category, fmt.Sprintf(labels.classifierReason, category, reason)
+	}
+	return "", ""
+}
+
+// 将内容改写为适合儿童的版本
+func rewriteForChildren(stage string, text string, language string, reasons []string, meter *common.Meter) (string, error) {
+	prompt, err := prompt_module.Render("moderation/rewrite", language, nil, struct {
+		Subject string
+		Reasons []string
+		Text    string
+	}{labelsFor(language).stage(stage), reasons, text})
+	if err != nil {
+		return "", err
+	}
+	response, err := meter.ChatWithModel(prompt)
+	if err != nil {
+		return "", err
+	}
+	response = strings.TrimSpace(response)
+	if response == "" {
+		return "", fmt.Errorf("改写结果为空")
+	}
+	return response, nil
+}
+
+// 某个语言当前生效的类别：配置了类别时使用配置中适用于该语言的类别，否则使用该语言的内置类别
+func categories(language string) []config.ModerationCategory {
+	code := common.GetLanguage(language).Code
+	if configured := config.GetConfig().Moderation.Categories; len(configured) > 0 {
+		var result []config.ModerationCategory
+		for _, category := range configured {
+			if category.Language == "" || common.GetLanguage(category.Language).Code == code {
+				result = append(result, category)
+			}
+		}
+		return result
+	}
+	return DEFAULT_CATEGORIES[code]
+}
+
+func classifierEnabled() bool {
+	return config.GetConfig().Moderation.Classifier != CLASSIFIER_NONE
+}
+
+// 分类器给出的类别对应的动作，未知类别按标记处理
+func actionForCategory(name string, language string) string {
+	for _, category := range categories(language) {
+		if strings.EqualFold(category.Name, name) {
+			return category.Action
+		}
+	}
+	return ACTION_FLAG
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package moderation_module
+
+import (
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/common"
+	"testing"
+)
+
+// mockConfig 关闭大模型分类器，只测试关键词审核
+func mockConfig() {
+	config.GlobalConfig = config.Config{
+		Moderation: config.ModerationConfig{
+			Classifier: CLASSIFIER_NONE,
+		},
+	}
+}
+
+func TestModerateKeywords(t *testing.T) {
+	mockConfig()
+
+	testCases := []struct {
+		name       string
+		text       string
+		wantAction string
+	}{
+		{name: "适宜内容", text: "小兔子和小熊一起分享胡萝卜", wantAction: ACTION_ALLOW},
+		{name: "危险行为", text: "小猴子偷偷玩火", wantAction: ACTION_FLAG},
+		{name: "血腥暴力", text: "一个血腥的故事", wantAction: ACTION_BLOCK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			text, decision, err := Moderate(STAGE_INPUT, tc.text, common.LANG_ZH_HANS, nil)
+			if decision.Action != tc.wantAction {
+				t.Errorf("审核动作错误: got %s, want %s, reasons: %v", decision.Action, tc.wantAction, decision.Reasons)
+			}
+			if tc.wantAction == ACTION_BLOCK {
+				if _, ok := IsBlocked(err); !ok {
+					t.Errorf("拦截时应返回 BlockedError: %v", err)
+				}
+				return
+			}
+			if err != nil || text != tc.text {
+				t.Errorf("未拦截时应返回原文: %q, %v", text, err)
+			}
+		})
+	}
+}
+
+func TestModerateConfiguredCategories(t *testing.T) {
+	config.GlobalConfig = config.Config{
+		Moderation: config.ModerationConfig{
+			Classifier: CLASSIFIER_NONE,
+			Categories: []config.ModerationCategory{
+				{Name: "零食", Action: ACTION_FLAG, Keywords: []string{"糖果"}},
+			},
+		},
+	}
+
+	// 配置类别后内置类别不再生效
+	_, decision, err := Moderate(STAGE_OUTPUT, "一个血腥的故事，里面有很多糖果", common.LANG_ZH_HANS, nil)
+	if err != nil || decision.Action != ACTION_FLAG || decision.Categories[0] != "零食" {
+		t.Errorf("应只使用配置的类别: %+v, %v", decision, err)
+	}
+}
+
+func TestParseClassification(t *testing.T) {
+	if category, _ := parseClassification("适宜", common.LANG_ZH_HANS); category != "" {
+		t.Errorf("适宜内容不应返回类别: %s", category)
+	}
+	category, reason := parseClassification("不适宜｜恐怖｜描写了吓人的鬼魂", common.LANG_ZH_HANS)
+	if category != "恐怖" || reason == "" {
+		t.Errorf("分类结果解析错误: %s, %s", category, reason)
+	}
+	category, _ = parseClassification("unsuitable | horror | a scary ghost", common.LANG_EN)
+	if category != "horror" {
+		t.Errorf("英文分类结果解析错误: %s", category)
+	}
+	if category, _ := parseClassification("Suitable", common.LANG_EN); category != "" {
+		t.Errorf("英文适宜内容不应返回类别: %s", category)
+	}
+}
+
+// 英文故事使用英文关键词，按完整的词匹配且不区分大小写
+func TestModerateEnglishKeywords(t *testing.T) {
+	mockConfig()
+
+	testCases := []struct {
+		name       string
+		text       string
+		wantAction string
+	}{
+		{name: "适宜内容", text: "The skilled rabbit shared carrots with the bear.", wantAction: ACTION_ALLOW},
+		{name: "危险行为", text: "The monkey was Playing with fire.", wantAction: ACTION_FLAG},
+		{name: "血腥暴力", text: "A GORY tale.", wantAction: ACTION_BLOCK},
+		{name: "中文关键词不适用", text: "一个血腥的故事", wantAction: ACTION_ALLOW},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, decision, _ := Moderate(STAGE_INPUT, tc.text, common.LANG_EN, nil)
+			if decision.Action != tc.wantAction {
+				t.Errorf("审核动作错误: got %s, want %s, reasons: %v", decision.Action, tc.wantAction, decision.Reasons)
+			}
+		})
+	}
+}
+
+// 配置的类别可以限定适用的语言
+func TestModerateConfiguredLanguage(t *testing.T) {
+	config.GlobalConfig = config.Config{
+		Moderation: config.ModerationConfig{
+			Classifier: CLASSIFIER_NONE,
+			Categories: []config.ModerationCategory{
+				{Name: "零食", Action: ACTION_FLAG, Keywords: []string{"糖果"}, Language: common.LANG_ZH_HANS},
+				{Name: "candy", Action: ACTION_FLAG, Keywords: []string{"candy"}, Language: common.LANG_EN},
+			},
+		},
+	}
+
+	if _, decision, _ := Moderate(STAGE_OUTPUT, "很多糖果 candy", common.LANG_EN, nil); len(decision.Categories) != 1 || decision.Categories[0] != "candy" {
+		t.Errorf("应只使用英文类别: %+v", decision)
+	}
+}
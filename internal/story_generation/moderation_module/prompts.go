@@ -0,0 +1,55 @@
+package moderation_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+)
+
+// 各语言的审核用语；分类和改写的提示词见 prompt_module 的 moderation/ 模板
+type labels struct {
+	// 各审核阶段的审核对象
+	stages map[string]string
+	// 分类器判定内容不适宜时输出的开头
+	unsuitable string
+	// 分类器没有给出类别时使用的类别
+	other string
+	// 审核原因，参数依次为类别和关键词或分类器给出的原因
+	keywordReason    string
+	classifierReason string
+}
+
+var languageLabels = map[string]labels{
+	common.LANG_ZH_HANS: {
+		stages:           map[string]string{STAGE_INPUT: "故事前提", STAGE_OUTPUT: "故事内容", STAGE_IMAGE_PROMPT: "图片提示词"},
+		unsuitable:       "不适宜",
+		other:            "其他",
+		keywordReason:    "包含%s类关键词：%s",
+		classifierReason: "分类器判定为%s：%s",
+	},
+	common.LANG_ZH_HANT: {
+		stages:           map[string]string{STAGE_INPUT: "故事前提", STAGE_OUTPUT: "故事內容", STAGE_IMAGE_PROMPT: "圖片提示詞"},
+		unsuitable:       "不適宜",
+		other:            "其他",
+		keywordReason:    "包含%s類關鍵詞：%s",
+		classifierReason: "分類器判定為%s：%s",
+	},
+	common.LANG_EN: {
+		stages:           map[string]string{STAGE_INPUT: "story premise", STAGE_OUTPUT: "story text", STAGE_IMAGE_PROMPT: "image prompt"},
+		unsuitable:       "Unsuitable",
+		other:            "other",
+		keywordReason:    "contains %s keyword: %s",
+		classifierReason: "classifier flagged %s: %s",
+	},
+}
+
+// 某个语言的审核用语，未知语言使用默认语言
+func labelsFor(language string) labels {
+	return languageLabels[common.GetLanguage(language).Code]
+}
+
+// 审核对象的名称，未知阶段视为故事内容
+func (l labels) stage(stage string) string {
+	if label, ok := l.stages[stage]; ok {
+		return label
+	}
+	return l.stages[STAGE_OUTPUT]
+}
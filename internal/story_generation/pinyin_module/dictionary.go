@@ -0,0 +1,122 @@
+package pinyin_module
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+//go:embed data/characters.txt data/polyphones.txt data/words.txt
+var dataFiles embed.FS
+
+// 拼音词典：单字读音及词语读音
+type dictionary struct {
+	// 每个汉字的读音，多音字的第一个读音为默认读音
+	readings map[rune][]string
+	// 词语的逐字读音
+	words map[string][]string
+	// 词语表中最长词语的字数
+	maxWordLength int
+}
+
+// 内置词典，数据文件有误时直接 panic
+var defaultDictionary = mustLoadDictionary()
+
+func mustLoadDictionary() *dictionary {
+	dict, err := loadDictionary()
+	if err != nil {
+		panic(err)
+	}
+	return dict
+}
+
+// 从内置数据文件加载词典
+func loadDictionary() (*dictionary, error) {
+	dict := &dictionary{
+		readings: make(map[rune][]string),
+		words:    make(map[string][]string),
+	}
+
+	err := eachLine("data/characters.txt", func(fields []string) error {
+		if len(fields) != 2 {
+			return fmt.Errorf("单音字表格式错误: %s", strings.Join(fields, " "))
+		}
+		for _, r := range fields[1] {
+			if _, ok := dict.readings[r]; ok {
+				return fmt.Errorf("汉字 %c 重复出现", r)
+			}
+			dict.readings[r] = []string{fields[0]}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = eachLine("data/polyphones.txt", func(fields []string) error {
+		if len(fields) < 3 || utf8.RuneCountInString(fields[0]) != 1 {
+			return fmt.Errorf("多音字表格式错误: %s", strings.Join(fields, " "))
+		}
+		r, _ := utf8.DecodeRuneInString(fields[0])
+		if _, ok := dict.readings[r]; ok {
+			return fmt.Errorf("汉字 %c 重复出现", r)
+		}
+		dict.readings[r] = fields[1:]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = eachLine("data/words.txt", func(fields []string) error {
+		length := utf8.RuneCountInString(fields[0])
+		if length < 2 || len(fields) != length+1 {
+			return fmt.Errorf("词语表格式错误: %s", strings.Join(fields, " "))
+		}
+		dict.words[fields[0]] = fields[1:]
+		if length > dict.maxWordLength {
+			dict.maxWordLength = length
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dict, nil
+}
+
+// 逐行读取数据文件，跳过空行和 # 开头的注释
+func eachLine(name string, handle func(fields []string) error) error {
+	file, err := dataFiles.Open(name)
+	if err != nil {
+		return fmt.Errorf("打开拼音数据 %s 失败: %v", name, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := handle(strings.Fields(line)); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// 从 start 开始匹配最长的词语，返回词语的字数及读音，没有匹配时返回 0
+func (d *dictionary) matchWord(runes []rune, start int) (int, []string) {
+	for length := d.maxWordLength; length >= 2; length-- {
+		if start+length > len(runes) {
+			continue
+		}
+		if readings, ok := d.words[string(runes[start:start+length])]; ok {
+			return length, readings
+		}
+	}
+	return 0, nil
+}
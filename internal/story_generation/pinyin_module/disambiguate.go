@@ -0,0 +1,97 @@
+package pinyin_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	MAX_ATTEMPTS = 2
+	// 每次请求模型判断的字数上限
+	MAX_QUERIES_PER_PROMPT = 30
+	// 提供给模型的上下文半径（字数）
+	CONTEXT_RADIUS = 8
+)
+
+var (
+	answerLine      = regexp.MustCompile(`^(\d+)\s*[｜|]\s*(\S+)$`)
+	syllablePattern = regexp.MustCompile(`^[a-zāáǎàēéěèīíǐìōóǒòūúǔùüǖǘǚǜńň]+$`)
+)
+
+// 分批请求模型判断读音，模型调用失败时只记录日志，未确定的字使用默认读音
+func disambiguateWithModel(queries []query, meter *common.Meter) map[int]string {
+	resolved := make(map[int]string)
+	for start := 0; start < len(queries); start += MAX_QUERIES_PER_PROMPT {
+		end := start + MAX_QUERIES_PER_PROMPT
+		if end > len(queries) {
+			end = len(queries)
+		}
+		batch := queries[start:end]
+		prompt := constructDisambiguatePrompt(batch)
+
+		for attempts := 0; attempts < MAX_ATTEMPTS; attempts++ {
+			response, err := meter.ChatWithModel(prompt)
+			if err != nil {
+				log.Printf("调用模型判断多音字读音失败: %v", err)
+				break
+			}
+			answers := parseAnswers(response)
+			missing := 0
+			for i, q := range batch {
+				reading, ok := answers[i+1]
+				if ok && validReading(reading, q.Candidates) {
+					resolved[q.Index] = reading
+				} else if _, done := resolved[q.Index]; !done {
+					missing++
+				}
+			}
+			if missing == 0 {
+				break
+			}
+			log.Printf("有 %d 个字的读音未能确定，重新判断", missing)
+		}
+	}
+	return resolved
+}
+
+// 构建判断读音的提示词
+func constructDisambiguatePrompt(queries []query) string {
+	var builder strings.Builder
+
+	builder.WriteString("请根据上下文判断以下句子中用【】标出的汉字的读音，用带声调符号的汉语拼音表示（如 zhǎng）。\n\n")
+	for i, q := range queries {
+		candidates := "无，请直接标注"
+		if len(q.Candidates) > 0 {
+			candidates = strings.Join(q.Candidates, "/")
+		}
+		builder.WriteString(fmt.Sprintf("%d｜%s｜候选读音：%s\n", i+1, q.Context, candidates))
+	}
+
+	builder.WriteString("\n要求：\n")
+	builder.WriteString("1. 每个编号输出一行，格式为 编号｜读音\n")
+	builder.WriteString("2. 有候选读音时只能从候选读音中选择\n")
+	builder.WriteString("3. 只输出读音，不要输出句子或解释\n")
+
+	return builder.String()
+}
+
+// 解析 "编号｜读音" 格式的回答
+func parseAnswers(response string) map[int]string {
+	answers := make(map[int]string)
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, "*", ""))
+		match := answerLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[1])
+		if _, ok := answers[index]; !ok {
+			answers[index] = strings.ToLower(match[2])
+		}
+	}
+	return answers
+}
@@ -0,0 +1,197 @@
+// 拼音模块：为中文故事逐字标注带声调的拼音，供低龄读者和 HTML 导出的注音使用
+//
+// 读音依次由词语表、单音字表确定；多音字和词典中没有的字交给模型根据上下文判断，
+// 模型不可用或给出的读音不在候选之列时使用默认读音。
+package pinyin_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"strings"
+	"unicode"
+)
+
+// 声调符号对应的声调，未出现声调符号的音节为轻声
+var toneMarks = map[rune]int{
+	'ā': 1, 'á': 2, 'ǎ': 3, 'à': 4,
+	'ē': 1, 'é': 2, 'ě': 3, 'è': 4,
+	'ī': 1, 'í': 2, 'ǐ': 3, 'ì': 4,
+	'ō': 1, 'ó': 2, 'ǒ': 3, 'ò': 4,
+	'ū': 1, 'ú': 2, 'ǔ': 3, 'ù': 4,
+	'ǖ': 1, 'ǘ': 2, 'ǚ': 3, 'ǜ': 4,
+	'ń': 2, 'ň': 3,
+}
+
+// "一" 前后出现这些字时按数字读 yī
+const numerals = "第零〇一二三四五六七八九十百千万亿两"
+
+// Ruby 一个字符及其拼音，非汉字的拼音为空
+type Ruby struct {
+	Text   string `json:"text" yaml:"text"`
+	Pinyin string `json:"pinyin,omitempty" yaml:"pinyin,omitempty"`
+}
+
+// Annotation 故事文本的逐字拼音标注
+type Annotation struct {
+	Characters []Ruby `json:"characters" yaml:"characters"`
+	// 由模型根据上下文确定读音的字数
+	Disambiguated int `json:"disambiguated,omitempty" yaml:"disambiguated,omitempty"`
+}
+
+// 需要模型判断读音的字
+type query struct {
+	// 在文本中的位置（按字符计）
+	Index int
+	// 包含该字的上下文，该字用【】标出
+	Context string
+	// 候选读音，词典中没有的字为空
+	Candidates []string
+}
+
+// Annotate 为文本逐字标注拼音，判断读音的模型调用计入 meter（可以为 nil）
+func Annotate(text string, meter *common.Meter) *Annotation {
+	return annotate(text, func(queries []query) map[int]string {
+		return disambiguateWithModel(queries, meter)
+	})
+}
+
+// 标注拼音，disambiguate 根据上下文确定多音字及生僻字的读音，返回位置到读音的映射
+func annotate(text string, disambiguate func([]query) map[int]string) *Annotation {
+	runes := []rune(text)
+	annotation := &Annotation{Characters: make([]Ruby, len(runes))}
+	// 读音已由词语表确定的字，不再做变调处理
+	fromWord := make([]bool, len(runes))
+	var queries []query
+
+	for i := 0; i < len(runes); {
+		if !unicode.Is(unicode.Han, runes[i]) {
+			annotation.Characters[i] = Ruby{Text: string(runes[i])}
+			i++
+			continue
+		}
+		if length, readings := defaultDictionary.matchWord(runes, i); length > 0 {
+			for j := 0; j < length; j++ {
+				annotation.Characters[i+j] = Ruby{Text: string(runes[i+j]), Pinyin: readings[j]}
+				fromWord[i+j] = true
+			}
+			i += length
+			continue
+		}
+
+		readings := defaultDictionary.readings[runes[i]]
+		annotation.Characters[i] = Ruby{Text: string(runes[i])}
+		if len(readings) > 0 {
+			annotation.Characters[i].Pinyin = readings[0]
+		}
+		if len(readings) != 1 {
+			queries = append(queries, query{Index: i, Context: contextOf(runes, i), Candidates: readings})
+		}
+		i++
+	}
+
+	if len(queries) > 0 {
+		resolved := disambiguate(queries)
+		for _, q := range queries {
+			if reading, ok := resolved[q.Index]; ok && validReading(reading, q.Candidates) {
+				annotation.Characters[q.Index].Pinyin = reading
+				annotation.Disambiguated++
+			}
+		}
+	}
+
+	applyToneSandhi(annotation.Characters, fromWord)
+	return annotation
+}
+
+// 取某个字前后各 CONTEXT_RADIUS 个字作为上下文，不跨越换行
+func contextOf(runes []rune, index int) string {
+	start := index
+	for start > 0 && index-start < CONTEXT_RADIUS && runes[start-1] != '\n' {
+		start--
+	}
+	end := index + 1
+	for end < len(runes) && end-index <= CONTEXT_RADIUS && runes[end] != '\n' {
+		end++
+	}
+	return string(runes[start:index]) + "【" + string(runes[index]) + "】" + string(runes[index+1:end])
+}
+
+// "一" 和 "不" 的变调：
+// 不：在第四声前读 bú；
+// 一：作数字时读 yī，在第四声前读 yí，在其他声调前读 yì
+func applyToneSandhi(characters []Ruby, fromWord []bool) {
+	for i := range characters {
+		if fromWord[i] || (characters[i].Text != "一" && characters[i].Text != "不") {
+			continue
+		}
+		next := 0
+		if i+1 < len(characters) && characters[i+1].Pinyin != "" {
+			next = toneOf(characters[i+1].Pinyin)
+		}
+
+		if characters[i].Text == "不" {
+			characters[i].Pinyin = "bù"
+			if next == 4 {
+				characters[i].Pinyin = "bú"
+			}
+			continue
+		}
+
+		characters[i].Pinyin = "yī"
+		numeral := (i > 0 && strings.Contains(numerals, characters[i-1].Text)) ||
+			(i+1 < len(characters) && strings.Contains(numerals, characters[i+1].Text))
+		switch {
+		case numeral || next == 0 || next == 5:
+		case next == 4:
+			characters[i].Pinyin = "yí"
+		default:
+			characters[i].Pinyin = "yì"
+		}
+	}
+}
+
+// 音节的声调，1-4 为四声，5 为轻声
+func toneOf(syllable string) int {
+	for _, r := range syllable {
+		if tone, ok := toneMarks[r]; ok {
+			return tone
+		}
+	}
+	return 5
+}
+
+// 检查模型给出的读音：有候选读音时必须是其中之一，否则必须是一个拼音音节
+func validReading(reading string, candidates []string) bool {
+	if len(candidates) > 0 {
+		for _, candidate := range candidates {
+			if reading == candidate {
+				return true
+			}
+		}
+		return false
+	}
+	return syllablePattern.MatchString(reading)
+}
+
+// Lines 按换行拆分标注结果，用于逐段渲染，换行符本身不包含在结果中
+func (a *Annotation) Lines() [][]Ruby {
+	var lines [][]Ruby
+	var line []Ruby
+	for _, ruby := range a.Characters {
+		if ruby.Text == "\n" {
+			lines = append(lines, line)
+			line = nil
+			continue
+		}
+		line = append(line, ruby)
+	}
+	return append(lines, line)
+}
+
+// Text 标注对应的原文
+func (a *Annotation) Text() string {
+	var builder strings.Builder
+	for _, ruby := range a.Characters {
+		builder.WriteString(ruby.Text)
+	}
+	return builder.String()
+}
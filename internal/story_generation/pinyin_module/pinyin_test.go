@@ -0,0 +1,97 @@
+package pinyin_module
+
+import (
+	"strings"
+	"testing"
+)
+
+// 把标注结果中的汉字拼音用空格连接
+func joinPinyin(annotation *Annotation) string {
+	var syllables []string
+	for _, ruby := range annotation.Characters {
+		if ruby.Pinyin != "" {
+			syllables = append(syllables, ruby.Pinyin)
+		}
+	}
+	return strings.Join(syllables, " ")
+}
+
+func TestLoadDictionary(t *testing.T) {
+	dict, err := loadDictionary()
+	if err != nil {
+		t.Fatalf("加载拼音词典失败: %v", err)
+	}
+	for word, readings := range dict.words {
+		for _, r := range word {
+			if _, ok := dict.readings[r]; !ok {
+				t.Errorf("词语 %s 中的 %c 不在字表中", word, r)
+			}
+		}
+		for _, reading := range readings {
+			if !syllablePattern.MatchString(reading) {
+				t.Errorf("词语 %s 的读音格式错误: %s", word, reading)
+			}
+		}
+	}
+}
+
+func TestAnnotateWithDictionary(t *testing.T) {
+	noModel := func([]query) map[int]string { return nil }
+	cases := map[string]string{
+		"小兔子长大了":  "xiǎo tù zi zhǎng dà le",
+		"长长的尾巴":   "cháng cháng de wěi ba",
+		"他去银行":    "tā qù yín háng",
+		"我们一起睡觉":  "wǒ men yì qǐ shuì jiào",
+		"一个不是第一":  "yí gè bú shì dì yī",
+		"妈妈觉得很快乐": "mā ma jué de hěn kuài lè",
+	}
+	for text, expected := range cases {
+		if got := joinPinyin(annotate(text, noModel)); got != expected {
+			t.Errorf("%s 的拼音为 %s，期望 %s", text, got, expected)
+		}
+	}
+}
+
+func TestAnnotateWithModel(t *testing.T) {
+	var asked []query
+	model := func(queries []query) map[int]string {
+		asked = queries
+		return map[int]string{2: "zhǎng", 4: "xíng"}
+	}
+	annotation := annotate("我长高，行吗", model)
+
+	if len(asked) != 1 || asked[0].Index != 4 || asked[0].Context != "我长高，【行】吗" {
+		t.Fatalf("需要模型判断的字错误: %+v", asked)
+	}
+	// 模型给出的读音只对需要判断的字生效
+	if annotation.Disambiguated != 1 || annotation.Characters[4].Pinyin != "xíng" {
+		t.Errorf("模型判断结果未生效: %+v", annotation)
+	}
+	if annotation.Characters[1].Pinyin != "zhǎng" || annotation.Characters[3].Pinyin != "" {
+		t.Errorf("标注结果错误: %+v", annotation.Characters)
+	}
+	if annotation.Text() != "我长高，行吗" {
+		t.Errorf("原文还原错误: %s", annotation.Text())
+	}
+
+	// 不在候选读音中的回答使用默认读音
+	annotation = annotate("行", func([]query) map[int]string { return map[int]string{0: "hàng"} })
+	if annotation.Characters[0].Pinyin != "xíng" || annotation.Disambiguated != 0 {
+		t.Errorf("无效读音应被忽略: %+v", annotation)
+	}
+}
+
+func TestParseAnswers(t *testing.T) {
+	answers := parseAnswers("1｜Zhǎng\n**2 | háng**\n说明：无\n1｜cháng")
+	if answers[1] != "zhǎng" || answers[2] != "háng" || len(answers) != 2 {
+		t.Errorf("解析回答错误: %v", answers)
+	}
+}
+
+func TestLines(t *testing.T) {
+	annotation := annotate("小鸟。\n飞了", func([]query) map[int]string { return nil })
+	lines := annotation.Lines()
+	if len(lines) != 2 || len(lines[0]) != 3 || lines[1][0].Pinyin != "fēi" {
+		t.Errorf("按行拆分错误: %+v", lines)
+	}
+}
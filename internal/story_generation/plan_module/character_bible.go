@@ -0,0 +1,164 @@
+package plan_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// CHARACTER_VOICES 按顺序分配给角色的有道 TTS 音色
+var CHARACTER_VOICES = []string{"youxiaoxun", "youxiaozhi", "youxiaoqin"}
+
+// 角色设定中各字段的中文标签
+var characterBibleFields = map[string]string{
+	"角色名":  "name",
+	"名字":   "name",
+	"物种":   "species",
+	"外貌":   "appearance",
+	"性格":   "personality",
+	"说话风格": "speaking_style",
+	"人物关系": "relationships",
+	"关系":   "relationships",
+}
+
+// 生成结构化的角色设定，失败时退化为由角色名称和详细信息构成的简单设定
+func generateCharacterBible(premise string, setting string, names []string, details []string, options PlanOptions) []common.CharacterProfile {
+	prompt := "故事前提: " + premise + "\n\n" + "故事背景: " + setting + "\n\n" +
+		"角色信息：\n" + strings.Join(details, "\n") + "\n\n" +
+		"请为以上每个角色整理一份角色设定，" +
+		"要求：\n" +
+		"1. " + options.prompts().instruction + "\n" +
+		"2. 不要使用特殊字符、星号或markdown格式\n" +
+		"3. 每个字段言简意赅，不超过20个字\n" +
+		"4. 字段名保持下面的格式不变\n" +
+		"5. 每个角色按照如下格式列出：\n" +
+		"1. 角色名：...\n" +
+		"物种：...\n" +
+		"外貌：...\n" +
+		"性格：...\n" +
+		"说话风格：...\n" +
+		"人物关系：...\n"
+
+	var characters []common.CharacterProfile
+	for attempts := 0; attempts < MAX_ATTEMPTS; attempts++ {
+		response, err := options.Meter.ChatWithModel(prompt)
+		if err != nil {
+			log.Printf("生成角色设定失败: %v", err)
+			continue
+		}
+		characters = parseCharacterBible(removeAsterisks(response))
+		if len(characters) > 0 {
+			break
+		}
+		common.RecordParseFailure("plan/character_bible")
+	}
+
+	if len(characters) == 0 {
+		log.Println("未能生成有效的角色设定，使用角色基本信息代替")
+		characters = fallbackCharacterBible(names, details)
+	}
+	if characterCount := options.characterCount(); len(characters) > characterCount {
+		characters = characters[:characterCount]
+	}
+	assignCharacterVoices(characters)
+	return characters
+}
+
+// 解析角色设定
+func parseCharacterBible(response string) []common.CharacterProfile {
+	var characters []common.CharacterProfile
+	var current *common.CharacterProfile
+
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		// 以序号开头的行表示新角色
+		if regexp.MustCompile(`^\d+\.`).MatchString(line) {
+			if current != nil && current.Name != "" {
+				characters = append(characters, *current)
+			}
+			current = &common.CharacterProfile{}
+			line = strings.TrimSpace(regexp.MustCompile(`^\d+\.`).ReplaceAllString(line, ""))
+		}
+		if current == nil {
+			continue
+		}
+
+		key, value, ok := splitField(line)
+		if !ok {
+			continue
+		}
+		switch characterBibleFields[key] {
+		case "name":
+			current.Name = cleanChineseName(value)
+		case "species":
+			current.Species = value
+		case "appearance":
+			current.Appearance = value
+		case "personality":
+			current.Personality = value
+		case "speaking_style":
+			current.SpeakingStyle = value
+		case "relationships":
+			current.Relationships = splitRelationships(value)
+		}
+	}
+
+	if current != nil && current.Name != "" {
+		characters = append(characters, *current)
+	}
+	return characters
+}
+
+// 按中文或英文冒号切分字段名和值
+func splitField(line string) (string, string, bool) {
+	parts := strings.SplitN(line, "：", 2)
+	if len(parts) < 2 {
+		parts = strings.SplitN(line, ":", 2)
+	}
+	if len(parts) < 2 {
+		return "", "", false
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), true
+}
+
+// 切分人物关系
+func splitRelationships(value string) []string {
+	var relationships []string
+	for _, relationship := range regexp.MustCompile(`[；;、，,]`).Split(value, -1) {
+		relationship = strings.TrimSpace(relationship)
+		if relationship != "" && relationship != "无" {
+			relationships = append(relationships, relationship)
+		}
+	}
+	return relationships
+}
+
+// 使用角色名称和详细信息构建简单的角色设定
+func fallbackCharacterBible(names []string, details []string) []common.CharacterProfile {
+	var characters []common.CharacterProfile
+	for i, name := range names {
+		character := common.CharacterProfile{Name: name}
+		if i < len(details) {
+			_, personality, ok := splitField(details[i])
+			if ok {
+				character.Personality = personality
+			}
+		}
+		characters = append(characters, character)
+	}
+	return characters
+}
+
+// 按顺序为角色分配音色
+func assignCharacterVoices(characters []common.CharacterProfile) {
+	for i := range characters {
+		if characters[i].Voice == "" {
+			characters[i].Voice = CHARACTER_VOICES[i%len(CHARACTER_VOICES)]
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package plan_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	CONTINUATION_SECTIONS = 3 // 续写时新增的大纲段落数
+)
+
+// GenerateContinuationOutline 为已有故事续写大纲，新段落接在原有大纲之后
+// 参数：
+// - planInfo: 已有故事的计划
+// - ending: 已有故事最后一段的内容
+// - facts: 已有故事中已经确立的事实，由 common.FormatFacts 生成
+func GenerateContinuationOutline(planInfo *PlanInfo, ending string, facts string, options PlanOptions) ([]string, error) {
+	prompt, err := prompt_module.Render("plan/continuation", options.Language, options.PromptVariants, struct {
+		Background  string
+		Outline     string
+		Ending      string
+		Facts       string
+		Count       int
+		AgeGuidance string
+	}{planInfo.InferAttributesString, numberedSections(planInfo.OutlineSections), ending, facts, CONTINUATION_SECTIONS, options.ageGuidance()})
+	if err != nil {
+		return nil, err
+	}
+
+	for attempts := 0; attempts < MAX_ATTEMPTS; attempts++ {
+		response, err := options.Meter.ChatWithModel(prompt)
+		if err != nil {
+			return nil, fmt.Errorf("无法生成续写大纲: %w", err)
+		}
+		sections := parseOutlineSections(removeAsterisks(response))
+		if len(sections) > 0 {
+			if len(sections) > CONTINUATION_SECTIONS {
+				sections = sections[:CONTINUATION_SECTIONS]
+			}
+			log.Println("continuation outline: ", sections)
+			return sections, nil
+		}
+		common.RecordParseFailure("plan/continuation_outline")
+	}
+	return nil, fmt.Errorf("未能生成有效的续写大纲")
+}
+
+// GenerateSequelPlan 生成续集计划：沿用原故事的背景和角色设定（包括配音），使用新的故事前提
+// premise 为空时由模型根据原故事构思续集前提
+func GenerateSequelPlan(previous *PlanInfo, premise string, facts string, options PlanOptions) (*PlanInfo, error) {
+	if strings.TrimSpace(premise) == "" {
+		generated, err := generateSequelPremise(previous, facts, options)
+		if err != nil {
+			return nil, err
+		}
+		premise = generated
+	}
+	log.Println("sequel premise: ", premise)
+
+	planInfo := &PlanInfo{
+		Premise:          premise,
+		Setting:          previous.Setting,
+		Characters:       previous.Characters,
+		CharacterStrings: previous.CharacterStrings,
+		CharacterBible:   previous.CharacterBible,
+		AgeGroup:         previous.AgeGroup,
+		Goals:            previous.Goals,
+		Language:         previous.Language,
+	}
+	if options.AgeProfile != nil {
+		planInfo.AgeGroup = options.AgeProfile.Key
+	}
+	if options.Goals != nil {
+		planInfo.Goals = options.Goals
+	}
+	if options.Language != "" {
+		planInfo.Language = options.language().Code
+	}
+
+	// 背景信息中加入前情，让大纲和草稿都能延续上一个故事
+	prompts := PlanOptions{Language: planInfo.Language}.prompts()
+	planInfo.InferAttributesString = BuildInferAttributesString(planInfo.Language, premise, previous.Setting, previous.Characters, previous.CharacterStrings) +
+		"\n\n" + prompts.previousStory + "\n" + previous.Premise + "\n" + strings.Join(previous.OutlineSections, "\n")
+	if facts != "" {
+		planInfo.InferAttributesString += "\n\n" + prompts.previousFacts + "\n" + facts
+	}
+
+	outline, outlineSections, err := generateOutline(planInfo.InferAttributesString, options)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("sequel outline: ", outlineSections)
+	planInfo.Outline = outline
+	planInfo.OutlineSections = outlineSections
+	planInfo.Title = generateTitle(premise, planInfo.InferAttributesString, outline, options)
+	log.Println("sequel title: ", planInfo.Title)
+	return planInfo, nil
+}
+
+// 根据原故事构思续集前提
+func generateSequelPremise(previous *PlanInfo, facts string, options PlanOptions) (string, error) {
+	prompt, err := prompt_module.Render("plan/sequel_premise", options.Language, options.PromptVariants, struct {
+		Background string
+		Outline    string
+		Facts      string
+	}{previous.InferAttributesString, numberedSections(previous.OutlineSections), facts})
+	if err != nil {
+		return "", err
+	}
+
+	for attempts := 0; attempts < MAX_ATTEMPTS; attempts++ {
+		premise, err := options.Meter.ChatWithModel(prompt)
+		if err != nil {
+			return "", fmt.Errorf("无法生成续集前提: %w", err)
+		}
+		premise = strings.TrimSpace(removeAsterisks(premise))
+		if premise != "" {
+			return premise, nil
+		}
+	}
+	return "", fmt.Errorf("未能生成有效的续集前提")
+}
+
+// 将大纲段落格式化为 "1. 大纲" 的形式
+func numberedSections(sections []string) string {
+	var builder strings.Builder
+	for i, section := range sections {
+		builder.WriteString(fmt.Sprintf("%d. %s\n", i+1, section))
+	}
+	return builder.String()
+}
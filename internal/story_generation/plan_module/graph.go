@@ -0,0 +1,91 @@
+package plan_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	MAX_CHOICES     = 2  // 每个节点最多的选择数
+	MAX_ENDINGS     = 3  // 结局数上限，多条分支需要汇合到这些结局
+	MAX_GRAPH_NODES = 10 // 故事图的节点数上限
+)
+
+var (
+	graphNodeLine = regexp.MustCompile(`^(?:节点|節點|(?i:node))\s*(\d+)\s*[：:]\s*(.+)$`)
+	graphChoice   = regexp.MustCompile(`^(.+?)\s*(->|→)\s*(?:节点|節點|(?i:node))\s*(\d+)$`)
+)
+
+// 生成分支故事图
+// 从起始节点到任一结局的节点数不超过大纲段落数，多条分支汇合到有限的几个结局
+func generateStoryGraph(inferAttributesString string, options PlanOptions) (*common.StoryGraph, error) {
+	maxDepth := options.outlineSectionCount() - 1
+	prompt, err := prompt_module.Render("plan/graph", options.Language, options.PromptVariants, struct {
+		Background        string
+		Choices           int
+		PathLength        int
+		MaxEndings        int
+		MaxNodes          int
+		AgeGuidance       string
+		EducationGuidance string
+	}{inferAttributesString, MAX_CHOICES, maxDepth + 1, MAX_ENDINGS, MAX_GRAPH_NODES, options.ageGuidance(), options.Goals.Guidance()})
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for attempts := 0; attempts < MAX_ATTEMPTS; attempts++ {
+		response, err := options.Meter.ChatWithModel(prompt)
+		if err != nil {
+			return nil, fmt.Errorf("无法生成分支故事图: %w", err)
+		}
+		graph := parseStoryGraph(removeAsterisks(response))
+		if len(graph.Nodes) == 0 {
+			common.RecordParseFailure("plan/graph")
+		}
+		if len(graph.Nodes) > MAX_GRAPH_NODES {
+			lastErr = fmt.Errorf("节点数 %d 超过上限 %d", len(graph.Nodes), MAX_GRAPH_NODES)
+			log.Printf("分支故事图不合法: %v", lastErr)
+			continue
+		}
+		if err := graph.Normalize(maxDepth, MAX_ENDINGS); err != nil {
+			lastErr = err
+			log.Printf("分支故事图不合法: %v", err)
+			continue
+		}
+		return graph, nil
+	}
+	return nil, fmt.Errorf("未能生成有效的分支故事图: %w", lastErr)
+}
+
+// 解析分支故事图，每行格式为 "节点N：情节｜选择->节点M｜..." 或 "节点N：情节｜结局"，英文为 "Node N: ..."
+func parseStoryGraph(response string) *common.StoryGraph {
+	graph := &common.StoryGraph{Root: common.ROOT_NODE_ID}
+	for _, line := range strings.Split(response, "\n") {
+		match := graphNodeLine.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		id, _ := strconv.Atoi(match[1])
+		if graph.Node(id) != nil {
+			continue
+		}
+		parts := strings.Split(strings.ReplaceAll(match[2], "|", "｜"), "｜")
+		node := common.StoryNode{ID: id, Outline: strings.TrimSpace(parts[0])}
+		for _, part := range parts[1:] {
+			choice := graphChoice.FindStringSubmatch(strings.TrimSpace(part))
+			if choice == nil || len(node.Choices) == MAX_CHOICES {
+				continue
+			}
+			next, _ := strconv.Atoi(choice[3])
+			node.Choices = append(node.Choices, common.Choice{Text: strings.TrimSpace(choice[1]), Next: next})
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	return graph
+}
@@ -2,10 +2,10 @@ package plan_module
 
 import (
 	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/prompt_module"
 	"fmt"
 	"log"
 	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -20,34 +20,96 @@ const (
 
 // PlanInfo 存储故事计划的所有信息
 type PlanInfo struct {
-	Premise               string
-	Setting               string
-	Characters            []string
-	CharacterStrings      []string
-	Outline               string
-	OutlineSections       []string
-	InferAttributesString string
+	Premise               string   `json:"premise" yaml:"premise"`
+	Setting               string   `json:"setting" yaml:"setting"`
+	Characters            []string `json:"characters" yaml:"characters"`
+	CharacterStrings      []string `json:"character_strings" yaml:"character_strings"`
+	Outline               string   `json:"outline" yaml:"outline"`
+	OutlineSections       []string `json:"outline_sections" yaml:"outline_sections"`
+	InferAttributesString string   `json:"infer_attributes_string" yaml:"infer_attributes_string"`
+	// 故事标题，由计划阶段根据背景和大纲生成
+	Title string `json:"title,omitempty" yaml:"title,omitempty"`
+	// 结构化的角色设定
+	CharacterBible []common.CharacterProfile `json:"character_bible,omitempty" yaml:"character_bible,omitempty"`
+	// 目标年龄段，如 3-5
+	AgeGroup string `json:"age_group,omitempty" yaml:"age_group,omitempty"`
+	// 教学目标与目标词汇
+	Goals *common.EducationGoals `json:"goals,omitempty" yaml:"goals,omitempty"`
+	// 分支故事图，分支模式下代替 OutlineSections
+	Graph *common.StoryGraph `json:"graph,omitempty" yaml:"graph,omitempty"`
+	// 故事语言，如 zh-Hans、zh-Hant、en
+	Language string `json:"language,omitempty" yaml:"language,omitempty"`
+}
+
+// PlanOptions 生成故事计划时的可选参数
+type PlanOptions struct {
+	// 年龄段设定，非空时覆盖 MAX_CHARACTERS、MAX_OUTLINE_SECTIONS 并在提示词中加入年龄段要求
+	AgeProfile *common.AgeProfile
+	// 教学目标，非空时编入大纲
+	Goals *common.EducationGoals
+	// 分支模式：生成带选择的故事图而不是线性大纲
+	Branching bool
+	// 故事语言，为空时使用 common.DEFAULT_LANGUAGE
+	Language string
+	// 实验分配的提示词模板变体（阶段 → 变体），为空时使用基准模板
+	PromptVariants map[string]string
+	// 模型调用的用量计入的 Meter，为 nil 时只计入进程的累计用量
+	Meter *common.Meter
+}
+
+// 角色数量
+func (o PlanOptions) characterCount() int {
+	if o.AgeProfile != nil && o.AgeProfile.Characters > 0 {
+		return o.AgeProfile.Characters
+	}
+	return MAX_CHARACTERS
+}
+
+// 大纲段落数
+func (o PlanOptions) outlineSectionCount() int {
+	if o.AgeProfile != nil && o.AgeProfile.OutlineSections > 0 {
+		return o.AgeProfile.OutlineSections
+	}
+	return MAX_OUTLINE_SECTIONS
+}
+
+// 年龄段要求，未指定年龄段时为空
+func (o PlanOptions) ageGuidance() string {
+	if o.AgeProfile == nil {
+		return ""
+	}
+	return o.AgeProfile.Guidance()
 }
 
 func GeneratePlanInfo(premise string) (*PlanInfo, error) {
+	return GeneratePlanInfoWithOptions(premise, PlanOptions{})
+}
+
+// GeneratePlanInfoWithOptions 按照可选参数生成故事计划
+func GeneratePlanInfoWithOptions(premise string, options PlanOptions) (*PlanInfo, error) {
 	// 初始化 PlanInfo 结构体
 	planInfo := &PlanInfo{}
 
 	log.Println("premise: ", premise)
 	planInfo.Premise = premise
+	if options.AgeProfile != nil {
+		planInfo.AgeGroup = options.AgeProfile.Key
+	}
+	planInfo.Goals = options.Goals
+	planInfo.Language = options.language().Code
 
 	// 生成 setting
-	setting, err := generateSetting(premise)
+	setting, err := generateSetting(premise, options)
 	if err != nil {
-		return nil, fmt.Errorf("无法生成setting: %v", err)
+		return nil, fmt.Errorf("无法生成setting: %w", err)
 	}
 	log.Println("setting: ", setting)
 	planInfo.Setting = setting
 
 	// 生成角色信息
-	characters, characterDetails, err := generateCharactersInfos(premise, setting)
+	characters, characterDetails, err := generateCharactersInfos(premise, setting, options)
 	if err != nil {
-		return nil, fmt.Errorf("无法生成角色信息: %v", err)
+		return nil, fmt.Errorf("无法生成角色信息: %w", err)
 	}
 	log.Println("characters: ", characters)
 	for _, characterDetail := range characterDetails {
@@ -56,16 +118,29 @@ func GeneratePlanInfo(premise string) (*PlanInfo, error) {
 	planInfo.Characters = characters
 	planInfo.CharacterStrings = characterDetails
 
+	// 生成角色设定
+	planInfo.CharacterBible = generateCharacterBible(premise, setting, characters, characterDetails, options)
+	log.Println("characterBible: ", common.FormatCharacterBible(planInfo.CharacterBible))
+
 	// 生成 InferAttributesString
-	planInfo.InferAttributesString = fmt.Sprintf("前提：%s\n\n背景：%s\n\n角色：\n%s\n\n角色信息：\n%s",
-		premise,
-		setting,
-		strings.Join(characters, "\n"),
-		strings.Join(characterDetails, "\n"),
-	)
+	planInfo.InferAttributesString = BuildInferAttributesString(planInfo.Language, premise, setting, characters, characterDetails)
+
+	if options.Branching {
+		graph, err := generateStoryGraph(planInfo.InferAttributesString, options)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range graph.Nodes {
+			log.Printf("node%d: %s %+v", node.ID, node.Outline, node.Choices)
+		}
+		planInfo.Graph = graph
+		planInfo.Title = generateTitle(premise, planInfo.InferAttributesString, graphOutline(graph), options)
+		log.Println("title: ", planInfo.Title)
+		return planInfo, nil
+	}
 
 	// 生成故事大纲
-	outline, outlineSections, err := generateOutline(planInfo.InferAttributesString)
+	outline, outlineSections, err := generateOutline(planInfo.InferAttributesString, options)
 	if err != nil {
 		return nil, err
 	}
@@ -77,28 +152,44 @@ func GeneratePlanInfo(premise string) (*PlanInfo, error) {
 	planInfo.Outline = outline
 	planInfo.OutlineSections = outlineSections
 
+	// 生成故事标题
+	planInfo.Title = generateTitle(premise, planInfo.InferAttributesString, outline, options)
+	log.Println("title: ", planInfo.Title)
+
 	return planInfo, nil
 }
 
+// BuildInferAttributesString 按故事语言拼接前提、背景与角色信息，作为后续各阶段提示词的背景信息
+func BuildInferAttributesString(language string, premise string, setting string, characters []string, characterDetails []string) string {
+	return fmt.Sprintf(PlanOptions{Language: language}.prompts().inferAttributes,
+		premise,
+		setting,
+		strings.Join(characters, "\n"),
+		strings.Join(characterDetails, "\n"),
+	)
+}
+
 // 生成角色信息
-func generateCharactersInfos(premise string, setting string) ([]string, []string, error) {
+func generateCharactersInfos(premise string, setting string, options PlanOptions) ([]string, []string, error) {
 	// 拼接premise和setting作为前置提醒
-	basePrompt := "故事前提: " + premise + "\n\n" + "故事背景: " + setting + "\n\n"
-
-	charactersPrompt := basePrompt + "请生成" + strconv.Itoa(MAX_CHARACTERS) + "个主要角色，" +
-		"要求：\n" +
-		"1. 用简体中文\n" +
-		"2. 不要使用特殊字符、星号或markdown格式\n" +
-		"3. 避免使用括号、方括号或任何可能影响文本转语音的符号\n" +
-		"4. 每个角色按照1. 2. 3.的格式列出，如 1. 角色名：特点、背景、对故事的影响。\n" +
-		"5. 每个角色需要有中文名字（不包含标点符号）和独特的特点背景。\n"
+	characterCount := options.characterCount()
+
+	charactersPrompt, err := prompt_module.Render("plan/characters", options.Language, options.PromptVariants, struct {
+		Premise     string
+		Setting     string
+		Count       int
+		AgeGuidance string
+	}{premise, setting, characterCount, options.ageGuidance()})
+	if err != nil {
+		return nil, nil, err
+	}
 	var characterNames []string
 	var characterDetails []string
 
 	for attempts := 0; attempts < MAX_ATTEMPTS; attempts++ {
-		charactersBasic, err := common.ChatWithModel(charactersPrompt)
+		charactersBasic, err := options.Meter.ChatWithModel(charactersPrompt)
 		if err != nil {
-			return nil, nil, fmt.Errorf("无法生成角色基本信息: %v", err)
+			return nil, nil, fmt.Errorf("无法生成角色基本信息: %w", err)
 		}
 
 		// 检查是否包含有效的角色信息格式
@@ -113,13 +204,14 @@ func generateCharactersInfos(premise string, setting string) ([]string, []string
 				break // 如果成功生成，退出循环
 			}
 		}
+		common.RecordParseFailure("plan/characters")
 	}
 
 	if len(characterDetails) == 0 {
 		return nil, nil, fmt.Errorf("未能生成有效的角色信息")
 	}
-	if len(characterDetails) > MAX_CHARACTERS {
-		characterDetails = characterDetails[:MAX_CHARACTERS]
+	if len(characterDetails) > characterCount {
+		characterDetails = characterDetails[:characterCount]
 	}
 	return characterNames, characterDetails, nil
 }
@@ -218,28 +310,25 @@ func parseCharacterDetails(charactersBasic string) []string {
 }
 
 // 生成故事大纲
-func generateOutline(inferAttributesString string) (string, []string, error) {
+func generateOutline(inferAttributesString string, options PlanOptions) (string, []string, error) {
 	var outlineSections []string
 	var outlineSectionsRaw string
 	var err error
+	// 生成故事大纲，明确限制不生成不当内容
+	outlinePrompt, err := prompt_module.Render("plan/outline", options.Language, options.PromptVariants, struct {
+		Background        string
+		Count             int
+		AgeGuidance       string
+		EducationGuidance string
+	}{inferAttributesString, options.outlineSectionCount(), options.ageGuidance(), options.Goals.Guidance()})
+	if err != nil {
+		return "", nil, err
+	}
 
 	for i := 0; i < MAX_ATTEMPTS; i++ {
-		// 生成故事大纲，明确限制不生成不当内容
-		outlinePrompt := fmt.Sprintf("%s\n\n请生成一个完整的第三人称的故事大纲，分为"+strconv.Itoa(MAX_OUTLINE_SECTIONS)+"个主要部分，"+
-			"要求："+
-			"1. 用简体中文"+
-			"2. 不要使用特殊字符、星号或markdown格式"+
-			"3. 避免使用括号、方括号或任何可能影响文本转语音的符号"+
-			"4. 请确保内容适合所有年龄段，不包含任何不当或敏感的主题"+
-			"5. 每个部分之间需要有伏笔响应且有逻辑关系并言简意赅"+
-			"输出示例："+
-			"1. 大纲1 "+
-			"2. 大纲2 ",
-			inferAttributesString)
-
-		outlineSectionsRaw, err = common.ChatWithModel(outlinePrompt)
+		outlineSectionsRaw, err = options.Meter.ChatWithModel(outlinePrompt)
 		if err != nil {
-			return "", nil, fmt.Errorf("无法生成大纲分段: %v", err)
+			return "", nil, fmt.Errorf("无法生成大纲分段: %w", err)
 		}
 		// 移除大纲分段中的 * 符号
 		outlineSectionsRaw = removeAsterisks(outlineSectionsRaw)
@@ -250,6 +339,7 @@ func generateOutline(inferAttributesString string) (string, []string, error) {
 		if len(outlineSections) > 0 {
 			break
 		}
+		common.RecordParseFailure("plan/outline")
 	}
 	return outlineSectionsRaw, outlineSections, nil // 返回所有部分
 }
@@ -274,15 +364,16 @@ func parseOutlineSections(outlineSectionsRaw string) []string {
 		// 1. 内容
 		// 第1部分:内容
 		// 第一部分：内容
+		// Part 1: 内容
 		// （第1部分：内容）
-		if match, _ := regexp.MatchString(`^(\d+\.|第[一二三四五六七八九十\d]+部分[：:])`, line); match {
+		if match, _ := regexp.MatchString(`^(\d+\.|第[一二三四五六七八九十\d]+部分[：:]|(?i:part|section)\s*\d+[：:.])`, line); match {
 			// 如果已经有当前大纲，先保存它
 			if currentOutline != "" {
 				sections = append(sections, currentOutline)
 			}
 
 			// 移除序号和括号，只保留内容
-			content := regexp.MustCompile(`^(\d+\.|第[一二三四五六七八九十\d]+部分[：:]|\(|\))`).ReplaceAllString(line, "")
+			content := regexp.MustCompile(`^(\d+\.|第[一二三四五六七八九十\d]+部分[：:]|(?i:part|section)\s*\d+[：:.]|\(|\))`).ReplaceAllString(line, "")
 			currentOutline = strings.TrimSpace(content)
 		} else if currentOutline != "" && line != "" {
 			// 如果这一行不是大纲标题，且不是空行，那么它可能是详细内容的开始
@@ -302,24 +393,20 @@ func parseOutlineSections(outlineSectionsRaw string) []string {
 }
 
 // 新增的 generateSetting 函数
-func generateSetting(premise string) (string, error) {
-	settingPrompt := "故事的前提是: " + premise + "\n\n描述一下故事的背景\n\n" +
-		"要求：\n" +
-		"1. 用简体中文\n" +
-		"2. 不要使用特殊字符、星号或markdown格式\n" +
-		"3. 背景要有趣且富有想象力\n" +
-		"4. 使用简单明了的语言\n" +
-		"5. 避免使用括号、方括号或任何可能影响文本转语音的符号\n" +
-		"对故事发展有指导意义" + "\n\n这个故事发生在"
+func generateSetting(premise string, options PlanOptions) (string, error) {
+	prompts := options.prompts()
+	settingPrompt, err := prompt_module.Render("plan/setting", options.Language, options.PromptVariants, struct{ Premise string }{premise})
+	if err != nil {
+		return "", err
+	}
 	var setting string
-	var err error
 
 	for attempts := 0; attempts < MAX_ATTEMPTS; attempts++ {
-		setting, err = common.ChatWithModel(settingPrompt)
+		setting, err = options.Meter.ChatWithModel(settingPrompt)
 		if err == nil && setting != "" {
-			//切割setting，只保留前100个字符
-			if len(setting) > MAX_SETTING_LENGTH {
-				setting = setting[:MAX_SETTING_LENGTH]
+			//切割setting，只保留前 maxSettingLength 个字节
+			if len(setting) > prompts.maxSettingLength {
+				setting = setting[:prompts.maxSettingLength]
 			}
 			return removeAsterisks(setting), nil
 		}
@@ -2,6 +2,7 @@ package plan_module
 
 import (
 	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/common"
 	"log"
 	"path/filepath"
 	"runtime"
@@ -42,7 +43,7 @@ func TestGenerateCharactersInfos(t *testing.T) {
 	setting := "这个故事发生在一个现代城市，科技发达但人们的生活压力很大"
 
 	// 调用函数
-	characterNames, characterDetails, err := generateCharactersInfos(premise, setting)
+	characterNames, characterDetails, err := generateCharactersInfos(premise, setting, PlanOptions{})
 	if err != nil {
 		t.Fatalf("GenerateCharactersInfos失败: %v", err)
 	}
@@ -54,7 +55,7 @@ func TestGenerateCharactersInfos(t *testing.T) {
 func TestGenerateOutline(t *testing.T) {
 	inferAttributesString := "前提：一个年轻的女孩在森林中迷路了。\n\n背景：这个故事发生在一个神秘的森林，充满了奇幻的生物。\n\n角色：\n1. 小红：勇敢的女孩，善于解决问题。\n2. 狼：狡猾的生物，试图引导小红走向危险。"
 
-	outline, outlineSections, err := generateOutline(inferAttributesString)
+	outline, outlineSections, err := generateOutline(inferAttributesString, PlanOptions{})
 	if err != nil {
 		t.Fatalf("GenerateOutline失败: %v", err)
 	}
@@ -74,3 +75,109 @@ func TestGeneratePlanInfo(t *testing.T) {
 
 	log.Println("planInfo: ", planInfo)
 }
+
+func TestParseCharacterBible(t *testing.T) {
+	response := `1. 角色名：小白
+物种：兔子
+外貌：雪白的毛，红红的眼睛
+性格：胆小善良
+说话风格：轻声细语
+人物关系：猫头鹰爷爷的朋友；小灰的妹妹
+2. 角色名：猫头鹰爷爷
+物种：猫头鹰
+性格：睿智
+人物关系：无`
+
+	characters := parseCharacterBible(response)
+	if len(characters) != 2 {
+		t.Fatalf("角色数量错误: %d", len(characters))
+	}
+	if characters[0].Name != "小白" || characters[0].Species != "兔子" || characters[0].SpeakingStyle != "轻声细语" {
+		t.Errorf("角色设定解析错误: %+v", characters[0])
+	}
+	if len(characters[0].Relationships) != 2 {
+		t.Errorf("人物关系解析错误: %+v", characters[0].Relationships)
+	}
+	if characters[1].Name != "猫头鹰爷爷" || len(characters[1].Relationships) != 0 {
+		t.Errorf("角色设定解析错误: %+v", characters[1])
+	}
+}
+
+func TestParseStoryGraph(t *testing.T) {
+	response := `节点1：小兔在森林里迷路了｜跟着萤火虫走->节点2｜去问猫头鹰->节点3
+节点2：萤火虫带小兔来到小河边｜过桥->节点4
+节点3：猫头鹰告诉小兔回家的方向｜沿着大树走->节点4
+节点4：小兔回到了家，妈妈抱住了它｜结局
+节点9：没有被任何节点指向的节点｜结局`
+
+	graph := parseStoryGraph(response)
+	if err := graph.Normalize(MAX_OUTLINE_SECTIONS-1, MAX_ENDINGS); err != nil {
+		t.Fatalf("故事图不合法: %v", err)
+	}
+	if len(graph.Nodes) != 4 {
+		t.Fatalf("不可达节点没有被去掉: %+v", graph.Nodes)
+	}
+	if root := graph.Node(1); len(root.Choices) != 2 || root.Choices[1].Next != 3 {
+		t.Errorf("选择解析错误: %+v", root.Choices)
+	}
+	if ending := graph.Node(4); !ending.Ending || ending.Depth != 2 {
+		t.Errorf("结局节点错误: %+v", ending)
+	}
+	if parents, _ := graph.Parents(4); len(parents) != 2 {
+		t.Errorf("分支没有汇合: %d", len(parents))
+	}
+}
+
+func TestParseEnglishStoryGraph(t *testing.T) {
+	response := `Node 1: Benny gets lost in the forest | follow the fireflies -> Node 2 | ask Owl -> node 3
+Node 2: The fireflies lead Benny home | The End
+Node 3: Owl shows Benny the way home | The End`
+
+	graph := parseStoryGraph(response)
+	if err := graph.Normalize(MAX_OUTLINE_SECTIONS-1, MAX_ENDINGS); err != nil {
+		t.Fatalf("故事图不合法: %v", err)
+	}
+	if root := graph.Node(1); len(graph.Nodes) != 3 || len(root.Choices) != 2 || root.Choices[1].Next != 3 {
+		t.Errorf("英文故事图解析错误: %+v", graph.Nodes)
+	}
+}
+
+func TestParseEnglishOutlineSections(t *testing.T) {
+	sections := parseOutlineSections("Part 1: Benny finds a map\nPart 2: Benny asks Owl for help\n3. Benny finds the treasure")
+	if len(sections) != 3 || sections[0] != "Benny finds a map" {
+		t.Errorf("英文大纲解析错误: %q", sections)
+	}
+}
+
+func TestCleanTitle(t *testing.T) {
+	zh := common.GetLanguage(common.LANG_ZH_HANS)
+	en := common.GetLanguage(common.LANG_EN)
+	cases := []struct {
+		response string
+		language common.Language
+		want     string
+	}{
+		{"《小兔子找月亮》", zh, "小兔子找月亮"},
+		{"标题：**小兔子找月亮**。\n这是一个关于勇气的故事", zh, "小兔子找月亮"},
+		{"Title: \"Benny and the Lost Map\"", en, "Benny and the Lost Map"},
+		{"  \n", zh, ""},
+	}
+	for _, c := range cases {
+		if got := cleanTitle(c.response, c.language); got != c.want {
+			t.Errorf("cleanTitle(%q) = %q, 期望 %q", c.response, got, c.want)
+		}
+	}
+}
+
+func TestFallbackTitle(t *testing.T) {
+	if title := FallbackTitle("一只小兔子想去月亮上看看。它做了一架梯子", common.LANG_ZH_HANS); title != "一只小兔子想去月亮上看看" {
+		t.Errorf("中文备用标题错误: %q", title)
+	}
+	if title := FallbackTitle("一只住在森林深处的小兔子每天晚上都会抬头看着天上又大又圆的月亮", common.LANG_ZH_HANS); len([]rune(title)) != MAX_TITLE_RUNES {
+		t.Errorf("中文备用标题没有截断: %q", title)
+	}
+	title := FallbackTitle("A little bear who lives at the edge of a very big forest wants to find out where the river goes. He packs a bag", common.LANG_EN)
+	if title != "A little bear who lives at the edge of a very big forest wants to find out where" {
+		t.Errorf("英文备用标题错误: %q", title)
+	}
+}
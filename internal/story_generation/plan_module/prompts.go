@@ -0,0 +1,52 @@
+package plan_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+)
+
+// 各语言的计划阶段文本；背景、角色、大纲、分支故事图和续写的提示词见 prompt_module 的 plan/ 模板
+type planPrompts struct {
+	// 背景信息，参数依次为前提、背景、角色、角色信息
+	inferAttributes string
+	// 背景描述的最大字节数
+	maxSettingLength int
+	// 其余提示词中使用的语言要求
+	instruction string
+	// 续集背景信息中前情和上一个故事已确立事实的标题
+	previousStory string
+	previousFacts string
+}
+
+var planPromptTemplates = map[string]planPrompts{
+	common.LANG_ZH_HANS: {
+		inferAttributes:  "前提：%s\n\n背景：%s\n\n角色：\n%s\n\n角色信息：\n%s",
+		maxSettingLength: MAX_SETTING_LENGTH,
+		instruction:      "用简体中文",
+		previousStory:    "前情：",
+		previousFacts:    "上一个故事中已经确立的事实（类别｜主体｜事实）：",
+	},
+	common.LANG_ZH_HANT: {
+		inferAttributes:  "前提：%s\n\n背景：%s\n\n角色：\n%s\n\n角色資訊：\n%s",
+		maxSettingLength: MAX_SETTING_LENGTH,
+		instruction:      "用繁體中文",
+		previousStory:    "前情：",
+		previousFacts:    "上一個故事中已經確立的事實（類別｜主體｜事實）：",
+	},
+	common.LANG_EN: {
+		inferAttributes:  "Premise: %s\n\nSetting: %s\n\nCharacters:\n%s\n\nCharacter details:\n%s",
+		maxSettingLength: MAX_SETTING_LENGTH * 4,
+		instruction:      "Write in English",
+		previousStory:    "Previously:",
+		previousFacts:    "Facts established in the previous story (category | subject | fact):",
+	},
+}
+
+// 当前语言的提示词模板，未知语言使用默认语言
+func (o PlanOptions) prompts() planPrompts {
+	return planPromptTemplates[o.language().Code]
+}
+
+// 当前语言
+func (o PlanOptions) language() common.Language {
+	return common.GetLanguage(o.Language)
+}
@@ -0,0 +1,106 @@
+package plan_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"log"
+	"strings"
+	"unicode"
+)
+
+const (
+	MAX_TITLE_RUNES = 20 // 中文标题的最大字数，英文标题按 4 倍计算
+)
+
+// 根据背景信息和大纲生成故事标题，失败时退化为由故事前提截取的标题，不会失败
+func generateTitle(premise string, inferAttributesString string, outline string, options PlanOptions) string {
+	prompt, err := prompt_module.Render("plan/title", options.Language, options.PromptVariants, struct {
+		Background string
+		Outline    string
+	}{inferAttributesString, outline})
+	if err == nil {
+		for attempts := 0; attempts < MAX_ATTEMPTS; attempts++ {
+			response, err := options.Meter.ChatWithModel(prompt)
+			if err != nil {
+				log.Printf("生成故事标题失败: %v", err)
+				continue
+			}
+			if title := cleanTitle(response, options.language()); title != "" {
+				return title
+			}
+			common.RecordParseFailure("plan/title")
+		}
+	} else {
+		log.Printf("渲染标题提示词失败: %v", err)
+	}
+
+	log.Println("未能生成有效的故事标题，使用故事前提代替")
+	return FallbackTitle(premise, options.Language)
+}
+
+// 清理模型返回的标题：只取第一行，去掉标签、引号、书名号和末尾标点，过长时截断
+func cleanTitle(response string, language common.Language) string {
+	title := strings.TrimSpace(removeAsterisks(response))
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = strings.TrimSpace(title[:i])
+	}
+	for _, label := range []string{"标题", "標題", "title"} {
+		if len(title) > len(label) && strings.EqualFold(title[:len(label)], label) {
+			if rest := strings.TrimLeft(title[len(label):], " "); strings.HasPrefix(rest, ":") || strings.HasPrefix(rest, "：") {
+				title = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(rest, ":"), "："))
+			}
+		}
+	}
+	title = strings.Trim(title, "\"'“”‘’《》「」『』")
+	title = strings.TrimRightFunc(title, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	})
+	return truncateTitle(title, language)
+}
+
+// FallbackTitle 由故事前提截取的标题，用于模型未能生成标题或文档缺少标题的情况：取第一句，过长时截断
+func FallbackTitle(premise string, language string) string {
+	lang := common.GetLanguage(language)
+	title := strings.TrimSpace(premise)
+	if i := strings.IndexAny(title, "。！？!?\n"); i >= 0 {
+		title = title[:i]
+	}
+	if !lang.IsChinese() {
+		if i := strings.Index(title, ". "); i >= 0 {
+			title = title[:i]
+		}
+	}
+	title = strings.TrimRightFunc(title, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	})
+	return truncateTitle(title, lang)
+}
+
+// 按语言截断标题：中文按字数截断，其他语言在单词边界截断
+func truncateTitle(title string, language common.Language) string {
+	limit := MAX_TITLE_RUNES
+	if !language.IsChinese() {
+		limit *= 4
+	}
+	runes := []rune(title)
+	if len(runes) <= limit {
+		return title
+	}
+	title = string(runes[:limit])
+	if !language.IsChinese() && !unicode.IsSpace(runes[limit]) {
+		// 截断处在单词中间时丢弃不完整的单词
+		if i := strings.LastIndexByte(title, ' '); i > 0 {
+			title = title[:i]
+		}
+	}
+	return strings.TrimSpace(title)
+}
+
+// 分支故事图各节点的大纲，用于生成标题
+func graphOutline(graph *common.StoryGraph) string {
+	var outlines []string
+	for _, node := range graph.Nodes {
+		outlines = append(outlines, node.Outline)
+	}
+	return numberedSections(outlines)
+}
@@ -0,0 +1,305 @@
+// 提示词模块：各阶段的提示词以 text/template 文件维护，不需要重新编译即可调整措辞
+//
+// 模板按 templates/<语言>/<阶段>/<名称>.tmpl 组织，模板名称为 "<阶段>/<名称>"，
+// 每个模板文件以 {{- /* version: N */ -}} 开头声明版本。内置模板编译进程序，
+// 配置 prompts.dir 后，目录中相同路径的模板覆盖内置模板，也可以新增模板。
+// <名称>@<变体>.tmpl 是同一模板的实验变体，由 experiment_module 按流量分配给请求。
+// 故事元数据记录生成时所用模板的名称、变体和版本。
+package prompt_module
+
+import (
+	"bytes"
+	"embed"
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/common"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+const (
+	TEMPLATE_EXT = ".tmpl"
+	// 模板文件名中名称与变体的分隔符
+	VARIANT_SEPARATOR = "@"
+	// SOURCE_EMBEDDED 内置模板的来源
+	SOURCE_EMBEDDED = "embedded"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+// 模板开头的版本声明
+var versionPattern = regexp.MustCompile(`^\{\{-?\s*/\*\s*version:\s*(\S+)\s*\*/\s*-?\}\}`)
+
+// 模板中可用的辅助函数
+var funcs = template.FuncMap{
+	// 序号从 1 开始
+	"inc": func(i int) int { return i + 1 },
+	"join": func(items []string, separator string) string {
+		return strings.Join(items, separator)
+	},
+	// 构造和追加字符串列表，用于拼接带序号的要求列表
+	"list": func(items ...string) []string { return items },
+	"append": func(items []string, item string) []string {
+		return append(append([]string(nil), items...), item)
+	},
+}
+
+// Ref 提示词模板的名称、语言、变体和版本，记录在故事元数据中
+type Ref struct {
+	Name     string `json:"name" yaml:"name"`
+	Language string `json:"language" yaml:"language"`
+	// 实验变体，基准模板为空
+	Variant string `json:"variant,omitempty" yaml:"variant,omitempty"`
+	Version string `json:"version" yaml:"version"`
+}
+
+// Variants 一次请求中各阶段使用的模板变体（阶段 → 变体），未列出的阶段使用基准模板
+type Variants map[string]string
+
+// For 模板名称 "<阶段>/<名称>" 所在阶段的变体
+func (v Variants) For(name string) string {
+	stage, _, _ := strings.Cut(name, "/")
+	return v[stage]
+}
+
+// Template 已加载的提示词模板
+type Template struct {
+	Ref
+	// 模板来源：SOURCE_EMBEDDED 或覆盖目录中的文件路径
+	Source string
+	tmpl   *template.Template
+}
+
+// Registry 按语言和名称索引的提示词模板
+type Registry struct {
+	templates map[string]*Template
+}
+
+var (
+	mu      sync.RWMutex
+	current *Registry
+)
+
+// Load 加载内置模板，dir 非空时用目录中的模板覆盖内置模板；加载失败时保留之前加载的模板
+func Load(dir string) error {
+	registry, err := NewRegistry(dir)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	current = registry
+	mu.Unlock()
+	for _, ref := range registry.Refs("", nil) {
+		if ref.Variant != "" {
+			log.Printf("提示词模板 %s/%s 变体 %s 版本 %s", ref.Language, ref.Name, ref.Variant, ref.Version)
+			continue
+		}
+		log.Printf("提示词模板 %s/%s 版本 %s", ref.Language, ref.Name, ref.Version)
+	}
+	return nil
+}
+
+// NewRegistry 加载内置模板及 dir 中的覆盖模板
+func NewRegistry(dir string) (*Registry, error) {
+	registry := &Registry{templates: make(map[string]*Template)}
+	templates, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		return nil, err
+	}
+	if err := registry.loadFS(templates, SOURCE_EMBEDDED); err != nil {
+		return nil, fmt.Errorf("加载内置提示词模板失败: %v", err)
+	}
+	if dir != "" {
+		if err := registry.loadFS(os.DirFS(dir), dir); err != nil {
+			return nil, fmt.Errorf("加载提示词模板目录 %s 失败: %v", dir, err)
+		}
+	}
+	return registry, nil
+}
+
+// 加载文件系统中的全部模板，同名模板覆盖已加载的模板
+func (r *Registry) loadFS(fsys fs.FS, source string) error {
+	return fs.WalkDir(fsys, ".", func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || path.Ext(file) != TEMPLATE_EXT {
+			return nil
+		}
+		parts := strings.SplitN(strings.TrimSuffix(file, TEMPLATE_EXT), "/", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("%s: 模板必须放在语言目录下", file)
+		}
+		if _, ok := common.LANGUAGES[parts[0]]; !ok {
+			return fmt.Errorf("%s: 不支持的语言目录 %s", file, parts[0])
+		}
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		text := strings.TrimSuffix(string(data), "\n")
+		match := versionPattern.FindStringSubmatch(text)
+		if match == nil {
+			return fmt.Errorf("%s: 模板开头缺少版本声明", file)
+		}
+		name, variant, _ := strings.Cut(parts[1], VARIANT_SEPARATOR)
+		tmpl, err := template.New(parts[1]).Funcs(funcs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+
+		templateSource := source
+		if source != SOURCE_EMBEDDED {
+			templateSource = path.Join(source, file)
+		}
+		r.templates[key(parts[0], name, variant)] = &Template{
+			Ref:    Ref{Name: name, Language: parts[0], Variant: variant, Version: match[1]},
+			Source: templateSource,
+			tmpl:   tmpl,
+		}
+		return nil
+	})
+}
+
+func key(language string, name string, variant string) string {
+	if variant != "" {
+		name += VARIANT_SEPARATOR + variant
+	}
+	return language + "/" + name
+}
+
+// Lookup 查找模板：依次使用该语言的变体、该语言的基准模板和默认语言的基准模板
+func (r *Registry) Lookup(name string, language string, variant string) (*Template, error) {
+	code := common.GetLanguage(language).Code
+	if variant != "" {
+		if t, ok := r.templates[key(code, name, variant)]; ok {
+			return t, nil
+		}
+	}
+	if t, ok := r.templates[key(code, name, "")]; ok {
+		return t, nil
+	}
+	if t, ok := r.templates[key(common.DEFAULT_LANGUAGE, name, "")]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("找不到提示词模板 %s", name)
+}
+
+// HasVariant 是否有任意语言的模板提供某个阶段的变体
+func (r *Registry) HasVariant(stage string, variant string) bool {
+	for _, t := range r.templates {
+		if t.Variant == variant && inStages(t.Name, []string{stage}) {
+			return true
+		}
+	}
+	return false
+}
+
+// Render 渲染指定名称和语言的模板，variants 指定各阶段使用的变体
+func (r *Registry) Render(name string, language string, variants Variants, data interface{}) (string, error) {
+	t, err := r.Lookup(name, language, variants.For(name))
+	if err != nil {
+		return "", err
+	}
+	var buffer bytes.Buffer
+	if err := t.tmpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("渲染提示词模板 %s 版本 %s 失败: %v", name, t.Version, err)
+	}
+	return buffer.String(), nil
+}
+
+// Refs 返回某个语言实际使用的各模板（含变体及回退到默认语言的模板）的名称和版本，按名称排序；
+// stages 非空时只返回这些阶段的模板，language 为空时返回全部已加载的模板
+func (r *Registry) Refs(language string, variants Variants, stages ...string) []Ref {
+	var refs []Ref
+	if language == "" {
+		for _, t := range r.templates {
+			refs = append(refs, t.Ref)
+		}
+	} else {
+		names := make(map[string]bool)
+		for _, t := range r.templates {
+			if inStages(t.Name, stages) {
+				names[t.Name] = true
+			}
+		}
+		for name := range names {
+			if t, err := r.Lookup(name, language, variants.For(name)); err == nil {
+				refs = append(refs, t.Ref)
+			}
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Name != refs[j].Name {
+			return refs[i].Name < refs[j].Name
+		}
+		if refs[i].Language != refs[j].Language {
+			return refs[i].Language < refs[j].Language
+		}
+		return refs[i].Variant < refs[j].Variant
+	})
+	return refs
+}
+
+// 模板名称 "<阶段>/<名称>" 是否属于指定阶段，未指定阶段时都属于
+func inStages(name string, stages []string) bool {
+	if len(stages) == 0 {
+		return true
+	}
+	for _, stage := range stages {
+		if strings.HasPrefix(name, stage+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// 当前使用的模板；启动时没有调用 Load 的程序（如命令行工具）在第一次使用时按配置加载
+func registry() *Registry {
+	mu.RLock()
+	r := current
+	mu.RUnlock()
+	if r != nil {
+		return r
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		loaded, err := NewRegistry(config.GetConfig().Prompts.Dir)
+		if err != nil {
+			// 覆盖模板有误时退回内置模板
+			log.Printf("加载提示词模板时发生错误，使用内置模板: %v", err)
+			loaded, err = NewRegistry("")
+			if err != nil {
+				panic(err)
+			}
+		}
+		current = loaded
+	}
+	return current
+}
+
+// Render 渲染指定名称和语言的提示词，variants 为空时使用基准模板
+func Render(name string, language string, variants Variants, data interface{}) (string, error) {
+	return registry().Render(name, language, variants, data)
+}
+
+// Refs 某个语言的故事在指定阶段所使用的提示词模板及版本
+func Refs(language string, variants Variants, stages ...string) []Ref {
+	return registry().Refs(language, variants, stages...)
+}
+
+// HasVariant 当前加载的模板中是否有某个阶段的变体
+func HasVariant(stage string, variant string) bool {
+	return registry().HasVariant(stage, variant)
+}
@@ -0,0 +1,248 @@
+package prompt_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 写入覆盖目录中的模板文件
+func writeTemplate(t *testing.T, dir string, file string, content string) {
+	t.Helper()
+	path := filepath.Join(dir, file)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEmbeddedTemplatesRender(t *testing.T) {
+	registry, err := NewRegistry("")
+	if err != nil {
+		t.Fatalf("加载内置模板失败: %v", err)
+	}
+	draft := common.Draft{
+		CurrentSection:        "小兔子出门找朋友",
+		NextOutlineSection:    "小兔子遇到了小熊",
+		InferAttributesString: "前提：小兔子交朋友",
+		CharacterBible:        "小白：一只好奇的小兔子",
+		TargetLength:          200,
+		IncomingChoices:       []string{"走左边", "走右边"},
+	}
+	data := map[string]interface{}{
+		"plan/setting": struct{ Premise string }{"小兔子交朋友"},
+		"plan/characters": struct {
+			Premise, Setting, AgeGuidance string
+			Count                         int
+		}{"前提", "森林", "", 3},
+		"plan/outline": struct {
+			Background, AgeGuidance, EducationGuidance string
+			Count                                      int
+		}{"背景", "句子简短", "", 5},
+		"plan/graph": struct {
+			Background, AgeGuidance, EducationGuidance string
+			Choices, PathLength, MaxEndings, MaxNodes  int
+		}{"背景", "句子简短", "", 2, 5, 3, 10},
+		"plan/continuation": struct {
+			Background, Outline, Ending, Facts, AgeGuidance string
+			Count                                           int
+		}{"背景", "1. 大纲\n", "结尾", "物品｜钥匙｜小白拿着", "", 3},
+		"plan/sequel_premise": struct{ Background, Outline, Facts string }{"背景", "1. 大纲\n", ""},
+		"plan/title":          struct{ Background, Outline string }{"背景", "1. 大纲\n"},
+		"draft/section":       draft,
+		"rewrite/score": struct {
+			common.Draft
+			Candidate, Dimension, Label string
+		}{draft, "候选段落", "quality", "内容质量"},
+		"edit/rewrite": struct {
+			common.Draft
+			Candidate string
+		}{draft, "候选段落"},
+		"edit/consistency": struct{ CharacterBible, Content, NoIssues string }{"小白：一只好奇的小兔子", "段落", "无矛盾"},
+		"edit/length": struct {
+			Content       string
+			Count, Budget int
+		}{"段落", 300, 200},
+		"fact/extract": struct {
+			Content    string
+			Categories []string
+		}{"段落", []string{"物品", "位置"}},
+		"fact/contradiction": struct{ Facts, Content, NoIssues string }{"物品｜钥匙｜小白拿着", "段落", "无矛盾"},
+		"education/activity": struct {
+			Story, Background, AgeGuidance, EducationGuidance string
+			MinQuestions, MaxQuestions                        int
+		}{"故事", "背景", "句子简短", "", 2, 4},
+		"education/objective": struct{ Objective, Story string }{"学会分享", "故事"},
+		"moderation/classify": struct {
+			Subject, Text string
+			Categories    []string
+		}{"故事内容", "段落", []string{"暴力", "恐怖"}},
+		"moderation/rewrite": struct {
+			Subject, Text string
+			Reasons       []string
+		}{"故事内容", "段落", []string{"包含恐怖类关键词：鬼魂"}},
+		"service/story": struct {
+			Theme, EducationGuidance, StoryType, AgeGroup, AgeGuidance string
+			TargetLength                                               int
+		}{"分享", "", "童话", "3-5", "句子简短", 300},
+		"service/story_system":        nil,
+		"service/image_prompt":        struct{ ImageType, Story string }{"水彩", "故事"},
+		"service/image_prompt_system": nil,
+	}
+
+	for _, ref := range registry.Refs("", nil) {
+		if ref.Version == "" {
+			t.Errorf("模板 %s/%s 没有版本", ref.Language, ref.Name)
+		}
+		value, ok := data[ref.Name]
+		if !ok {
+			t.Errorf("模板 %s 没有测试数据", ref.Name)
+			continue
+		}
+		prompt, err := registry.Render(ref.Name, ref.Language, nil, value)
+		if err != nil {
+			t.Errorf("渲染 %s/%s 失败: %v", ref.Language, ref.Name, err)
+			continue
+		}
+		if strings.Contains(prompt, "<no value>") || strings.HasPrefix(prompt, "\n") {
+			t.Errorf("%s/%s 渲染结果异常:\n%s", ref.Language, ref.Name, prompt)
+		}
+	}
+
+	// 每种语言都有完整的计划、续写、草稿、打分、编辑、事实、学习和审核模板
+	for code := range common.LANGUAGES {
+		for _, name := range []string{"plan/setting", "plan/characters", "plan/outline", "plan/graph", "plan/continuation", "plan/sequel_premise", "plan/title",
+			"draft/section", "rewrite/score", "edit/rewrite", "edit/length", "edit/consistency", "fact/extract", "fact/contradiction",
+			"education/activity", "education/objective", "moderation/classify", "moderation/rewrite"} {
+			if _, ok := registry.templates[key(code, name, "")]; !ok {
+				t.Errorf("缺少 %s/%s 模板", code, name)
+			}
+		}
+	}
+}
+
+func TestDraftSectionRequirements(t *testing.T) {
+	registry, err := NewRegistry("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompt, err := registry.Render("draft/section", common.LANG_EN, nil, common.Draft{
+		Language:           common.LANG_EN,
+		CurrentSection:     "The bunny meets a bear",
+		PreOutlineSection:  "The bunny leaves home",
+		PreContent:         "Once upon a time",
+		NextOutlineSection: "They become friends",
+		TargetLength:       120,
+		OutgoingChoices:    []string{"run", "stay"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"Requirements:\n1. Write in English\n", "7. About 120 words for this part\n", "directions: run, stay\n", "The full text of this part is as follows"} {
+		if !strings.Contains(prompt, expected) {
+			t.Errorf("提示词缺少 %q:\n%s", expected, prompt)
+		}
+	}
+}
+
+func TestOverrideDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "zh-Hans/plan/setting.tmpl", "{{- /* version: 2-test */ -}}\n背景：{{.Premise}}\n")
+	writeTemplate(t, dir, "en/service/image_prompt_system.tmpl", "{{- /* version: 1 */ -}}\nYou are a storyteller.")
+
+	registry, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatalf("加载覆盖目录失败: %v", err)
+	}
+	prompt, err := registry.Render("plan/setting", common.LANG_ZH_HANS, nil, struct{ Premise string }{"小兔子"})
+	if err != nil || prompt != "背景：小兔子" {
+		t.Errorf("覆盖模板未生效: %q %v", prompt, err)
+	}
+	template, _ := registry.Lookup("plan/setting", common.LANG_ZH_HANS, "")
+	if template.Version != "2-test" || template.Source != filepath.Join(dir, "zh-Hans/plan/setting.tmpl") {
+		t.Errorf("覆盖模板的版本或来源错误: %+v", template.Ref)
+	}
+	// 没有覆盖的语言仍使用内置模板
+	template, _ = registry.Lookup("plan/setting", common.LANG_ZH_HANT, "")
+	if template.Source != SOURCE_EMBEDDED {
+		t.Errorf("繁体中文模板不应被覆盖: %s", template.Source)
+	}
+	// 新增的模板只对该语言生效，其他语言回退到默认语言
+	template, _ = registry.Lookup("service/image_prompt_system", common.LANG_EN, "")
+	if template.Language != common.LANG_EN {
+		t.Errorf("英文模板未生效: %+v", template.Ref)
+	}
+	template, _ = registry.Lookup("service/image_prompt_system", common.LANG_ZH_HANT, "")
+	if template.Language != common.DEFAULT_LANGUAGE {
+		t.Errorf("应回退到默认语言: %+v", template.Ref)
+	}
+
+	refs := registry.Refs(common.LANG_ZH_HANS, nil, "plan")
+	if setting := refs[len(refs)-2]; len(refs) != 7 || setting.Name != "plan/setting" || setting.Version != "2-test" {
+		t.Errorf("模板版本记录错误: %+v", refs)
+	}
+}
+
+func TestVariants(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "zh-Hans/draft/section@vivid.tmpl", "{{- /* version: 1 */ -}}\n生动：{{.CurrentSection}}")
+	registry, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	draft := common.Draft{CurrentSection: "小兔子出门"}
+	variants := Variants{"draft": "vivid"}
+
+	prompt, err := registry.Render("draft/section", common.LANG_ZH_HANS, variants, draft)
+	if err != nil || prompt != "生动：小兔子出门" {
+		t.Errorf("变体模板未生效: %q %v", prompt, err)
+	}
+	// 其他阶段以及没有该变体的语言使用基准模板
+	template, _ := registry.Lookup("plan/setting", common.LANG_ZH_HANS, variants.For("plan/setting"))
+	if template.Variant != "" {
+		t.Errorf("计划阶段不应使用变体: %+v", template.Ref)
+	}
+	template, _ = registry.Lookup("draft/section", common.LANG_EN, variants.For("draft/section"))
+	if template.Variant != "" || template.Language != common.LANG_EN {
+		t.Errorf("英文应使用基准模板: %+v", template.Ref)
+	}
+	if !registry.HasVariant("draft", "vivid") || registry.HasVariant("plan", "vivid") {
+		t.Error("变体检查错误")
+	}
+
+	refs := registry.Refs(common.LANG_ZH_HANS, variants, "draft")
+	if len(refs) != 1 || refs[0].Variant != "vivid" {
+		t.Errorf("应记录使用的变体: %+v", refs)
+	}
+}
+
+func TestInvalidTemplates(t *testing.T) {
+	cases := map[string]string{
+		"zh-Hans/plan/setting.tmpl": "背景：{{.Premise}}",
+		"zh-Hans/plan/outline.tmpl": "{{- /* version: 1 */ -}}\n{{if .Count}}",
+		"fr/plan/setting.tmpl":      "{{- /* version: 1 */ -}}\nLe cadre",
+		"setting.tmpl":              "{{- /* version: 1 */ -}}\n背景",
+	}
+	for file, content := range cases {
+		dir := t.TempDir()
+		writeTemplate(t, dir, file, content)
+		if _, err := NewRegistry(dir); err == nil {
+			t.Errorf("%s 应加载失败", file)
+		}
+	}
+
+	// 模板中引用不存在的字段时渲染失败
+	dir := t.TempDir()
+	writeTemplate(t, dir, "zh-Hans/plan/setting.tmpl", "{{- /* version: 1 */ -}}\n{{.Missing}}")
+	registry, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Render("plan/setting", common.LANG_ZH_HANS, nil, map[string]string{"Premise": "小兔子"}); err == nil {
+		t.Error("缺少字段时应渲染失败")
+	}
+}
@@ -0,0 +1,36 @@
+package readability_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"testing"
+)
+
+func TestGetAgeProfile(t *testing.T) {
+	testCases := map[string]string{
+		"3-5岁":  "3-5",
+		"3~5岁":  "3-5",
+		"9到12岁": "9-12",
+		"4岁":    "3-5",
+		"":      common.DEFAULT_AGE_GROUP,
+	}
+	for ageGroup, want := range testCases {
+		if got := common.GetAgeProfile(ageGroup).Key; got != want {
+			t.Errorf("年龄段 %q 识别错误: got %s, want %s", ageGroup, got, want)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	profile := common.GetAgeProfile("3-5")
+
+	report := Analyze("小兔子跳呀跳。它找到了一个大萝卜！", profile)
+	if !report.Passed || report.SentenceCount != 2 {
+		t.Errorf("简单文本应通过检查: %+v", report)
+	}
+
+	long := "小兔子在森林里遇到了一只正在树下唱歌的小鸟和一只正在河边喝水的小鹿。"
+	report = Analyze(long, profile)
+	if report.Passed || len(report.LongSentences) != 1 {
+		t.Errorf("长句应被标记: %+v", report)
+	}
+}
@@ -0,0 +1,36 @@
+package rewrite_module
+
+import (
+	"flutterdreams/internal/story_generation/common"
+)
+
+// 打分维度
+const (
+	DIMENSION_COHERENCE = "coherence"
+	DIMENSION_QUALITY   = "quality"
+	DIMENSION_FLUENCY   = "fluency"
+)
+
+// 各语言的维度名称，同时用作输出格式中的标签；评分标准见 prompt_module 的 rewrite/score 模板
+var dimensionLabels = map[string]map[string]string{
+	common.LANG_ZH_HANS: {
+		DIMENSION_COHERENCE: "连贯性",
+		DIMENSION_QUALITY:   "内容质量",
+		DIMENSION_FLUENCY:   "表达流畅度",
+	},
+	common.LANG_ZH_HANT: {
+		DIMENSION_COHERENCE: "連貫性",
+		DIMENSION_QUALITY:   "內容品質",
+		DIMENSION_FLUENCY:   "表達流暢度",
+	},
+	common.LANG_EN: {
+		DIMENSION_COHERENCE: "Coherence",
+		DIMENSION_QUALITY:   "Content quality",
+		DIMENSION_FLUENCY:   "Fluency",
+	},
+}
+
+// 草稿所用语言中某个维度的名称
+func labelFor(draft Draft, dimension string) string {
+	return dimensionLabels[common.GetLanguage(draft.Language).Code][dimension]
+}
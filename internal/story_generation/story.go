@@ -3,31 +3,216 @@
 package story_generation
 
 import (
+	"flutterdreams/config"
+	"flutterdreams/internal/story_generation/bilingual_module"
+	"flutterdreams/internal/story_generation/common"
 	"flutterdreams/internal/story_generation/draft_module"
+	"flutterdreams/internal/story_generation/education_module"
+	"flutterdreams/internal/story_generation/experiment_module"
+	"flutterdreams/internal/story_generation/moderation_module"
+	"flutterdreams/internal/story_generation/pinyin_module"
 	"flutterdreams/internal/story_generation/plan_module"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"flutterdreams/internal/story_generation/readability_module"
+	"flutterdreams/internal/story_generation/story_document"
 	"fmt"
 	"log"
+	"time"
 )
 
+const (
+	DEFAULT_TARGET_LENGTH = 1000 // 未指定长度时故事的默认目标字数
+)
+
+// StoryOptions 生成故事时的可选参数
+type StoryOptions struct {
+	// 故事总目标字数，为 0 时使用年龄段的默认字数，未指定年龄段时使用 DEFAULT_TARGET_LENGTH
+	TargetLength int
+	// 目标年龄段，如 3-5岁，为空时不做年龄段适配
+	AgeGroup string
+	// 教学目标与目标词汇，为空时不做教学适配
+	Goals *common.EducationGoals
+	// 是否生成配套学习单（阅读理解题、讨论话题和活动）
+	ActivitySheet bool
+	// 分支模式：生成带选择的故事图，由读者选择情节走向
+	Branching bool
+	// 双语对照的目标语言或 pinyin，为空时不生成双语对照
+	BilingualTarget string
+	// 是否为最终文本逐字标注拼音，只适用于中文故事
+	Pinyin bool
+	// 故事语言，如 zh-Hans、zh-Hant、en，为空时使用 common.DEFAULT_LANGUAGE
+	// 中文的长度单位为字，英文为词
+	Language string
+	// 请求 ID，用于确定性地分配提示词实验变体，为空时不参与实验
+	RequestID string
+	// 各阶段的进度回调，为 nil 时不报告进度
+	Progress common.ProgressFunc
+	// 模型调用的用量计入的 Meter，用于按请求计量，为 nil 时只计入进程的累计用量
+	Meter *common.Meter
+	// 根据 RequestID 分配的实验变体
+	assignments []experiment_module.Assignment
+}
+
 func GenerateStory(premise string) string {
-	//plan
-	planInfo, err := plan_module.GeneratePlanInfo(premise)
+	doc, err := GenerateStoryDocument(premise, StoryOptions{})
 	if err != nil {
-		fmt.Println("生成计划信息时出错: ", err)
+		log.Printf("生成故事时发生错误: %v", err)
 		return ""
 	}
+	return doc.FinalText
+}
+
+// GenerateStoryDocument 给定premise，生成完整的故事文档
+func GenerateStoryDocument(premise string, options StoryOptions) (*story_document.StoryDocument, error) {
+	startTime := time.Now()
+	ageProfile := ageProfileFor(options.AgeGroup)
+	language := common.GetLanguage(options.Language)
+	options.Language = language.Code
+	options.assignments = experiment_module.Assign(options.RequestID)
+	variants := experiment_module.Variants(options.assignments)
+	if options.TargetLength <= 0 {
+		options.TargetLength = DEFAULT_TARGET_LENGTH
+		if ageProfile != nil {
+			options.TargetLength = ageProfile.TargetLength
+		}
+		options.TargetLength = language.ScaleLength(options.TargetLength)
+	}
+
+	// 审核用户输入的故事前提，被拦截时直接返回 *moderation_module.BlockedError
+	premise, inputDecision, err := moderation_module.Moderate(moderation_module.STAGE_INPUT, premise, options.Language, options.Meter)
+	if err != nil {
+		return nil, err
+	}
+
+	//plan
+	options.Progress.Report(common.Progress{Stage: common.PROGRESS_PLAN, Total: 1})
+	planInfo, err := plan_module.GeneratePlanInfoWithOptions(premise, plan_module.PlanOptions{
+		AgeProfile:     ageProfile,
+		Goals:          options.Goals,
+		Branching:      options.Branching,
+		Language:       options.Language,
+		PromptVariants: variants,
+		Meter:          options.Meter,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("生成计划信息时出错: %w", err)
+	}
+	options.Progress.Report(common.Progress{Stage: common.PROGRESS_PLAN, Done: 1, Total: 1})
 
 	//Draft
-	draft, err := draft_module.GenerateDraft(planInfo.InferAttributesString, planInfo.OutlineSections)
+	draftOptions := draft_module.DraftOptions{
+		Characters:     planInfo.CharacterBible,
+		TargetLength:   options.TargetLength,
+		AgeProfile:     ageProfile,
+		Goals:          options.Goals,
+		Language:       options.Language,
+		PromptVariants: variants,
+		Progress:       options.Progress,
+		Meter:          options.Meter,
+	}
+	var drafts []common.Draft
+	if planInfo.Graph != nil {
+		err = draft_module.GenerateGraphDrafts(planInfo.InferAttributesString, planInfo.Graph, draftOptions)
+	} else {
+		drafts, err = draft_module.GenerateDraftSections(planInfo.InferAttributesString, planInfo.OutlineSections, draftOptions)
+	}
 	if err != nil {
-		fmt.Println("生成草稿时出错: ", err)
-		return ""
+		return nil, fmt.Errorf("生成草稿时出错: %w", err)
+	}
+
+	options.Progress.Report(common.Progress{Stage: common.PROGRESS_MODERATION})
+	// 逐段审核，改写后的内容写回段落，最终文本由审核后的段落拼接而成
+	outputDecisions, err := moderateDrafts(drafts, options.Language, options.Meter)
+	if err != nil {
+		return nil, err
+	}
+	doc := story_document.NewStoryDocument(planInfo, drafts)
+	doc.Moderation = append(doc.Moderation, inputDecision)
+	doc.Moderation = append(doc.Moderation, outputDecisions...)
+
+	if doc.Graph != nil {
+		// 分支故事逐个节点审核，改写后的内容写回节点
+		for i := range doc.Graph.Nodes {
+			node := &doc.Graph.Nodes[i]
+			content, decision, err := moderation_module.Moderate(moderation_module.STAGE_OUTPUT, node.Content, options.Language, options.Meter)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = content
+			doc.Moderation = append(doc.Moderation, decision)
+		}
+		doc.FinalText = doc.JoinSections()
+	}
+
+	finishDocument(doc, planInfo.InferAttributesString, ageProfile, options, startTime)
+	options.Progress.Report(common.Progress{Stage: common.PROGRESS_DONE})
+	return doc, nil
+}
+
+// 逐段审核草稿，改写后的内容写回草稿，使段落内容与由段落拼接的最终文本一致
+func moderateDrafts(drafts []common.Draft, language string, meter *common.Meter) ([]moderation_module.Decision, error) {
+	var decisions []moderation_module.Decision
+	for i := range drafts {
+		content, decision, err := moderation_module.Moderate(moderation_module.STAGE_OUTPUT, drafts[i].Content, language, meter)
+		if err != nil {
+			return nil, err
+		}
+		drafts[i].Content = content
+		decisions = append(decisions, decision)
 	}
-	log.Println("draft: ", draft)
-	return draft
-	//Rewrite
+	return decisions, nil
+}
 
-	//Edit
+// 生成故事文本之后的收尾工作：教学目标覆盖检查、双语对照、拼音标注、学习单、可读性检查及元数据
+func finishDocument(doc *story_document.StoryDocument, background string, ageProfile *common.AgeProfile, options StoryOptions, startTime time.Time) {
+	options.Progress.Report(common.Progress{Stage: common.PROGRESS_FINISH})
+	if !options.Goals.Empty() {
+		// 检查目标词汇与学习目标的覆盖情况
+		coverage := education_module.CheckCoverage(options.Goals, doc.SectionContents(), options.Language, options.Meter)
+		doc.Coverage = &coverage
+		log.Printf("教学目标覆盖情况: %+v", coverage)
+	}
 
-	//Story
+	if options.BilingualTarget != "" {
+		// 按段落生成逐句对照，失败时只记录日志
+		parallelText, err := bilingual_module.AlignSections(doc.SectionContents(), options.Language, options.BilingualTarget, options.Meter)
+		if err != nil {
+			log.Printf("生成双语对照时发生错误: %v", err)
+		}
+		doc.ParallelText = parallelText
+	}
+
+	if options.Pinyin && common.GetLanguage(options.Language).IsChinese() {
+		// 多音字读音无法确定时使用默认读音，不会失败
+		doc.Pinyin = pinyin_module.Annotate(doc.FinalText, options.Meter)
+		log.Printf("拼音标注完成，模型判断读音 %d 处", doc.Pinyin.Disambiguated)
+	}
+
+	if options.ActivitySheet {
+		sheet, err := education_module.GenerateActivitySheet(doc.FinalText, background, ageProfile, options.Goals, options.Language, options.Meter)
+		if err != nil {
+			// 学习单是附加内容，生成失败不影响故事
+			log.Printf("生成学习单时发生错误: %v", err)
+		}
+		doc.ActivitySheet = sheet
+	}
+
+	doc.Metadata.Model = config.GetConfig().DefaultModel
+	doc.Metadata.TargetLength = options.TargetLength
+	doc.Metadata.Prompts = prompt_module.Refs(options.Language, experiment_module.Variants(options.assignments), "plan", "draft", "rewrite", "edit", "fact", "education", "moderation")
+	doc.Metadata.RequestID = options.RequestID
+	doc.Metadata.Experiments = options.assignments
+	if ageProfile != nil && common.GetLanguage(options.Language).IsChinese() {
+		// 按年龄段检查可读性，只记录结果不改写故事；可读性指标只适用于中文
+		report := readability_module.Analyze(doc.FinalText, *ageProfile)
+		doc.Readability = &report
+		log.Printf("可读性检查: 平均句长 %.1f, 最长句 %d, 非常用字比例 %.2f, 问题: %v",
+			report.AvgSentenceLength, report.MaxSentenceLength, report.RareCharRatio, report.Issues)
+	}
+	doc.Metadata.DurationMs = time.Since(startTime).Milliseconds()
+
+	// 记录实验分配结果和评分，供离线比较各变体
+	if err := experiment_module.RecordStory(doc.ID, options.RequestID, doc.Language, options.assignments, doc.AverageScores()); err != nil {
+		log.Printf("记录实验结果时发生错误: %v", err)
+	}
 }
@@ -0,0 +1,107 @@
+package story_document
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Format 故事文档的序列化格式
+type Format string
+
+const (
+	FORMAT_JSON Format = "json"
+	FORMAT_YAML Format = "yaml"
+)
+
+// FormatFromPath 根据文件扩展名判断序列化格式，无法识别时默认使用 JSON
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FORMAT_YAML
+	default:
+		return FORMAT_JSON
+	}
+}
+
+// Marshal 将故事文档序列化为指定格式
+func Marshal(doc *StoryDocument, format Format) ([]byte, error) {
+	if doc.Version == 0 {
+		doc.Version = CURRENT_VERSION
+	}
+
+	switch format {
+	case FORMAT_JSON:
+		return json.MarshalIndent(doc, "", "  ")
+	case FORMAT_YAML:
+		return yaml.Marshal(doc)
+	default:
+		return nil, fmt.Errorf("不支持的故事文档格式: %s", format)
+	}
+}
+
+// Unmarshal 解析故事文档，旧版本的文档会自动迁移到当前版本
+func Unmarshal(data []byte, format Format) (*StoryDocument, error) {
+	var header struct {
+		Version int `json:"version" yaml:"version"`
+	}
+	if err := decode(data, format, &header); err != nil {
+		return nil, fmt.Errorf("解析故事文档版本失败: %v", err)
+	}
+
+	if header.Version > CURRENT_VERSION {
+		return nil, fmt.Errorf("故事文档版本 %d 高于当前支持的版本 %d", header.Version, CURRENT_VERSION)
+	}
+
+	return migrate(header.Version, data, format)
+}
+
+// Save 将故事文档保存到文件，格式由扩展名决定
+func Save(doc *StoryDocument, path string) error {
+	data, err := Marshal(doc, FormatFromPath(path))
+	if err != nil {
+		return fmt.Errorf("序列化故事文档失败: %v", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Load 从文件读取故事文档，格式由扩展名决定
+func Load(path string) (*StoryDocument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取故事文档失败: %v", err)
+	}
+	return Unmarshal(data, FormatFromPath(path))
+}
+
+func decode(data []byte, format Format, v interface{}) error {
+	switch format {
+	case FORMAT_JSON:
+		return json.Unmarshal(data, v)
+	case FORMAT_YAML:
+		return yaml.Unmarshal(data, v)
+	default:
+		return fmt.Errorf("不支持的故事文档格式: %s", format)
+	}
+}
+
+// migrate 将指定版本的文档迁移到当前版本
+// 新增版本时在此处补充从上一版本迁移的分支；版本 1 是第一个版本，没有版本号的数据不是故事文档
+func migrate(version int, data []byte, format Format) (*StoryDocument, error) {
+	switch version {
+	case 0:
+		return nil, fmt.Errorf("故事文档缺少版本号")
+	case CURRENT_VERSION:
+		var doc StoryDocument
+		if err := decode(data, format, &doc); err != nil {
+			return nil, fmt.Errorf("解析故事文档失败: %v", err)
+		}
+		return &doc, nil
+	default:
+		return nil, fmt.Errorf("无法迁移版本为 %d 的故事文档", version)
+	}
+}
@@ -0,0 +1,345 @@
+// 故事文档：可序列化、带版本号的故事产物格式
+//
+// 一个 StoryDocument 完整记录一次故事生成的结果，包括前提、背景、角色、大纲、
+// 各段落草稿及打分、最终文本、音频/图片等媒体引用以及生成元数据，
+// 可以保存为 JSON 或 YAML，用于保存、对比、分享和重新加载故事。
+package story_document
+
+import (
+	"flutterdreams/internal/story_generation/bilingual_module"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/education_module"
+	"flutterdreams/internal/story_generation/experiment_module"
+	"flutterdreams/internal/story_generation/moderation_module"
+	"flutterdreams/internal/story_generation/pinyin_module"
+	"flutterdreams/internal/story_generation/plan_module"
+	"flutterdreams/internal/story_generation/prompt_module"
+	"flutterdreams/internal/story_generation/readability_module"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// CURRENT_VERSION 当前故事文档格式的版本号，格式发生不兼容变化时递增并在 migrate 中补充迁移逻辑
+	CURRENT_VERSION = 1
+	// GENERATOR 写入元数据的生成器名称
+	GENERATOR = "flutterdreams"
+)
+
+// 媒体类型
+const (
+	MEDIA_AUDIO = "audio"
+	MEDIA_IMAGE = "image"
+)
+
+// StoryDocument 故事文档
+type StoryDocument struct {
+	Version int    `json:"version" yaml:"version"`
+	ID      string `json:"id,omitempty" yaml:"id,omitempty"`
+	// 续写或续集所基于的故事 ID
+	ParentID   string      `json:"parent_id,omitempty" yaml:"parent_id,omitempty"`
+	Title      string      `json:"title,omitempty" yaml:"title,omitempty"`
+	Premise    string      `json:"premise" yaml:"premise"`
+	Setting    string      `json:"setting" yaml:"setting"`
+	Characters []Character `json:"characters" yaml:"characters"`
+	Outline    []string    `json:"outline" yaml:"outline"`
+	Sections   []Section   `json:"sections" yaml:"sections"`
+	// 分支故事图，分支模式下代替 Outline 与 Sections
+	Graph     *common.StoryGraph `json:"graph,omitempty" yaml:"graph,omitempty"`
+	FinalText string             `json:"final_text" yaml:"final_text"`
+	AgeGroup  string             `json:"age_group,omitempty" yaml:"age_group,omitempty"`
+	// 故事语言，如 zh-Hans、zh-Hant、en
+	Language    string                                `json:"language,omitempty" yaml:"language,omitempty"`
+	Readability *readability_module.ReadabilityReport `json:"readability,omitempty" yaml:"readability,omitempty"`
+	Goals       *common.EducationGoals                `json:"goals,omitempty" yaml:"goals,omitempty"`
+	Coverage    *education_module.CoverageReport      `json:"coverage,omitempty" yaml:"coverage,omitempty"`
+	// 逐句对齐的双语对照
+	ParallelText *bilingual_module.ParallelText `json:"parallel_text,omitempty" yaml:"parallel_text,omitempty"`
+	// 最终文本的逐字拼音标注
+	Pinyin *pinyin_module.Annotation `json:"pinyin,omitempty" yaml:"pinyin,omitempty"`
+	// 配套学习单
+	ActivitySheet *education_module.ActivitySheet `json:"activity_sheet,omitempty" yaml:"activity_sheet,omitempty"`
+	Media         []MediaRef                      `json:"media,omitempty" yaml:"media,omitempty"`
+	// 各阶段的内容审核结果
+	Moderation []moderation_module.Decision `json:"moderation,omitempty" yaml:"moderation,omitempty"`
+	Metadata   Metadata                     `json:"metadata" yaml:"metadata"`
+}
+
+// Character 角色名称、特点及结构化的角色设定
+type Character struct {
+	Name        string   `json:"name" yaml:"name"`
+	Traits      []string `json:"traits" yaml:"traits"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+
+	Species       string   `json:"species,omitempty" yaml:"species,omitempty"`
+	Appearance    string   `json:"appearance,omitempty" yaml:"appearance,omitempty"`
+	Personality   string   `json:"personality,omitempty" yaml:"personality,omitempty"`
+	SpeakingStyle string   `json:"speaking_style,omitempty" yaml:"speaking_style,omitempty"`
+	Relationships []string `json:"relationships,omitempty" yaml:"relationships,omitempty"`
+	Voice         string   `json:"voice,omitempty" yaml:"voice,omitempty"`
+}
+
+// Section 单个大纲段落及其草稿
+type Section struct {
+	Index           int                     `json:"index" yaml:"index"`
+	Outline         string                  `json:"outline" yaml:"outline"`
+	TargetLength    int                     `json:"target_length,omitempty" yaml:"target_length,omitempty"`
+	Content         string                  `json:"content" yaml:"content"`
+	Scores          common.Scores           `json:"scores" yaml:"scores"`
+	CharacterIssues []common.CharacterIssue `json:"character_issues,omitempty" yaml:"character_issues,omitempty"`
+	// 从本段提取的事实及未能解决的事实矛盾
+	Facts            []common.Fact            `json:"facts,omitempty" yaml:"facts,omitempty"`
+	ContinuityIssues []common.ContinuityIssue `json:"continuity_issues,omitempty" yaml:"continuity_issues,omitempty"`
+}
+
+// MediaRef 音频、图片等媒体资源的引用
+type MediaRef struct {
+	Type   string `json:"type" yaml:"type"`
+	URL    string `json:"url" yaml:"url"`
+	Prompt string `json:"prompt,omitempty" yaml:"prompt,omitempty"`
+}
+
+// Metadata 生成元数据
+type Metadata struct {
+	Generator string `json:"generator" yaml:"generator"`
+	Model     string `json:"model,omitempty" yaml:"model,omitempty"`
+	// 请求的故事总目标字数
+	TargetLength int       `json:"target_length,omitempty" yaml:"target_length,omitempty"`
+	CreatedAt    time.Time `json:"created_at" yaml:"created_at"`
+	DurationMs   int64     `json:"duration_ms,omitempty" yaml:"duration_ms,omitempty"`
+	// 生成时使用的提示词模板及版本
+	Prompts []prompt_module.Ref `json:"prompts,omitempty" yaml:"prompts,omitempty"`
+	// 请求 ID 及据此分配的提示词实验变体
+	RequestID   string                         `json:"request_id,omitempty" yaml:"request_id,omitempty"`
+	Experiments []experiment_module.Assignment `json:"experiments,omitempty" yaml:"experiments,omitempty"`
+}
+
+// NewStoryDocument 根据故事计划和段落草稿创建故事文档
+func NewStoryDocument(planInfo *plan_module.PlanInfo, drafts []common.Draft) *StoryDocument {
+	doc := &StoryDocument{
+		Version:    CURRENT_VERSION,
+		ID:         NewID(),
+		Title:      planInfo.Title,
+		Premise:    planInfo.Premise,
+		Setting:    planInfo.Setting,
+		Characters: buildCharacters(planInfo.Characters, planInfo.CharacterStrings, planInfo.CharacterBible),
+		Outline:    planInfo.OutlineSections,
+		AgeGroup:   planInfo.AgeGroup,
+		Language:   planInfo.Language,
+		Goals:      planInfo.Goals,
+		Graph:      planInfo.Graph,
+		Metadata: Metadata{
+			Generator: GENERATOR,
+			CreatedAt: time.Now(),
+		},
+	}
+
+	doc.AppendDrafts(drafts)
+	doc.FinalText = doc.JoinSections()
+	return doc
+}
+
+// AppendDrafts 将段落草稿追加到故事文档末尾，不更新 FinalText
+func (doc *StoryDocument) AppendDrafts(drafts []common.Draft) {
+	for _, draft := range drafts {
+		doc.Sections = append(doc.Sections, Section{
+			Index:            draft.Index,
+			Outline:          draft.CurrentSection,
+			TargetLength:     draft.TargetLength,
+			Content:          draft.Content,
+			Scores:           draft.Scores,
+			CharacterIssues:  draft.CharacterIssues,
+			Facts:            draft.NewFacts,
+			ContinuityIssues: draft.ContinuityIssues,
+		})
+	}
+}
+
+// NewID 生成新的故事文档 ID
+func NewID() string {
+	return uuid.New().String()
+}
+
+// JoinSections 按顺序拼接所有段落内容，段落之间使用故事语言的分隔符
+func (doc *StoryDocument) JoinSections() string {
+	return strings.Join(doc.SectionContents(), common.GetLanguage(doc.Language).SectionSeparator)
+}
+
+// SectionContents 返回各段落内容；分支故事返回主线路径（每次都走第一个选择）上各节点的内容
+func (doc *StoryDocument) SectionContents() []string {
+	var contents []string
+	if doc.Graph != nil && len(doc.Sections) == 0 {
+		for _, node := range doc.Graph.MainPath() {
+			contents = append(contents, node.Content)
+		}
+		return contents
+	}
+	for _, section := range doc.Sections {
+		contents = append(contents, section.Content)
+	}
+	return contents
+}
+
+// AverageScores 各段落（分支故事为各节点）评分的平均值
+func (doc *StoryDocument) AverageScores() common.Scores {
+	var scores []common.Scores
+	if doc.Graph != nil && len(doc.Sections) == 0 {
+		for _, node := range doc.Graph.Nodes {
+			scores = append(scores, node.Scores)
+		}
+	}
+	for _, section := range doc.Sections {
+		scores = append(scores, section.Scores)
+	}
+
+	var average common.Scores
+	if len(scores) == 0 {
+		return average
+	}
+	for _, s := range scores {
+		average.Coherence += s.Coherence
+		average.Quality += s.Quality
+		average.Fluency += s.Fluency
+		average.Total += s.Total
+	}
+	count := float64(len(scores))
+	average.Coherence /= count
+	average.Quality /= count
+	average.Fluency /= count
+	average.Total /= count
+	return average
+}
+
+// ExportText 导出可打印的纯文本：标题、故事全文，以及学习单（如果有）
+func (doc *StoryDocument) ExportText() string {
+	var builder strings.Builder
+	if doc.Title != "" {
+		builder.WriteString(doc.Title)
+		builder.WriteString("\n\n")
+	}
+	builder.WriteString(doc.FinalText)
+	builder.WriteString("\n")
+	if doc.ActivitySheet != nil {
+		builder.WriteString("\n")
+		builder.WriteString(doc.ActivitySheet.Text())
+	}
+	return builder.String()
+}
+
+// FactLedger 按段落顺序汇总所有段落提取的事实
+func (doc *StoryDocument) FactLedger() *common.FactLedger {
+	ledger := &common.FactLedger{}
+	for _, section := range doc.Sections {
+		ledger.Add(section.Facts...)
+	}
+	if doc.Graph != nil {
+		for _, node := range doc.Graph.MainPath() {
+			ledger.Add(node.Facts...)
+		}
+	}
+	return ledger
+}
+
+// AddMedia 添加媒体引用
+func (doc *StoryDocument) AddMedia(mediaType string, url string, prompt string) {
+	doc.Media = append(doc.Media, MediaRef{Type: mediaType, URL: url, Prompt: prompt})
+}
+
+// ToPlanInfo 将故事文档还原为 PlanInfo，便于重新载入生成流程
+func (doc *StoryDocument) ToPlanInfo() *plan_module.PlanInfo {
+	var names []string
+	var details []string
+	var bible []common.CharacterProfile
+	for _, character := range doc.Characters {
+		names = append(names, character.Name)
+		details = append(details, character.detailString())
+		bible = append(bible, character.Profile())
+	}
+
+	return &plan_module.PlanInfo{
+		Title:                 doc.Title,
+		Premise:               doc.Premise,
+		Setting:               doc.Setting,
+		Characters:            names,
+		CharacterStrings:      details,
+		Outline:               strings.Join(doc.Outline, "\n"),
+		OutlineSections:       doc.Outline,
+		AgeGroup:              doc.AgeGroup,
+		Language:              doc.Language,
+		Goals:                 doc.Goals,
+		InferAttributesString: plan_module.BuildInferAttributesString(doc.Language, doc.Premise, doc.Setting, names, details),
+		CharacterBible:        bible,
+	}
+}
+
+// Profile 返回角色的结构化设定
+func (c Character) Profile() common.CharacterProfile {
+	return common.CharacterProfile{
+		Name:          c.Name,
+		Species:       c.Species,
+		Appearance:    c.Appearance,
+		Personality:   c.Personality,
+		SpeakingStyle: c.SpeakingStyle,
+		Relationships: c.Relationships,
+		Voice:         c.Voice,
+	}
+}
+
+// 还原为 plan_module 使用的 "角色名：特点" 格式
+func (c Character) detailString() string {
+	if c.Description != "" {
+		return c.Name + "：" + c.Description
+	}
+	return c.Name + "：" + strings.Join(c.Traits, "、")
+}
+
+// 根据角色名称、详细信息和角色设定构建结构化角色
+func buildCharacters(names []string, details []string, bible []common.CharacterProfile) []Character {
+	var characters []Character
+	profiles := make(map[string]common.CharacterProfile)
+	for _, profile := range bible {
+		profiles[profile.Name] = profile
+	}
+	for i, detail := range details {
+		character := Character{}
+		description := stripCharacterPrefix(detail)
+		if i < len(names) {
+			character.Name = names[i]
+			description = strings.TrimPrefix(description, character.Name)
+			description = strings.TrimLeft(description, "：: ")
+		}
+		character.Description = description
+		character.Traits = splitTraits(description)
+		if profile, ok := profiles[character.Name]; ok {
+			character.Species = profile.Species
+			character.Appearance = profile.Appearance
+			character.Personality = profile.Personality
+			character.SpeakingStyle = profile.SpeakingStyle
+			character.Relationships = profile.Relationships
+			character.Voice = profile.Voice
+		}
+		characters = append(characters, character)
+	}
+	return characters
+}
+
+// 去掉 "1. " 这样的序号前缀
+func stripCharacterPrefix(detail string) string {
+	detail = regexp.MustCompile(`^\d+\.\s*`).ReplaceAllString(strings.TrimSpace(detail), "")
+	return strings.TrimSpace(detail)
+}
+
+// 将角色描述按中英文分隔符切分为特点列表
+func splitTraits(description string) []string {
+	var traits []string
+	for _, trait := range regexp.MustCompile(`[，、,；;。\n]`).Split(description, -1) {
+		trait = strings.TrimSpace(trait)
+		if trait != "" {
+			traits = append(traits, trait)
+		}
+	}
+	return traits
+}
@@ -0,0 +1,134 @@
+package story_document
+
+import (
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/pinyin_module"
+	"flutterdreams/internal/story_generation/plan_module"
+	"strings"
+	"testing"
+)
+
+func newTestDocument() *StoryDocument {
+	planInfo := &plan_module.PlanInfo{
+		Premise:          "一只害怕黑夜的小兔子学会了勇敢",
+		Setting:          "这个故事发生在一片宁静的森林里",
+		Characters:       []string{"小白", "猫头鹰爷爷"},
+		CharacterStrings: []string{"1. 小白：胆小、善良、喜欢胡萝卜", "2. 猫头鹰爷爷：睿智，夜里守护森林"},
+		OutlineSections:  []string{"小白害怕天黑", "猫头鹰爷爷带小白看星星"},
+	}
+	drafts := []common.Draft{
+		{Index: 0, CurrentSection: "小白害怕天黑", Content: "天黑了，小白躲进了洞里。", Scores: common.Scores{Total: 7.5}},
+		{Index: 1, CurrentSection: "猫头鹰爷爷带小白看星星", Content: "猫头鹰爷爷带着小白看星星。", Scores: common.Scores{Total: 8.1}},
+	}
+	return NewStoryDocument(planInfo, drafts)
+}
+
+func TestNewStoryDocument(t *testing.T) {
+	doc := newTestDocument()
+
+	if doc.FinalText != "天黑了，小白躲进了洞里。猫头鹰爷爷带着小白看星星。" {
+		t.Errorf("最终文本拼接错误: %s", doc.FinalText)
+	}
+	if len(doc.Characters) != 2 {
+		t.Fatalf("角色数量错误: %d", len(doc.Characters))
+	}
+	if doc.Characters[0].Name != "小白" || len(doc.Characters[0].Traits) != 3 {
+		t.Errorf("角色解析错误: %+v", doc.Characters[0])
+	}
+	if doc.Characters[1].Traits[0] != "睿智" {
+		t.Errorf("角色特点解析错误: %+v", doc.Characters[1])
+	}
+}
+
+// 英文段落之间换行，不会把上一段的结尾和下一段的开头粘在一起
+func TestNewEnglishStoryDocument(t *testing.T) {
+	planInfo := &plan_module.PlanInfo{Premise: "A shy rabbit learns to be brave", Language: common.LANG_EN}
+	drafts := []common.Draft{
+		{Index: 0, Content: "Benny hid in the forest."},
+		{Index: 1, Content: "The next day, he met an owl."},
+	}
+	doc := NewStoryDocument(planInfo, drafts)
+	if doc.FinalText != "Benny hid in the forest.\nThe next day, he met an owl." {
+		t.Errorf("英文最终文本拼接错误: %q", doc.FinalText)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	for _, format := range []Format{FORMAT_JSON, FORMAT_YAML} {
+		t.Run(string(format), func(t *testing.T) {
+			doc := newTestDocument()
+			doc.AddMedia(MEDIA_AUDIO, "http://localhost:8080/getAudio?filename=a.mp3", "")
+
+			data, err := Marshal(doc, format)
+			if err != nil {
+				t.Fatalf("序列化失败: %v", err)
+			}
+			loaded, err := Unmarshal(data, format)
+			if err != nil {
+				t.Fatalf("反序列化失败: %v", err)
+			}
+
+			if loaded.Version != CURRENT_VERSION {
+				t.Errorf("版本号错误: %d", loaded.Version)
+			}
+			if loaded.FinalText != doc.FinalText || len(loaded.Sections) != len(doc.Sections) {
+				t.Errorf("反序列化内容不一致: %+v", loaded)
+			}
+			if loaded.Sections[1].Scores.Total != 8.1 {
+				t.Errorf("段落分数丢失: %+v", loaded.Sections[1])
+			}
+			if len(loaded.Media) != 1 || loaded.Media[0].Type != MEDIA_AUDIO {
+				t.Errorf("媒体引用丢失: %+v", loaded.Media)
+			}
+		})
+	}
+}
+
+func TestUnmarshalMissingVersion(t *testing.T) {
+	_, err := Unmarshal([]byte(`{"premise":"一只小兔子","final_text":"从前有一只小兔子。"}`), FORMAT_JSON)
+	if err == nil {
+		t.Error("没有版本号的文档应返回错误")
+	}
+}
+
+func TestUnmarshalFutureVersion(t *testing.T) {
+	_, err := Unmarshal([]byte(`{"version":99}`), FORMAT_JSON)
+	if err == nil {
+		t.Error("高于当前版本的文档应返回错误")
+	}
+}
+
+func TestToPlanInfo(t *testing.T) {
+	planInfo := newTestDocument().ToPlanInfo()
+
+	if len(planInfo.Characters) != 2 || planInfo.CharacterStrings[0] != "小白：胆小、善良、喜欢胡萝卜" {
+		t.Errorf("还原角色信息错误: %+v", planInfo.CharacterStrings)
+	}
+	if len(planInfo.OutlineSections) != 2 || planInfo.InferAttributesString == "" {
+		t.Errorf("还原大纲信息错误: %+v", planInfo)
+	}
+}
+
+func TestExportHTML(t *testing.T) {
+	doc := newTestDocument()
+	doc.Title = "<小白>"
+
+	content, err := doc.ExportHTML()
+	if err != nil {
+		t.Fatalf("导出 HTML 失败: %v", err)
+	}
+	if !strings.Contains(content, "<h1>&lt;小白&gt;</h1>") || !strings.Contains(content, "<p>天黑了，小白躲进了洞里。") {
+		t.Errorf("导出的 HTML 错误: %s", content)
+	}
+
+	doc.Pinyin = &pinyin_module.Annotation{Characters: []pinyin_module.Ruby{
+		{Text: "小", Pinyin: "xiǎo"}, {Text: "白", Pinyin: "bái"}, {Text: "！"},
+	}}
+	content, err = doc.ExportHTML()
+	if err != nil {
+		t.Fatalf("导出 HTML 失败: %v", err)
+	}
+	if !strings.Contains(content, "<p><ruby>小<rt>xiǎo</rt></ruby><ruby>白<rt>bái</rt></ruby>！</p>") {
+		t.Errorf("拼音标注未渲染为 ruby: %s", content)
+	}
+}
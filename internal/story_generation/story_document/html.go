@@ -0,0 +1,85 @@
+package story_document
+
+import (
+	"bytes"
+	"flutterdreams/internal/story_generation/common"
+	"flutterdreams/internal/story_generation/pinyin_module"
+	"html/template"
+	"strings"
+)
+
+// 导出 HTML 的模板，带拼音标注时每个汉字渲染为 <ruby>，样式兼顾屏幕阅读和打印
+var htmlTemplate = template.Must(template.New("story").Parse(`<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 40em; margin: 2em auto; padding: 0 1em; font-size: 20px; line-height: 2.4; }
+ruby rt { font-size: 0.55em; }
+.activity-sheet { margin-top: 3em; line-height: 1.8; }
+@media print { body { margin: 0 auto; } .activity-sheet { page-break-before: always; } }
+</style>
+</head>
+<body>
+<article>
+{{if .Title}}<h1>{{.Title}}</h1>
+{{end}}{{range .Paragraphs}}<p>{{range .}}{{if .Pinyin}}<ruby>{{.Text}}<rt>{{.Pinyin}}</rt></ruby>{{else}}{{.Text}}{{end}}{{end}}</p>
+{{end}}</article>
+{{if .ActivitySheet}}<section class="activity-sheet">
+{{range .ActivitySheet}}<p>{{.}}</p>
+{{end}}</section>
+{{end}}</body>
+</html>
+`))
+
+// ExportHTML 导出可在浏览器中阅读或打印的 HTML：
+// 文档带拼音标注时逐字显示拼音，否则按段落显示故事全文；学习单（如果有）另起一页
+func (doc *StoryDocument) ExportHTML() (string, error) {
+	data := struct {
+		Language      string
+		Title         string
+		Paragraphs    [][]pinyin_module.Ruby
+		ActivitySheet []string
+	}{
+		Language: common.GetLanguage(doc.Language).Code,
+		Title:    doc.Title,
+	}
+
+	var lines [][]pinyin_module.Ruby
+	if doc.Pinyin != nil {
+		lines = doc.Pinyin.Lines()
+	} else {
+		for _, line := range strings.Split(doc.FinalText, "\n") {
+			lines = append(lines, []pinyin_module.Ruby{{Text: line}})
+		}
+	}
+	for _, line := range lines {
+		if len(line) > 0 && strings.TrimSpace(rubyText(line)) != "" {
+			data.Paragraphs = append(data.Paragraphs, line)
+		}
+	}
+
+	if doc.ActivitySheet != nil {
+		for _, line := range strings.Split(doc.ActivitySheet.Text(), "\n") {
+			if strings.TrimSpace(line) != "" {
+				data.ActivitySheet = append(data.ActivitySheet, line)
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := htmlTemplate.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// 拼接一行标注的原文
+func rubyText(line []pinyin_module.Ruby) string {
+	var builder strings.Builder
+	for _, ruby := range line {
+		builder.WriteString(ruby.Text)
+	}
+	return builder.String()
+}
@@ -3,6 +3,10 @@ package main
 import (
 	"flutterdreams/config"
 	"flutterdreams/internal/route"
+	"flutterdreams/internal/story_generation/experiment_module"
+	"flutterdreams/internal/story_generation/job_module"
+	"flutterdreams/internal/story_generation/library_module"
+	"flutterdreams/internal/story_generation/prompt_module"
 	"fmt"
 	"github.com/rs/cors"
 	"log"
@@ -27,13 +31,48 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
+	// 启动时加载提示词模板，覆盖目录中的模板有误时拒绝启动
+	if err := prompt_module.Load(config.Prompts.Dir); err != nil {
+		log.Fatalf("Error loading prompt templates: %v", err)
+	}
+	if err := experiment_module.Validate(config.Experiments.Active); err != nil {
+		log.Fatalf("Invalid prompt experiments: %v", err)
+	}
+	if err := library_module.Open(config.Library); err != nil {
+		log.Fatalf("Error opening story library: %v", err)
+	}
+	// 认证默认启用，账户保存在故事库中；第一次启动时创建管理员，其 API key 只在日志中输出这一次
+	if config.Auth.Disabled {
+		log.Println("WARNING: authentication is DISABLED (auth.disabled: true); every endpoint is open to anyone who can reach this server and admin endpoints are unavailable. Do not use this setting outside local development.")
+	} else {
+		accounts := library_module.Accounts()
+		if accounts == nil {
+			log.Fatalf("Authentication requires the story library; enable the library or set auth.disabled: true for local development")
+		}
+		key, err := library_module.EnsureAdmin(accounts)
+		if err != nil {
+			log.Fatalf("Error creating admin account: %v", err)
+		}
+		if key != "" {
+			log.Printf("Created admin account, API key (shown only once): %s", key)
+		}
+	}
+	// 启动异步任务的 worker，存储中上次未结束的任务标记为中断
+	if _, err := job_module.Default(); err != nil {
+		log.Fatalf("Error starting job manager: %v", err)
+	}
 	router := route.InitRouter()
 
-	// 配置 CORS 允许来自 localhost:3000 的请求
+	// 配置 CORS 允许来自前端的请求，默认为 localhost:3000
+	origins := config.Server.AllowedOrigins
+	if len(origins) == 0 {
+		origins = []string{"http://localhost:3000"}
+	}
 	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:3000"}, // 允许的源
+		AllowedOrigins:   origins, // 允许的源
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
-		AllowedHeaders:   []string{"Content-Type"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", route.API_KEY_HEADER, route.REQUEST_ID_HEADER},
+		ExposedHeaders:   []string{route.REQUEST_ID_HEADER},
 		AllowCredentials: true,
 	})
 